@@ -1,10 +1,12 @@
 package core
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -49,6 +51,8 @@ const ActionSpacing = time.Minute * 5
 var (
 	ErrImpossible        = sterr.New("this error should not be possible under expected corcompstances, please report this")
 	ErrInvalidTargetType = sterr.New("failed to parse target")
+	ErrInvalidScope      = sterr.New("invalid scope %s")
+	ErrMissingScopes     = sterr.New("at least one scope is required")
 )
 
 // EI is shorthand for ErrImpossible wrapper
@@ -70,6 +74,8 @@ type Account struct {
 	Name, Password, Code, Email string
 
 	Cfg Config
+
+	TOTP TOTP
 }
 
 // AID implements IDer
@@ -80,12 +86,50 @@ func (a *Account) Cookie() http.Cookie {
 	return http.Cookie{Name: "user", Value: a.Name + " " + a.Password}
 }
 
+// HideCredentials clears password and verification code so account can be shown to its
+// owner, password is what authentication cookie carries so no token may read it
+func (a *Account) HideCredentials() {
+	a.Password = ""
+	a.Code = ""
+}
+
 // Censure censures all private information of user
 func (a *Account) Censure() {
 	a.Password = "i don't think so"
+	a.Code = ""
 	a.Email = "not.quite@gamil.com"
+	a.TOTP = TOTP{}
 }
 
+// TOTP is two factor authentication state of account, secrets never leave the server
+type TOTP struct {
+	Enabled bool
+
+	Secret, Pending string `json:"-"`
+
+	// Last is time step of last accepted code, used codes cannot be replayed
+	Last int64 `json:"-"`
+
+	// Recovery contains hashes of unused recovery codes
+	Recovery []string `json:"-"`
+}
+
+// Session proves that user passed second step of login, only hash of session token is stored
+type Session struct {
+	ID      ID `bson:"_id"`
+	Account ID
+
+	Hash string
+
+	BornDate, Expires int64
+
+	// ExpireAt is Expires as date, database deletes sessions by it
+	ExpireAt time.Time `json:"-"`
+}
+
+// AID implements IDer
+func (s *Session) AID() ID { return s.ID }
+
 // Config ...
 type Config struct {
 	Colors []string
@@ -104,9 +148,22 @@ type Note struct {
 
 	Published bool
 
+	// PublishDate is time of first publishing, it does not change when note is
+	// published again so feeds are not flooded
+	PublishDate int64
+
 	Theme, Subject, Name string
 
+	// Tags are normalized with NormalizeTags
+	Tags []string
+
 	Comments []ID
+
+	// Views counts how many times readers other than author opened published note
+	Views int64
+
+	// Notebook is id of notebook containing the note, 0 is the root
+	Notebook ID
 }
 
 // AID implements IDer
@@ -117,7 +174,9 @@ type Draft struct {
 	ID                   ID `bson:"_id"`
 	Month, Year          int
 	Theme, Subject, Name string
+	Tags                 []string
 	Published            bool
+	Notebook             ID
 }
 
 // NotePreview ...
@@ -127,6 +186,10 @@ type NotePreview struct {
 	BornDate uint64
 	Name     string
 	Content  string
+	Tags     []string
+
+	Published   bool
+	PublishDate int64
 }
 
 // As String for testing purposes
@@ -146,6 +209,101 @@ type Comment struct {
 // AID implements IDer
 func (a *Comment) AID() ID { return a.ID }
 
+// Scope limits what can be done with api token
+type Scope string
+
+// Scope variants, token that has any scope can also read
+const (
+	ReadS     Scope = "read-only"
+	NotesS    Scope = "notes:write"
+	CommentsS Scope = "comments:write"
+
+	// AccountS is never granted to tokens, only logged in user can manage the account
+	AccountS Scope = "account"
+)
+
+// APIToken grants scripted access to account, only hash of the token is stored
+type APIToken struct {
+	ID    ID `bson:"_id"`
+	Owner ID
+
+	Name string
+	Hash string `json:"-"`
+
+	Scopes []Scope
+
+	BornDate, LastUsed int64
+}
+
+// AID implements IDer
+func (t *APIToken) AID() ID { return t.ID }
+
+// Allows returns whether token can be used for action that requires given scope
+func (t *APIToken) Allows(scope Scope) bool {
+	if scope == ReadS {
+		return true
+	}
+
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseScopes parses space separated list of scopes that can be granted to token
+func ParseScopes(raw string) ([]Scope, error) {
+	var scopes []Scope
+	for _, s := range strings.Fields(raw) {
+		switch sc := Scope(s); sc {
+		case ReadS, NotesS, CommentsS:
+			scopes = append(scopes, sc)
+		default:
+			return nil, ErrInvalidScope.Args(s)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrMissingScopes
+	}
+
+	return scopes, nil
+}
+
+// Attachment is file uploaded by account that notes embed with <embed:ID> tag, ID is random
+// so the file can be shared by link and its extension tells whether it is an image, Thumb
+// is true if smaller copy of image is stored too
+type Attachment struct {
+	ID    string `bson:"_id"`
+	Owner ID
+
+	Name, Type string
+	Size       int
+	Thumb      bool
+
+	BornDate int64
+}
+
+// Audit is record of security related event, such as account lockout
+type Audit struct {
+	ID       ID `bson:"_id"`
+	BornDate int64
+
+	Kind, Subject, IP string
+
+	Until int64
+}
+
+// Audit kinds
+const (
+	LockoutA = "lockout"
+)
+
+// AID implements IDer
+func (a *Audit) AID() ID { return a.ID }
+
 // TargetType ...
 type TargetType uint8
 
@@ -186,6 +344,22 @@ func ParseID(id string) (ID, error) {
 	return i, EI(err)
 }
 
+// Hash returns hex encoded sha256 of a string, used for storing tokens
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomString returns hex encoded random bytes of given size
+func RandomString(size int) (string, error) {
+	bts := make([]byte, size)
+	_, err := rand.Read(bts)
+	if err != nil {
+		return "", EI(err)
+	}
+	return hex.EncodeToString(bts), nil
+}
+
 // Time returns current time in millis
 func Time() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
@@ -218,9 +392,7 @@ type SearchRequest struct {
 
 	Year  int `urlp:"optional"`
 	Month int `urlp:"optional"`
-}
 
-// Testing returns whether this is test run
-func Testing() bool {
-	return strings.HasSuffix(os.Args[0], ".test.exe")
+	// Tags is tag query parsed by ParseTagQuery
+	Tags string `urlp:"optional"`
 }
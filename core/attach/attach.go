@@ -0,0 +1,86 @@
+// Package attach stores files embedded in notes, type of file is sniffed from its content
+// and images get thumbnails so notes do not load full resolution images
+package attach
+
+import (
+	"io"
+	"myNotes/core"
+	"net/http"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// ThumbSuffix is appended to key of attachment to get key of its thumbnail
+const ThumbSuffix = ".thumb"
+
+// attachment errors
+var (
+	ErrType     = sterr.New("files of type %s can not be attached")
+	ErrTooLarge = sterr.New("file %s exceeds limit of %d bytes")
+	ErrQuota    = sterr.New("attachments would exceed quota of %d bytes")
+	ErrMissing  = sterr.New("file %s is missing in storage")
+	ErrImage    = sterr.New("image %s is too large to process")
+)
+
+// Exts maps sniffed content types that can be attached to extensions of attachment ids
+var Exts = map[string]string{
+	"image/png":                 "png",
+	"image/jpeg":                "jpg",
+	"image/gif":                 "gif",
+	"image/webp":                "webp",
+	"application/pdf":           "pdf",
+	"text/plain; charset=utf-8": "txt",
+	"application/zip":           "zip",
+}
+
+// Storage keeps content of attachments under keys, keys are ids of attachments or ids with
+// ThumbSuffix, deleting missing key is not an error
+type Storage interface {
+	Put(key string, data []byte) error
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+// Sniff detects type of file from its content, client provided type is not trusted
+func Sniff(data []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(data)
+	ext, ok := Exts[contentType]
+	if !ok {
+		return "", "", ErrType.Args(contentType)
+	}
+	return contentType, ext, nil
+}
+
+// New prepares attachment of owner from uploaded file, thumbnail is nil if it is not needed
+func New(owner core.ID, name string, data []byte, maxSize int) (core.Attachment, []byte, error) {
+	if len(data) > maxSize {
+		return core.Attachment{}, nil, ErrTooLarge.Args(name, maxSize)
+	}
+
+	contentType, ext, err := Sniff(data)
+	if err != nil {
+		return core.Attachment{}, nil, err
+	}
+
+	id, err := core.RandomString(12)
+	if err != nil {
+		return core.Attachment{}, nil, err
+	}
+
+	var thumb []byte
+	if core.ImageExts[ext] {
+		thumb, err = Thumbnail(name, data)
+		if err != nil {
+			return core.Attachment{}, nil, err
+		}
+	}
+
+	return core.Attachment{
+		ID:    id + "." + ext,
+		Owner: owner,
+		Name:  name,
+		Type:  contentType,
+		Size:  len(data),
+		Thumb: thumb != nil,
+	}, thumb, nil
+}
@@ -0,0 +1,114 @@
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+func pngOf(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		desc, name string
+		data       []byte
+		ext        string
+		thumb      bool
+		err        error
+	}{
+		{desc: "small image", name: "a.png", data: pngOf(10, 10), ext: ".png"},
+		{desc: "large image", name: "a.png", data: pngOf(1000, 500), ext: ".png", thumb: true},
+		{desc: "pdf", name: "a.pdf", data: []byte("%PDF-1.4\n"), ext: ".pdf"},
+		{desc: "text named as image", name: "a.png", data: []byte("hello"), ext: ".txt"},
+		{desc: "html", name: "a.html", data: []byte("<html><script></script></html>"), err: ErrType},
+		{desc: "too large", name: "a.txt", data: bytes.Repeat([]byte("a"), 1<<20+1), err: ErrTooLarge},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			a, thumb, err := New(7, tC.name, tC.data, 1<<20)
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(a.ID) != 24+len(tC.ext) || !strings.HasSuffix(a.ID, tC.ext) {
+				t.Error(a.ID)
+			}
+			if a.Owner != 7 || a.Name != tC.name || a.Size != len(tC.data) || a.Thumb != tC.thumb || (thumb != nil) != tC.thumb {
+				t.Error(a)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		w, h   int
+		tw, th int
+	}{
+		{desc: "wide", w: 1000, h: 500, tw: ThumbSize, th: ThumbSize / 2},
+		{desc: "tall", w: 500, h: 2000, tw: ThumbSize / 4, th: ThumbSize},
+		{desc: "thin", w: 5000, h: 2, tw: ThumbSize, th: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			thumb, err := Thumbnail("a.png", pngOf(tC.w, tC.h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tC.tw || cfg.Height != tC.th {
+				t.Error(cfg.Width, cfg.Height)
+			}
+		})
+	}
+}
+
+func TestDir(t *testing.T) {
+	d := Dir(t.TempDir() + "/files")
+
+	err := d.Put("a.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := d.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "hello" {
+		t.Error(string(data))
+	}
+
+	for _, key := range []string{"", "../a.txt", "sub/a.txt", ".upload-1"} {
+		if _, err := d.Open(key); !errors.Is(err, ErrMissing) {
+			t.Error(key, err)
+		}
+	}
+
+	if err := d.Delete("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete("a.txt"); err != nil {
+		t.Error(err)
+	}
+	if _, err := d.Open("a.txt"); !errors.Is(err, ErrMissing) {
+		t.Error(err)
+	}
+}
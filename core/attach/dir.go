@@ -0,0 +1,78 @@
+package attach
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Dir stores files in local directory, it is created with first file
+type Dir string
+
+// path returns path of key, keys with separators are refused so they can not escape Dir
+func (d Dir) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || key[0] == '.' {
+		return "", ErrMissing.Args(key)
+	}
+	return filepath.Join(string(d), key), nil
+}
+
+// Put implements Storage, file is written under temporary name and renamed so readers never
+// see partial file
+func (d Dir) Put(key string, data []byte) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(string(d), 0o750)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(string(d), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Open implements Storage
+func (d Dir) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrMissing.Args(key)
+	}
+	return f, err
+}
+
+// Delete implements Storage
+func (d Dir) Delete(key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
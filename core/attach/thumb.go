@@ -0,0 +1,92 @@
+package attach
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// thumbnail limits
+const (
+	// ThumbSize is the longest side of thumbnail
+	ThumbSize = 480
+	// MaxPixels protects server from images that are small files but decode to huge bitmaps
+	MaxPixels = 50_000_000
+	// samples per axis taken from source area of thumbnail pixel
+	samples = 4
+)
+
+// Thumbnail scales image down so its longer side is ThumbSize, gif and png thumbnails are png
+// so transparency is kept, result is nil if image is small enough already or its format can
+// not be decoded, webp for example
+func Thumbnail(name string, data []byte) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImage.Args(name)
+	}
+	if cfg.Width <= ThumbSize && cfg.Height <= ThumbSize {
+		return nil, nil
+	}
+
+	var src image.Image
+	switch format {
+	case "png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, nil
+	}
+
+	dst := scale(src, ThumbSize)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
+
+// scale averages samples*samples points of source area of every pixel, it is much faster
+// than averaging whole area and still smooth enough for thumbnails
+func scale(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	w, h := size, b.Dy()*size/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = b.Dx()*size/b.Dy(), size
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, bl, a uint32
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					px := b.Min.X + (x*samples+sx)*b.Dx()/(w*samples)
+					py := b.Min.Y + (y*samples+sy)*b.Dy()/(h*samples)
+					cr, cg, cb, ca := src.At(px, py).RGBA()
+					r, g, bl, a = r+cr, g+cg, bl+cb, a+ca
+				}
+			}
+
+			n := uint32(samples * samples * 257)
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+
+	return dst
+}
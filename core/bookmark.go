@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// bookmark limits
+const (
+	MaxBookmarks        = 1000
+	MaxCollectionLength = 64
+)
+
+// states of bookmarked notes
+const (
+	BookmarkOK          = "ok"
+	BookmarkUnpublished = "unpublished"
+	BookmarkDeleted     = "deleted"
+)
+
+// bookmark errors
+var (
+	ErrBookmarkLimit = sterr.New("account can have at most %d bookmarks")
+	ErrCollection    = sterr.New("collection name is longer than %d characters")
+)
+
+// Bookmark is private reference to note, Name is name of note at the time of bookmarking
+// so deleted notes can still be recognized, Collection is optional name of group
+type Bookmark struct {
+	Owner, Note ID
+
+	Name       string
+	Collection string `json:",omitempty"`
+
+	// Deleted is set when note is deleted, note id can be reused by other note
+	Deleted bool
+
+	BornDate int64
+}
+
+// BookmarkEntry is bookmark with current preview of note, Note is empty unless State
+// is BookmarkOK
+type BookmarkEntry struct {
+	Bookmark
+	State string
+	Note  NotePreview
+}
+
+// CollectionCount is bookmark collection with amount of bookmarks in it
+type CollectionCount struct {
+	Collection string `bson:"_id"`
+	Count      int
+}
+
+// NormalizeCollection trims collection name and checks its length, empty name means no
+// collection
+func NormalizeCollection(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > MaxCollectionLength {
+		return "", ErrCollection.Args(MaxCollectionLength)
+	}
+	return name, nil
+}
+
+// Entry pairs bookmark with preview of its note, viewer sees unpublished notes only if
+// they wrote them, ok is false when note was not found
+func (b Bookmark) Entry(nt NotePreview, ok bool, viewer ID) BookmarkEntry {
+	e := BookmarkEntry{Bookmark: b}
+	switch {
+	case b.Deleted || !ok:
+		e.State = BookmarkDeleted
+	case !nt.Published && nt.Author != viewer:
+		e.State = BookmarkUnpublished
+	default:
+		e.State = BookmarkOK
+		e.Note = nt
+	}
+	return e
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCollection(t *testing.T) {
+	testCases := []struct {
+		desc, name, out string
+		err             error
+	}{
+		{desc: "empty"},
+		{desc: "spaces", name: "  read   later ", out: "read later"},
+		{desc: "long", name: strings.Repeat("a", MaxCollectionLength+1), err: ErrCollection},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := NormalizeCollection(tC.name)
+			if out != tC.out || !errors.Is(err, tC.err) {
+				t.Error(out, err)
+			}
+		})
+	}
+}
+
+func TestBookmarkEntry(t *testing.T) {
+	b := Bookmark{Owner: 1, Note: 2, Name: "algebra"}
+
+	testCases := []struct {
+		desc   string
+		b      Bookmark
+		nt     NotePreview
+		found  bool
+		viewer ID
+		state  string
+	}{
+		{desc: "ok", b: b, nt: NotePreview{ID: 2, Author: 3, Published: true}, found: true, viewer: 1, state: BookmarkOK},
+		{desc: "unpublished", b: b, nt: NotePreview{ID: 2, Author: 3}, found: true, viewer: 1, state: BookmarkUnpublished},
+		{desc: "own draft", b: b, nt: NotePreview{ID: 2, Author: 1}, found: true, viewer: 1, state: BookmarkOK},
+		{desc: "missing", b: b, viewer: 1, state: BookmarkDeleted},
+		{desc: "reused id", b: Bookmark{Note: 2, Deleted: true}, nt: NotePreview{ID: 2, Published: true}, found: true, viewer: 1, state: BookmarkDeleted},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			e := tC.b.Entry(tC.nt, tC.found, tC.viewer)
+			if e.State != tC.state {
+				t.Error(e.State)
+			}
+			if e.State != BookmarkOK && e.Note.ID != 0 {
+				t.Error("preview of unavailable note leaked", e.Note)
+			}
+		})
+	}
+}
@@ -0,0 +1,526 @@
+// Package config loads typed configuration of the application, values come from defaults,
+// then YAML file, then environment variables and at last command-line flags
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakubDoka/sterr"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes all environment variables, MYNOTES_SERVER_PORT overrides server.port
+const EnvPrefix = "MYNOTES_"
+
+// errors of loading and validation
+var (
+	ErrRead     = sterr.New("failed to read config file %s")
+	ErrParse    = sterr.New("failed to parse config file %s")
+	ErrValue    = sterr.New("invalid value of %s")
+	ErrInvalid  = sterr.New("invalid %s: %s")
+	ErrFlags    = sterr.New("failed to parse flags")
+	ErrBaseDir  = sterr.New("failed to find base directory")
+	ErrRequired = sterr.New("%s is required")
+)
+
+// Config is configuration of whole application
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Storage     Storage     `yaml:"storage"`
+	Attachments Attachments `yaml:"attachments"`
+	Mail        Mail        `yaml:"mail"`
+	Limits      Limits      `yaml:"limits"`
+	Features    Features    `yaml:"features"`
+	Taxonomy    Taxonomy    `yaml:"taxonomy"`
+	Ranking     Ranking     `yaml:"ranking"`
+	Log         Log         `yaml:"log"`
+}
+
+// Server configures http server, frontend files are embedded in the binary unless PageDir
+// is set, relative PageDir is resolved against directory of config file or directory of
+// executable if there is no file
+type Server struct {
+	Host    string `yaml:"host" help:"address server listens on"`
+	Port    int    `yaml:"port" help:"port server listens on"`
+	PageDir string `yaml:"page_dir" help:"serve frontend files from this directory instead of embedded ones, for development"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" help:"time limit for reading request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" help:"time limit for reading whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" help:"time limit for writing responce"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" help:"how long keep-alive connection waits for next request"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" help:"how long shutdown waits for requests and emails"`
+
+	TLS      TLS      `yaml:"tls"`
+	Security Security `yaml:"security"`
+}
+
+// TLS configures https, it is enabled when Cert and Key are set, relative paths are resolved
+// same as PageDir
+type TLS struct {
+	Cert           string        `yaml:"cert" help:"certificate file in PEM format, enables https together with key"`
+	Key            string        `yaml:"key" help:"private key file in PEM format"`
+	ReloadInterval time.Duration `yaml:"reload_interval" help:"how often certificate files are checked for rotation"`
+	RedirectPort   int           `yaml:"redirect_port" help:"port of plain http listener that redirects to https, 0 disables it"`
+	HSTSMaxAge     time.Duration `yaml:"hsts_max_age" help:"max-age of Strict-Transport-Security header, 0 disables it"`
+}
+
+// Security configures headers sent with every responce, {nonce} in CSP is replaced by
+// nonce generated for each responce
+type Security struct {
+	CSP               string `yaml:"csp" help:"Content-Security-Policy, {nonce} is replaced by per-responce nonce"`
+	ReportOnly        bool   `yaml:"report_only" help:"only report policy violations instead of blocking"`
+	ReferrerPolicy    string `yaml:"referrer_policy" help:"value of Referrer-Policy header"`
+	PermissionsPolicy string `yaml:"permissions_policy" help:"value of Permissions-Policy header"`
+}
+
+// Enabled reports whether server should speak https
+func (t TLS) Enabled() bool {
+	return t.Cert != "" && t.Key != ""
+}
+
+// Storage configures mongo database
+type Storage struct {
+	URI         string        `yaml:"uri" help:"mongo connection string"`
+	Database    string        `yaml:"database" help:"name of the database"`
+	PingTimeout time.Duration `yaml:"ping_timeout" help:"time limit of readiness check"`
+}
+
+// Attachments configures files embedded in notes, relative Dir is resolved same as PageDir,
+// files that no note embeds are deleted once they are older than GCAge
+type Attachments struct {
+	Storage    string        `yaml:"storage" help:"where files are kept, dir or gridfs"`
+	Dir        string        `yaml:"dir" help:"directory of files when storage is dir"`
+	MaxSize    int           `yaml:"max_size" help:"size limit of single file in bytes"`
+	Quota      int           `yaml:"quota" help:"total size of files one account can upload in bytes"`
+	GCInterval time.Duration `yaml:"gc_interval" help:"how often files that no note embeds are deleted, 0 disables it"`
+	GCAge      time.Duration `yaml:"gc_age" help:"how old file has to be to get deleted, gives author time to save the note"`
+}
+
+// Mail configures smtp account used for verification emails, empty Template means
+// built-in one
+type Mail struct {
+	Host      string `yaml:"host" help:"smtp server host"`
+	Port      int    `yaml:"port" help:"smtp server port"`
+	Sender    string `yaml:"sender" help:"address emails are sent from"`
+	Password  string `yaml:"password" help:"password of sender, prefer environment variable"`
+	Template  string `yaml:"template" help:"path to verification email template"`
+	QueueSize int    `yaml:"queue_size" help:"amount of emails that can wait for delivery"`
+}
+
+// Limits configures lockouts of login and verification
+type Limits struct {
+	Account       Limit `yaml:"account"`
+	IP            Limit `yaml:"ip"`
+	EmailsPerHour int   `yaml:"emails_per_hour" help:"verification emails one account can get in an hour"`
+}
+
+// Limit describes how many failures key can make before it gets locked, delays are doubled
+// with each consequent failure
+type Limit struct {
+	Failures int           `yaml:"failures" help:"failures before lockout"`
+	Delay    time.Duration `yaml:"delay" help:"delay after first failure"`
+	MaxDelay time.Duration `yaml:"max_delay" help:"upper bound of delay"`
+	Lockout  time.Duration `yaml:"lockout" help:"duration of lockout"`
+}
+
+// Features toggles optional parts of application
+type Features struct {
+	Registration bool `yaml:"registration" help:"allow creating new accounts, needs mail.sender and mail.password for verification emails"`
+	Metrics      bool `yaml:"metrics" help:"expose prometheus metrics"`
+	OpenAPI      bool `yaml:"openapi" help:"serve OpenAPI document"`
+}
+
+// Taxonomy configures who manages schools, subjects and themes of notes, accounts are
+// listed by id because names can change
+type Taxonomy struct {
+	Admins string `yaml:"admins" help:"comma separated ids of accounts that can change taxonomy"`
+	Locale string `yaml:"locale" help:"locale of display names used when client prefers none of known ones"`
+}
+
+// AdminIDs parses Admins, config has to be valid
+func (t Taxonomy) AdminIDs() []uint64 {
+	var ids []uint64
+	for _, f := range strings.Split(t.Admins, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			id, _ := strconv.ParseUint(f, 10, 64)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Ranking configures top and trending notes, score of note is sum of its likes, comments
+// and views multiplied by weights, trending score halves every HalfLife of note age
+type Ranking struct {
+	Interval      time.Duration `yaml:"interval" help:"how often scores of notes are recomputed"`
+	HalfLife      time.Duration `yaml:"half_life" help:"age of note that halves its trending score"`
+	LikeWeight    int           `yaml:"like_weight" help:"points for each like"`
+	CommentWeight int           `yaml:"comment_weight" help:"points for each comment"`
+	ViewWeight    int           `yaml:"view_weight" help:"points for each view"`
+	ViewWindow    time.Duration `yaml:"view_window" help:"repeated views of note by same account or ip are counted once per this duration"`
+}
+
+// Log configures logger
+type Log struct {
+	Format string `yaml:"format" help:"json or text"`
+	Level  string `yaml:"level" help:"debug, info, warn or error"`
+}
+
+// Default returns configuration used when nothing overrides it
+func Default() Config {
+	return Config{
+		Server: Server{
+			Host:              "127.0.0.1",
+			Port:              5504,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			TLS: TLS{
+				ReloadInterval: time.Minute,
+				HSTSMaxAge:     180 * 24 * time.Hour,
+			},
+			Security: Security{
+				CSP: "default-src 'self'; script-src 'nonce-{nonce}' 'strict-dynamic'; " +
+					"style-src 'self' 'unsafe-inline'; img-src 'self' data: https://upload.wikimedia.org https://c1.wallpaperflare.com https://d1nhio0ox7pgb.cloudfront.net; " +
+					"object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'",
+				ReferrerPolicy:    "same-origin",
+				PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+			},
+		},
+		Storage: Storage{
+			URI:         "mongodb://127.0.0.1:27017",
+			Database:    "myNotes",
+			PingTimeout: 2 * time.Second,
+		},
+		Attachments: Attachments{
+			Storage:    "dir",
+			Dir:        "attachments",
+			MaxSize:    5 << 20,
+			Quota:      100 << 20,
+			GCInterval: time.Hour,
+			GCAge:      24 * time.Hour,
+		},
+		Mail: Mail{
+			Host:      "smtp.gmail.com",
+			Port:      587,
+			QueueSize: 64,
+		},
+		Limits: Limits{
+			Account: Limit{
+				Failures: 5,
+				Delay:    time.Second,
+				MaxDelay: time.Second * 30,
+				Lockout:  time.Minute * 15,
+			},
+			IP: Limit{
+				Failures: 20,
+				Delay:    time.Millisecond * 250,
+				MaxDelay: time.Second * 10,
+				Lockout:  time.Hour,
+			},
+			EmailsPerHour: 3,
+		},
+		Features: Features{
+			Registration: false,
+			Metrics:      true,
+			OpenAPI:      true,
+		},
+		Taxonomy: Taxonomy{
+			Locale: "en",
+		},
+		Ranking: Ranking{
+			Interval:      10 * time.Minute,
+			HalfLife:      48 * time.Hour,
+			LikeWeight:    5,
+			CommentWeight: 3,
+			ViewWeight:    1,
+			ViewWindow:    time.Hour,
+		},
+		Log: Log{
+			Format: "text",
+			Level:  "info",
+		},
+	}
+}
+
+// Load builds configuration from args (without program name) and environment, file is
+// taken from -config flag or MYNOTES_CONFIG variable, result is validated
+func Load(args []string, env func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("myNotes", flag.ContinueOnError)
+	path := fs.String("config", "", "path to YAML config file")
+	overrides := map[string]string{}
+	walk(&cfg, func(name []string, f reflect.Value, help string) {
+		key := strings.Join(name, ".")
+		store := func(s string) error {
+			overrides[key] = s
+			return nil
+		}
+
+		if f.Kind() == reflect.Bool {
+			fs.BoolFunc(key, help+" (default "+format(f)+")", store)
+		} else {
+			fs.Func(key, help+" (default "+format(f)+")", store)
+		}
+	})
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, ErrFlags.Wrap(err)
+	}
+
+	if *path == "" {
+		*path, _ = env(EnvPrefix + "CONFIG")
+	}
+
+	var base string
+	if *path != "" {
+		bts, err := os.ReadFile(*path)
+		if err != nil {
+			return cfg, ErrRead.Args(*path).Wrap(err)
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(bts))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, ErrParse.Args(*path).Wrap(err)
+		}
+
+		base, err = filepath.Abs(filepath.Dir(*path))
+		if err != nil {
+			return cfg, ErrBaseDir.Wrap(err)
+		}
+	} else {
+		exe, err := os.Executable()
+		if err != nil {
+			return cfg, ErrBaseDir.Wrap(err)
+		}
+		base = filepath.Dir(exe)
+	}
+
+	// environment first so flags win
+	err = nil
+	walk(&cfg, func(name []string, f reflect.Value, help string) {
+		key := EnvPrefix + strings.ToUpper(strings.Join(name, "_"))
+		if v, ok := env(key); ok && err == nil {
+			if serr := set(f, v); serr != nil {
+				err = ErrValue.Args(key).Wrap(serr)
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	walk(&cfg, func(name []string, f reflect.Value, help string) {
+		key := strings.Join(name, ".")
+		if v, ok := overrides[key]; ok && err == nil {
+			if serr := set(f, v); serr != nil {
+				err = ErrValue.Args("-" + key).Wrap(serr)
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.Resolve(base)
+
+	return cfg, cfg.Validate()
+}
+
+// Resolve makes relative paths absolute against base
+func (c *Config) Resolve(base string) {
+	for _, p := range []*string{&c.Server.PageDir, &c.Mail.Template, &c.Server.TLS.Cert, &c.Server.TLS.Key, &c.Attachments.Dir} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(base, *p)
+		}
+	}
+}
+
+// Validate reports all problems of configuration at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, problem string) {
+		if !ok {
+			errs = append(errs, ErrInvalid.Args(field, problem))
+		}
+	}
+
+	check(c.Server.Host != "", "server.host", "empty host")
+	check(c.Server.Port >= 0 && c.Server.Port <= 65535, "server.port", "out of range")
+	if c.Server.PageDir != "" {
+		info, err := os.Stat(c.Server.PageDir)
+		check(err == nil && info.IsDir(), "server.page_dir", "not a directory")
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+		"storage.ping_timeout":       c.Storage.PingTimeout,
+	} {
+		check(d > 0, name, "has to be positive")
+	}
+
+	if tls := c.Server.TLS; tls.Cert != "" || tls.Key != "" {
+		check(tls.Enabled(), "server.tls", "both cert and key have to be set")
+		for name, path := range map[string]string{"server.tls.cert": tls.Cert, "server.tls.key": tls.Key} {
+			if path != "" {
+				_, err := os.Stat(path)
+				check(err == nil, name, "file does not exist")
+			}
+		}
+		check(tls.ReloadInterval > 0, "server.tls.reload_interval", "has to be positive")
+		check(tls.RedirectPort >= 0 && tls.RedirectPort <= 65535 && tls.RedirectPort != c.Server.Port,
+			"server.tls.redirect_port", "out of range or same as server.port")
+		check(tls.HSTSMaxAge >= 0, "server.tls.hsts_max_age", "can not be negative")
+	}
+
+	check(c.Server.Security.CSP != "", "server.security.csp", "empty policy")
+	check(!strings.ContainsAny(c.Server.Security.CSP+c.Server.Security.ReferrerPolicy+c.Server.Security.PermissionsPolicy, "\r\n"),
+		"server.security", "headers can not contain line breaks")
+
+	check(strings.HasPrefix(c.Storage.URI, "mongodb://") || strings.HasPrefix(c.Storage.URI, "mongodb+srv://"),
+		"storage.uri", "expected mongodb:// or mongodb+srv:// scheme")
+	check(c.Storage.Database != "", "storage.database", "empty name")
+
+	switch a := c.Attachments; a.Storage {
+	case "dir":
+		check(a.Dir != "", "attachments.dir", "empty directory")
+	case "gridfs":
+	default:
+		check(false, "attachments.storage", "expected dir or gridfs")
+	}
+	check(c.Attachments.MaxSize > 0, "attachments.max_size", "has to be positive")
+	check(c.Attachments.Quota >= c.Attachments.MaxSize, "attachments.quota", "has to be at least max_size")
+	check(c.Attachments.GCInterval >= 0, "attachments.gc_interval", "can not be negative")
+	check(c.Attachments.GCAge > 0, "attachments.gc_age", "has to be positive")
+
+	check(c.Mail.Host != "", "mail.host", "empty host")
+	check(c.Mail.Port > 0 && c.Mail.Port <= 65535, "mail.port", "out of range")
+	check(c.Mail.QueueSize > 0, "mail.queue_size", "has to be positive")
+	if c.Features.Registration {
+		// accounts can not be verified without email
+		if _, err := mail.ParseAddress(c.Mail.Sender); err != nil {
+			errs = append(errs, ErrRequired.Args("valid mail.sender"))
+		}
+		if c.Mail.Password == "" {
+			errs = append(errs, ErrRequired.Args("mail.password"))
+		}
+	}
+	if c.Mail.Template != "" {
+		_, err := os.Stat(c.Mail.Template)
+		check(err == nil, "mail.template", "file does not exist")
+	}
+
+	for name, l := range map[string]Limit{"limits.account": c.Limits.Account, "limits.ip": c.Limits.IP} {
+		check(l.Failures > 0, name+".failures", "has to be positive")
+		check(l.Delay >= 0 && l.MaxDelay >= l.Delay, name+".max_delay", "has to be at least delay")
+		check(l.Lockout > 0, name+".lockout", "has to be positive")
+	}
+	check(c.Limits.EmailsPerHour > 0, "limits.emails_per_hour", "has to be positive")
+
+	for _, f := range strings.Split(c.Taxonomy.Admins, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			id, err := strconv.ParseUint(f, 10, 64)
+			check(err == nil && id != 0, "taxonomy.admins", "expected comma separated account ids")
+		}
+	}
+	check(c.Taxonomy.Locale != "", "taxonomy.locale", "empty locale")
+
+	r := c.Ranking
+	check(r.Interval > 0, "ranking.interval", "has to be positive")
+	check(r.HalfLife > 0, "ranking.half_life", "has to be positive")
+	check(r.ViewWindow > 0, "ranking.view_window", "has to be positive")
+	check(r.LikeWeight >= 0 && r.CommentWeight >= 0 && r.ViewWeight >= 0, "ranking.like_weight", "weights can not be negative")
+	check(r.LikeWeight+r.CommentWeight+r.ViewWeight > 0, "ranking.like_weight", "some weight has to be positive")
+
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "expected json or text")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "unknown level")
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel returns parsed log level, config has to be valid
+func (l Log) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(l.Level))
+	return level
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk calls fn for every leaf field of configuration, name is made of yaml keys
+func walk(cfg *Config, fn func(name []string, f reflect.Value, help string)) {
+	var rec func(v reflect.Value, name []string)
+	rec = func(v reflect.Value, name []string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			n := append(name[:len(name):len(name)], sf.Tag.Get("yaml"))
+			if sf.Type.Kind() == reflect.Struct {
+				rec(v.Field(i), n)
+				continue
+			}
+			fn(n, v.Field(i), sf.Tag.Get("help"))
+		}
+	}
+	rec(reflect.ValueOf(cfg).Elem(), nil)
+}
+
+func set(f reflect.Value, s string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	default:
+		panic("unsupported config field " + f.Type().String())
+	}
+
+	return nil
+}
+
+func format(f reflect.Value) string {
+	if f.Type() == durationType {
+		return time.Duration(f.Int()).String()
+	}
+	return fmt.Sprint(f.Interface())
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "web"), 0o700)
+
+	file := filepath.Join(dir, "config.yaml")
+	os.WriteFile(file, []byte(`
+server:
+  port: 8000
+  page_dir: web
+  read_timeout: 1m
+storage:
+  database: fromfile
+mail:
+  sender: bot@gmail.com
+limits:
+  account:
+    failures: 7
+`), 0o600)
+
+	env := map[string]string{
+		"MYNOTES_CONFIG":           file,
+		"MYNOTES_STORAGE_DATABASE": "fromenv",
+		"MYNOTES_MAIL_PASSWORD":    "secret",
+		"MYNOTES_SERVER_PORT":      "8001",
+		"MYNOTES_TAXONOMY_ADMINS":  "3, 12",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg, err := Load([]string{"-server.port=8002", "-features.metrics=false"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc          string
+		value, expect interface{}
+	}{
+		{"default", cfg.Server.Host, "127.0.0.1"},
+		{"file", cfg.Server.ReadTimeout, time.Minute},
+		{"nested file", cfg.Limits.Account.Failures, 7},
+		{"env over file", cfg.Storage.Database, "fromenv"},
+		{"flag over env", cfg.Server.Port, 8002},
+		{"bool flag", cfg.Features.Metrics, false},
+		{"relative path", cfg.Server.PageDir, filepath.Join(dir, "web")},
+		{"relative attachments", cfg.Attachments.Dir, filepath.Join(dir, "attachments")},
+		{"admins", fmt.Sprint(cfg.Taxonomy.AdminIDs()), "[3 12]"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if tC.value != tC.expect {
+				t.Error(tC.value, tC.expect)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.yaml")
+	os.WriteFile(unknown, []byte("server:\n  prot: 80\n"), 0o600)
+
+	none := func(string) (string, bool) { return "", false }
+
+	testCases := []struct {
+		desc string
+		args []string
+		err  error
+	}{
+		{"missing file", []string{"-config", filepath.Join(dir, "missing.yaml")}, ErrRead},
+		{"unknown key", []string{"-config", unknown}, ErrParse},
+		{"bad value", []string{"-server.port=eighty"}, ErrValue},
+		{"invalid", []string{"-server.page_dir=" + dir, "-log.format=xml", "-storage.uri=http://db"}, ErrInvalid},
+		{"cert without key", []string{"-server.page_dir=" + dir, "-features.registration=false", "-server.tls.cert=" + unknown}, ErrInvalid},
+		{"empty policy", []string{"-server.page_dir=" + dir, "-features.registration=false", "-server.security.csp="}, ErrInvalid},
+		{"attachment storage", []string{"-server.page_dir=" + dir, "-features.registration=false", "-attachments.storage=s3"}, ErrInvalid},
+		{"taxonomy admins", []string{"-server.page_dir=" + dir, "-features.registration=false", "-taxonomy.admins=1,root"}, ErrInvalid},
+		{"ranking weights", []string{"-server.page_dir=" + dir, "-features.registration=false", "-ranking.like_weight=0", "-ranking.comment_weight=0", "-ranking.view_weight=0"}, ErrInvalid},
+		{"view window", []string{"-server.page_dir=" + dir, "-features.registration=false", "-ranking.view_window=0s"}, ErrInvalid},
+		{"defaults", []string{"-server.page_dir=" + dir}, nil},
+		{"mail required", []string{"-server.page_dir=" + dir, "-features.registration=true"}, ErrRequired},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := Load(tC.args, none)
+			if !errors.Is(err, tC.err) {
+				t.Error(err, tC.err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAll(t *testing.T) {
+	cfg := Default()
+	cfg.Server.PageDir = t.TempDir()
+	cfg.Features.Registration = false
+	cfg.Log.Format = "xml"
+	cfg.Limits.IP.Failures = 0
+	cfg.Server.WriteTimeout = 0
+
+	err := cfg.Validate()
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 3 {
+		t.Error(n, err)
+	}
+}
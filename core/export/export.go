@@ -0,0 +1,83 @@
+// Package export converts notes to standalone documents that can be printed or handed in,
+// supported formats are self-contained HTML, CommonMark and PDF
+package export
+
+import (
+	"io"
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"strconv"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// supported formats, values are also file extensions
+const (
+	HTML     = "html"
+	Markdown = "md"
+	PDF      = "pdf"
+)
+
+// ErrFormat is returned for unknown format
+var ErrFormat = sterr.New("unknown export format %s, expected html, md or pdf")
+
+// ContentTypes of supported formats
+var ContentTypes = map[string]string{
+	HTML:     "text/html; charset=utf-8",
+	Markdown: "text/markdown; charset=utf-8",
+	PDF:      "application/pdf",
+}
+
+// Document is note prepared for export, colors of author are used for colored text and
+// Labels are display names of school, subject and theme
+type Document struct {
+	Note   core.Note
+	Author core.Account
+	Labels taxonomy.Labels
+}
+
+// Field is one piece of note metadata
+type Field struct {
+	Name, Value string
+}
+
+// Meta returns metadata of note in order they are displayed, empty values are skipped
+func (d Document) Meta() []Field {
+	fields := []Field{
+		{"subject", d.Labels.Subject},
+		{"theme", d.Labels.Theme},
+		{"school", d.Labels.School},
+		{"year", strconv.Itoa(d.Note.Year)},
+		{"month", strconv.Itoa(d.Note.Month)},
+		{"author", d.Author.Name},
+	}
+
+	meta := fields[:0]
+	for _, f := range fields {
+		if f.Value != "" && f.Value != "0" {
+			meta = append(meta, f)
+		}
+	}
+	return meta
+}
+
+// Write writes documents in format to w
+func Write(w io.Writer, format string, docs []Document) error {
+	switch format {
+	case HTML:
+		return WriteHTML(w, docs)
+	case Markdown:
+		return WriteMarkdown(w, docs)
+	case PDF:
+		return WritePDF(w, docs)
+	}
+	return ErrFormat.Args(format)
+}
+
+// Title returns title of export, name of note if there is only one
+func Title(docs []Document) string {
+	if len(docs) == 1 && docs[0].Note.Name != "" {
+		return docs[0].Note.Name
+	}
+	return "myNotes export"
+}
@@ -0,0 +1,158 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testDocs() []Document {
+	return []Document{
+		{
+			Note: core.Note{
+				Name:    "Linear Algebra",
+				Subject: "math",
+				Theme:   "vectors",
+				School:  3,
+				Year:    2021,
+				Month:   4,
+				Content: "<t>Vectors<t>\n<b>bold<b> and <1>red<1> <u>under<u>\n\n    # not heading *x*\n<script>",
+			},
+			Author: core.Account{Name: "bob", Cfg: core.Config{Colors: []string{"#ff0000"}}},
+			Labels: taxonomy.Labels{School: "university", Subject: "math", Theme: "vectors"},
+		},
+		{
+			Note:   core.Note{Name: "Derivace", Content: strings.Repeat("příliš žluťoučký kůň ", 400)},
+			Author: core.Account{Name: "alice"},
+			Labels: taxonomy.Labels{School: "none"},
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		desc, format string
+		contains     []string
+		err          error
+	}{
+		{
+			desc: "html", format: HTML,
+			contains: []string{
+				"<title>myNotes export</title>",
+				".italic {",
+				"<h1>Linear Algebra</h1>",
+				"<span>school: university</span>",
+				`<span class="base" style="color: #ff0000;">red</span>`,
+				"&lt;script&gt;",
+			},
+		},
+		{
+			desc: "markdown", format: Markdown,
+			contains: []string{
+				"# Linear Algebra\n\n*subject: math · theme: vectors · school: university · year: 2021 · month: 4 · author: bob*\n\n",
+				"## Vectors\n\n",
+				`**bold** and <span style="color: #ff0000">red</span> <u>under</u>` + "\n\n",
+				`&emsp;\# not heading \*x\*` + "\n\n",
+				`\<script\>`,
+				"\n---\n\n# Derivace\n\n*school: none · author: alice*",
+			},
+		},
+		{desc: "pdf", format: PDF, contains: []string{"%PDF-1.4", "%%EOF"}},
+		{desc: "unknown", format: "docx", err: ErrFormat},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Write(&buf, tC.format, testDocs())
+			if tC.err != nil {
+				if err == nil || !strings.Contains(err.Error(), "docx") {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, c := range tC.contains {
+				if !strings.Contains(buf.String(), c) {
+					t.Errorf("missing %q in\n%s", c, buf.String())
+				}
+			}
+		})
+	}
+}
+
+var pdfObject = regexp.MustCompile(`(?s)(\d+) 0 obj\n<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+
+func TestPDFStructure(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, testDocs()); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.String()
+
+	// every xref entry has to point at its object
+	start, _ := strconv.Atoi(strings.Fields(file[strings.LastIndex(file, "startxref"):])[1])
+	entries := strings.Split(strings.TrimSpace(file[start:strings.Index(file, "trailer")]), "\n")[3:]
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(e[:10])
+		if !strings.HasPrefix(file[offset:], strconv.Itoa(i+1)+" 0 obj") {
+			t.Fatalf("entry %d points to %q", i+1, file[offset:offset+10])
+		}
+	}
+
+	var content strings.Builder
+	for _, m := range pdfObject.FindAllStringSubmatchIndex(file, -1) {
+		length, _ := strconv.Atoi(file[m[4]:m[5]])
+		zr, err := zlib.NewReader(strings.NewReader(file[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(&content, zr)
+	}
+
+	pages := strings.Count(file, "/Type /Page ")
+	if pages < 3 || !strings.Contains(file, "/Count "+strconv.Itoa(pages)) {
+		t.Error("expected long note to continue on next page", pages)
+	}
+
+	for _, c := range []string{
+		"/F2 16.0 Tf",
+		"1.000 0.000 0.000 rg",
+		"(red) Tj",
+		"lutouck\xfd kun pr\xedli\x9a ",
+		"(    # not heading *x*) Tj",
+		" re f",
+	} {
+		if !strings.Contains(content.String(), c) {
+			t.Errorf("missing %q", c)
+		}
+	}
+}
+
+func TestPDFColor(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out [3]float64
+	}{
+		{"#ff0000", [3]float64{1, 0, 0}},
+		{"#0f0", [3]float64{0, 1, 0}},
+		{"blue", [3]float64{0, 0, 1}},
+		{"rgb(1, 2, 3)", [3]float64{}},
+		{"#zzz", [3]float64{}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.in, func(t *testing.T) {
+			if out := pdfColor(tC.in); out != tC.out {
+				t.Error(out)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package export
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"myNotes/core"
+	"myNotes/web"
+)
+
+//go:embed export.html
+var htmlPage string
+
+var htmlTemplate = template.Must(template.New("export").Parse(htmlPage))
+
+// stylesheet of markup is the same one site uses
+var markupCSS = func() template.CSS {
+	css, err := fs.ReadFile(web.FS, "stiles/markdown.css")
+	if err != nil {
+		panic(err)
+	}
+	return template.CSS(css)
+}()
+
+type htmlNote struct {
+	Name    string
+	Meta    []Field
+	Content template.HTML
+}
+
+// WriteHTML writes single html file with inlined styles, every note is rendered with colors
+// of its author
+func WriteHTML(w io.Writer, docs []Document) error {
+	notes := make([]htmlNote, len(docs))
+	for i, d := range docs {
+		notes[i] = htmlNote{
+			Name:    d.Note.Name,
+			Meta:    d.Meta(),
+			Content: template.HTML(core.NMarkup(d.Author.Cfg.Colors).Render(d.Note.Content)),
+		}
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		CSS   template.CSS
+		Notes []htmlNote
+	}{Title(docs), markupCSS, notes})
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"bufio"
+	"io"
+	"myNotes/core"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdEscape = strings.NewReplacer(
+		`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+		"<", `\<`, ">", `\>`, "!", `\!`, "&", `\&`, "|", `\|`,
+		// four spaces are tab of markup, in markdown they would start code block
+		"    ", "&emsp;",
+	)
+	// line starts that would turn paragraph into heading, list or quote
+	mdBlockStart = regexp.MustCompile(`^(\s*)([#=+-]|\d+[.)])`)
+)
+
+// WriteMarkdown writes CommonMark document, every line of note becomes paragraph, underline
+// and colors are kept as inline html since markdown has no syntax for them
+func WriteMarkdown(w io.Writer, docs []Document) error {
+	bw := bufio.NewWriter(w)
+
+	for i, d := range docs {
+		if i != 0 {
+			bw.WriteString("\n---\n\n")
+		}
+
+		bw.WriteString("# " + mdText(d.Note.Name) + "\n\n")
+
+		var meta []string
+		for _, f := range d.Meta() {
+			meta = append(meta, f.Name+": "+mdText(f.Value))
+		}
+		if meta != nil {
+			bw.WriteString("*" + strings.Join(meta, " · ") + "*\n\n")
+		}
+
+		for _, line := range core.NMarkup(d.Author.Cfg.Colors).Lines(d.Note.Content) {
+			if md := mdLine(line); md != "" {
+				bw.WriteString(md + "\n\n")
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// mdLine converts line of runs, line that is title as a whole becomes heading
+func mdLine(line []core.Run) string {
+	heading := len(line) != 0
+	for _, r := range line {
+		heading = heading && (r.Title || strings.TrimSpace(r.Text) == "")
+	}
+
+	var sb strings.Builder
+	for _, r := range line {
+		if heading {
+			r.Title = false
+		}
+		sb.WriteString(mdRun(r))
+	}
+
+	md := strings.TrimRight(sb.String(), " ")
+	if strings.TrimSpace(md) == "" {
+		return ""
+	}
+	if heading {
+		return "## " + strings.TrimSpace(md)
+	}
+	return md
+}
+
+// mdRun formats text of run, delimiters have to touch the text so surrounding spaces are
+// moved outside of them
+func mdRun(r core.Run) string {
+	text := strings.TrimSpace(r.Text)
+	if text == "" {
+		return mdText(r.Text)
+	}
+	lead := r.Text[:strings.Index(r.Text, text)]
+	trail := r.Text[len(lead)+len(text):]
+
+	text = mdText(text)
+	if r.Italic {
+		text = "*" + text + "*"
+	}
+	if r.Bold || r.Title {
+		text = "**" + text + "**"
+	}
+	if r.Underline {
+		text = "<u>" + text + "</u>"
+	}
+	if r.Color != "" {
+		text = `<span style="color: ` + r.Color + `">` + text + "</span>"
+	}
+
+	return mdText(lead) + text + mdText(trail)
+}
+
+func mdText(s string) string {
+	s = mdEscape.Replace(s)
+	return mdBlockStart.ReplaceAllStringFunc(s, func(m string) string {
+		return m[:len(m)-1] + `\` + m[len(m)-1:]
+	})
+}
@@ -0,0 +1,431 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"myNotes/core"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// page geometry in points, A4
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	margin     = 56.0
+	textWidth  = pageWidth - 2*margin
+
+	bodySize  = 11.0
+	titleSize = 16.0
+	nameSize  = 20.0
+	metaSize  = 9.0
+	leading   = 1.35
+)
+
+// fonts are standard Type1 fonts every reader has so nothing needs to be embedded, index is
+// bold + 2*italic
+var pdfFonts = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"}
+
+// PDFCharset describes which letters survive in PDF, it is part of endpoint descriptions
+const PDFCharset = "pdf keeps Windows-1252 letters, other latin letters lose their diacritics and other scripts become ?"
+
+// WritePDF writes PDF document, every note starts on new page, text is set in Helvetica
+// which covers Windows-1252 only, see PDFCharset
+func WritePDF(w io.Writer, docs []Document) error {
+	l := &pdfLayout{}
+	for _, d := range docs {
+		l.document(d)
+	}
+	if len(l.pages) == 0 {
+		l.newPage()
+	}
+
+	return l.write(w, Title(docs))
+}
+
+type pdfLayout struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+// fragment is piece of text that is placed at once
+type fragment struct {
+	text        string
+	font        int
+	size, width float64
+	color       [3]float64
+	underline   bool
+}
+
+func (f fragment) style() fragment {
+	f.text, f.width = "", 0
+	return f
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = &bytes.Buffer{}
+	l.pages = append(l.pages, l.page)
+	l.y = pageHeight - margin
+}
+
+func (l *pdfLayout) document(d Document) {
+	l.newPage()
+
+	l.paragraph([]fragment{{text: d.Note.Name, font: 1, size: nameSize}})
+
+	var meta []string
+	for _, f := range d.Meta() {
+		meta = append(meta, f.Name+": "+f.Value)
+	}
+	if meta != nil {
+		l.paragraph([]fragment{{text: strings.Join(meta, "  ·  "), size: metaSize, color: [3]float64{0.4, 0.4, 0.4}}})
+	}
+
+	l.y -= 4
+	fmt.Fprintf(l.page, "0.8 0.8 0.8 RG 0.5 w %.2f %.2f m %.2f %.2f l S\n", margin, l.y, pageWidth-margin, l.y)
+	l.y -= 8
+
+	for _, line := range core.NMarkup(d.Author.Cfg.Colors).Lines(d.Note.Content) {
+		var frags []fragment
+		for _, r := range line {
+			f := fragment{
+				text:      r.Text,
+				size:      bodySize,
+				color:     pdfColor(r.Color),
+				underline: r.Underline,
+			}
+			if r.Bold || r.Title {
+				f.font |= 1
+			}
+			if r.Italic {
+				f.font |= 2
+			}
+			if r.Title {
+				f.size = titleSize
+			}
+			frags = append(frags, f)
+		}
+
+		if len(frags) == 0 {
+			l.y -= bodySize * leading
+			continue
+		}
+		l.paragraph(frags)
+		l.y -= 3
+	}
+}
+
+// paragraph wraps fragments to lines of text width and places them
+func (l *pdfLayout) paragraph(frags []fragment) {
+	var line []fragment
+	x := 0.0
+
+	for _, f := range frags {
+		for _, word := range splitWords(f.text) {
+			w := f
+			w.text = word
+			w.width = textWidthOf(word, f.font, f.size)
+
+			if x+w.width > textWidth && x > 0 {
+				l.line(line)
+				line, x = nil, 0
+				if strings.TrimSpace(word) == "" {
+					continue
+				}
+			}
+
+			// words longer than whole line are broken anywhere
+			for w.width > textWidth {
+				cut := fitRunes(w.text, w.font, w.size, textWidth)
+				head := w
+				head.text = w.text[:cut]
+				head.width = textWidthOf(head.text, w.font, w.size)
+				l.line(append(line, head))
+				line, x = nil, 0
+				w.text = w.text[cut:]
+				w.width = textWidthOf(w.text, w.font, w.size)
+			}
+
+			line = append(line, w)
+			x += w.width
+		}
+	}
+
+	l.line(line)
+}
+
+// line places one line of fragments, new page is started when it does not fit
+func (l *pdfLayout) line(frags []fragment) {
+	size := 0.0
+	for _, f := range frags {
+		size = max(size, f.size)
+	}
+	if size == 0 {
+		return
+	}
+
+	if l.y-size*leading < margin {
+		l.newPage()
+	}
+	l.y -= size * leading
+	baseline := l.y + size*(leading-1)
+
+	// neighbours of same style are placed at once so text can be searched and copied
+	var merged []fragment
+	for _, f := range frags {
+		if n := len(merged) - 1; n >= 0 && merged[n].style() == f.style() {
+			merged[n].text += f.text
+			merged[n].width += f.width
+			continue
+		}
+		merged = append(merged, f)
+	}
+
+	x := margin
+	for _, f := range merged {
+		c := f.color
+		fmt.Fprintf(l.page, "BT /F%d %.1f Tf %.3f %.3f %.3f rg %.2f %.2f Td (%s) Tj ET\n",
+			f.font+1, f.size, c[0], c[1], c[2], x, baseline, pdfString(winAnsi(f.text)))
+		if f.underline {
+			fmt.Fprintf(l.page, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n",
+				c[0], c[1], c[2], x, baseline-f.size*0.12, f.width, f.size*0.06)
+		}
+		x += f.width
+	}
+}
+
+// write serializes pages to PDF file with cross reference table
+func (l *pdfLayout) write(w io.Writer, title string) error {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// catalog and page tree are referenced before pages exist so their ids are fixed
+	pagesID, fontsID := 2, 3
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	offsets = append(offsets, 0)
+	fonts := make([]string, len(pdfFonts))
+	for i, name := range pdfFonts {
+		fonts[i] = fmt.Sprintf("/F%d %d 0 R", i+1, fontsID+i)
+		obj("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+	}
+
+	var kids []string
+	for _, p := range l.pages {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(p.Bytes())
+		zw.Close()
+
+		content := obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes()))
+		page := obj(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pagesID, pageWidth, pageHeight, strings.Join(fonts, " "), content))
+		kids = append(kids, strconv.Itoa(page)+" 0 R")
+	}
+
+	info := obj("<< /Title " + pdfTextString(title) + " /Producer (myNotes) >>")
+
+	// page tree is written last when all pages are known
+	offsets[pagesID-1] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", pagesID, strings.Join(kids, " "), len(kids))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// splitWords splits text to words keeping spaces attached to the word before them so
+// wrapped lines do not start with space
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i-1] == ' ' && text[i] != ' ' {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	return append(words, text[start:])
+}
+
+// fitRunes returns byte length of longest prefix of text that fits into width, at least
+// one rune is always taken
+func fitRunes(text string, font int, size, width float64) int {
+	x := 0.0
+	for i, r := range text {
+		x += charWidth(r, font) * size / 1000
+		if x > width && i > 0 {
+			return i
+		}
+	}
+	return len(text)
+}
+
+func textWidthOf(text string, font int, size float64) float64 {
+	w := 0.0
+	for _, r := range text {
+		w += charWidth(r, font)
+	}
+	return w * size / 1000
+}
+
+// widths of printable ascii in Helvetica and Helvetica-Bold from their font metrics,
+// oblique variants share them
+var (
+	helvetica = [95]float64{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBold = [95]float64{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+func charWidth(r rune, font int) float64 {
+	table := &helvetica
+	if font&1 != 0 {
+		table = &helveticaBold
+	}
+	if r >= 32 && r < 127 {
+		return table[r-32]
+	}
+	// letters with diacritics are as wide as their base letter
+	if b, ok := baseLetters[r]; ok {
+		return table[b-32]
+	}
+	return 556
+}
+
+// windows-1252 characters outside of latin-1
+var cp1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// baseLetters maps letters of central european languages that Windows-1252 lacks, and
+// latin-1 letters for width lookup, to letters without diacritics
+var baseLetters = func() map[rune]byte {
+	m := map[rune]byte{}
+	for base, letters := range map[byte]string{
+		'a': "áäàâãåąă", 'A': "ÁÄÀÂÃÅĄĂ", 'c': "čćç", 'C': "ČĆÇ", 'd': "ďđ", 'D': "ĎĐ",
+		'e': "éěëèêę", 'E': "ÉĚËÈÊĘ", 'i': "íïìî", 'I': "ÍÏÌÎ", 'l': "ľĺł", 'L': "ĽĹŁ",
+		'n': "ňńñ", 'N': "ŇŃÑ", 'o': "óöòôõőø", 'O': "ÓÖÒÔÕŐØ", 'r': "řŕ", 'R': "ŘŔ",
+		's': "šśş", 'S': "ŠŚŞ", 't': "ťţ", 'T': "ŤŢ", 'u': "úůüùûű", 'U': "ÚŮÜÙÛŰ",
+		'y': "ýÿ", 'Y': "ÝŸ", 'z': "žźż", 'Z': "ŽŹŻ",
+	} {
+		for _, r := range letters {
+			m[r] = base
+		}
+	}
+	return m
+}()
+
+// winAnsi encodes text to Windows-1252 used by standard fonts
+func winAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch b, ok := cp1252[r]; {
+		case ok:
+			out = append(out, b)
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case baseLetters[r] != 0:
+			out = append(out, baseLetters[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfString escapes literal string
+func pdfString(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n', '\r':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// pdfTextString encodes text outside of content streams as UTF-16 so any letter is kept
+func pdfTextString(text string) string {
+	var sb strings.Builder
+	sb.WriteString("<FEFF")
+	for _, c := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&sb, "%04X", c)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+var namedColors = map[string][3]float64{
+	"black": {0, 0, 0}, "white": {1, 1, 1}, "red": {1, 0, 0}, "green": {0, 0.5, 0},
+	"blue": {0, 0, 1}, "yellow": {1, 1, 0}, "orange": {1, 0.65, 0}, "purple": {0.5, 0, 0.5},
+	"gray": {0.5, 0.5, 0.5}, "grey": {0.5, 0.5, 0.5}, "brown": {0.65, 0.16, 0.16},
+}
+
+// pdfColor converts css color to rgb, only hex and basic named colors are understood,
+// anything else is black
+func pdfColor(css string) [3]float64 {
+	if c, ok := namedColors[strings.ToLower(css)]; ok {
+		return c
+	}
+
+	hex := strings.TrimPrefix(css, "#")
+	if len(hex) == len(css) {
+		return [3]float64{}
+	}
+	switch len(hex) {
+	case 3, 4:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6, 8:
+		hex = hex[:6]
+	default:
+		return [3]float64{}
+	}
+
+	var c [3]float64
+	for i := range c {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return [3]float64{}
+		}
+		c[i] = float64(v) / 255
+	}
+	return c
+}
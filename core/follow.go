@@ -0,0 +1,73 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// MaxFollows is amount of accounts and subjects one account can follow
+const MaxFollows = 500
+
+// kinds of followed sources
+const (
+	FollowAccount = "account"
+	FollowSubject = "subject"
+)
+
+// follow errors
+var (
+	ErrFollowSelf  = sterr.New("you cannot follow yourself")
+	ErrFollowLimit = sterr.New("account can follow at most %d accounts and subjects")
+	ErrCursor      = sterr.New("invalid cursor %s")
+)
+
+// Follow is source of notes in feed of Follower, Account is set when Kind is FollowAccount
+// and Subject when it is FollowSubject
+type Follow struct {
+	Follower ID
+	Kind     string
+
+	Account ID     `json:",omitempty"`
+	Subject string `json:",omitempty"`
+
+	BornDate int64
+}
+
+// Cursor points after last note of feed page, notes are ordered by PublishDate and ID
+// from the newest, zero Cursor is the first page
+type Cursor struct {
+	Date int64
+	ID   ID
+}
+
+// String encodes cursor for query parameter
+func (c Cursor) String() string {
+	return strconv.FormatInt(c.Date, 10) + "-" + strconv.FormatUint(c.ID, 10)
+}
+
+// ParseCursor decodes cursor made by Cursor.String, empty string is the first page
+func ParseCursor(raw string) (Cursor, error) {
+	if raw == "" {
+		return Cursor{}, nil
+	}
+
+	date, id, ok := strings.Cut(raw, "-")
+	if !ok {
+		return Cursor{}, ErrCursor.Args(raw)
+	}
+
+	var c Cursor
+	var err error
+	c.Date, err = strconv.ParseInt(date, 10, 64)
+	if err != nil || c.Date <= 0 {
+		return Cursor{}, ErrCursor.Args(raw)
+	}
+	c.ID, err = strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrCursor.Args(raw)
+	}
+
+	return c, nil
+}
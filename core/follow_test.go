@@ -0,0 +1,31 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCursor(t *testing.T) {
+	testCases := []struct {
+		desc, raw string
+		cursor    Cursor
+		err       error
+	}{
+		{desc: "first page"},
+		{desc: "valid", raw: "1650000000000-42", cursor: Cursor{Date: 1650000000000, ID: 42}},
+		{desc: "missing id", raw: "1650000000000", err: ErrCursor},
+		{desc: "negative date", raw: "-5-42", err: ErrCursor},
+		{desc: "garbage", raw: "abc-def", err: ErrCursor},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			c, err := ParseCursor(tC.raw)
+			if !errors.Is(err, tC.err) || c != tC.cursor {
+				t.Error(c, err)
+			}
+			if err == nil && tC.raw != "" && c.String() != tC.raw {
+				t.Error(c.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,839 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"myNotes/core"
+	"myNotes/core/attach"
+	"myNotes/core/export"
+	"myNotes/core/importer"
+	"myNotes/core/mongo"
+	"myNotes/core/ranking"
+	"myNotes/core/taxonomy"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// APIPrefix is prefix of all routes of versioned api
+const APIPrefix = "/api/v1"
+
+// MaxBodySize limits size of request bodies
+const MaxBodySize = 1 << 20
+
+// MaxUploadSize limits size of request bodies that upload files
+const MaxUploadSize = 16 << 20
+
+// errors specific to versioned api
+var (
+	ErrInvalidBody  = sterr.New("invalid request body")
+	ErrInvalidParam = sterr.New("invalid %s parameter")
+	ErrNoRoute      = sterr.New("no such route")
+)
+
+// APIError is error object of versioned api, Code is stable and clients should compare it
+// instead of Message, RequestID can be used to find the request in logs
+type APIError struct {
+	Code, Message string
+	RequestID     string `json:",omitempty"`
+}
+
+// ErrorCode maps error to machine readable code and http status
+type ErrorCode struct {
+	Err    error
+	Code   string
+	Status int
+}
+
+// ErrorCodes is checked in order so errors that wrap other errors go last, unknown errors
+// are reported as "internal"
+var ErrorCodes = []ErrorCode{
+	{ErrInvalidBody, "invalid_body", http.StatusBadRequest},
+	{ErrInvalidParam, "invalid_param", http.StatusBadRequest},
+	{export.ErrFormat, "invalid_format", http.StatusBadRequest},
+	{ErrNoRoute, "no_route", http.StatusNotFound},
+	{importer.ErrFormat, "unsupported_file", http.StatusBadRequest},
+	{importer.ErrEncoding, "invalid_encoding", http.StatusBadRequest},
+	{importer.ErrEmpty, "empty_file", http.StatusBadRequest},
+	{importer.ErrTooLarge, "file_too_large", http.StatusRequestEntityTooLarge},
+	{importer.ErrArchive, "invalid_archive", http.StatusBadRequest},
+	{importer.ErrHTML, "invalid_html", http.StatusBadRequest},
+	{importer.ErrTooManyFiles, "too_many_files", http.StatusBadRequest},
+	{attach.ErrType, "unsupported_type", http.StatusUnsupportedMediaType},
+	{attach.ErrTooLarge, "file_too_large", http.StatusRequestEntityTooLarge},
+	{attach.ErrQuota, "quota_exceeded", http.StatusRequestEntityTooLarge},
+	{attach.ErrImage, "image_too_large", http.StatusRequestEntityTooLarge},
+	{attach.ErrMissing, "not_found", http.StatusNotFound},
+	{ErrCSRF, "csrf_failed", http.StatusForbidden},
+	{ErrMethodNotAllowed, "method_not_allowed", http.StatusMethodNotAllowed},
+	{core.ErrInvalidTargetType, "invalid_target", http.StatusBadRequest},
+	{core.ErrInvalidScope, "invalid_scope", http.StatusBadRequest},
+	{core.ErrMissingScopes, "missing_scopes", http.StatusBadRequest},
+
+	{mongo.ErrNotFound, "not_found", http.StatusNotFound},
+	{mongo.ErrNoDocuments, "not_found", http.StatusNotFound},
+	{mongo.ErrNameTaken, "name_taken", http.StatusConflict},
+	{mongo.ErrEmailTaken, "email_taken", http.StatusConflict},
+	{mongo.ErrNotVerified, "not_verified", http.StatusForbidden},
+	{mongo.ErrInvalidLogin, "invalid_login", http.StatusUnauthorized},
+	{mongo.ErrNotAuthor, "not_author", http.StatusForbidden},
+	{mongo.ErrLimmitRate, "rate_limited", http.StatusTooManyRequests},
+
+	{ErrLocked, "locked", http.StatusTooManyRequests},
+	{ErrTooSoon, "too_soon", http.StatusTooManyRequests},
+	{ErrEmailLimit, "email_limit", http.StatusTooManyRequests},
+	{ErrNoRegistration, "registration_closed", http.StatusForbidden},
+	{ErrInvalidEmail, "invalid_email", http.StatusBadRequest},
+	{ErrEmailVerifFail, "email_check_failed", http.StatusBadGateway},
+	{ErrSendEmail, "email_send_failed", http.StatusBadGateway},
+	{ErrIncorrectCode, "incorrect_code", http.StatusBadRequest},
+	{ErrAlreadyVerified, "already_verified", http.StatusConflict},
+	{ErrIllegalNoteAccess, "not_author", http.StatusForbidden},
+	{ErrNotPublished, "not_published", http.StatusNotFound},
+	{ErrIllegalNotebookAccess, "not_owner", http.StatusForbidden},
+	{core.ErrTag, "invalid_tag", http.StatusBadRequest},
+	{core.ErrTooManyTags, "too_many_tags", http.StatusBadRequest},
+	{core.ErrTagQuery, "invalid_tag_query", http.StatusBadRequest},
+	{core.ErrNotebookCycle, "notebook_cycle", http.StatusConflict},
+	{core.ErrNotebookDepth, "notebook_too_deep", http.StatusConflict},
+	{core.ErrNotebookLimit, "notebook_limit", http.StatusConflict},
+	{ErrNotAdmin, "not_admin", http.StatusForbidden},
+	{core.ErrFollowSelf, "follow_self", http.StatusBadRequest},
+	{core.ErrFollowLimit, "follow_limit", http.StatusConflict},
+	{core.ErrCursor, "invalid_cursor", http.StatusBadRequest},
+	{core.ErrBookmarkLimit, "bookmark_limit", http.StatusConflict},
+	{core.ErrCollection, "invalid_collection", http.StatusBadRequest},
+	{ranking.ErrWindow, "invalid_window", http.StatusBadRequest},
+	{taxonomy.ErrKind, "unknown_kind", http.StatusBadRequest},
+	{taxonomy.ErrKey, "invalid_term", http.StatusBadRequest},
+	{taxonomy.ErrCode, "invalid_term", http.StatusBadRequest},
+	{taxonomy.ErrDuplicate, "term_conflict", http.StatusConflict},
+	{taxonomy.ErrParent, "unknown_parent", http.StatusConflict},
+	{taxonomy.ErrInUse, "term_in_use", http.StatusConflict},
+	{taxonomy.ErrUnknown, "unknown_term", http.StatusBadRequest},
+	{taxonomy.ErrNotTaught, "not_taught", http.StatusBadRequest},
+	{taxonomy.ErrTheme, "theme_mismatch", http.StatusBadRequest},
+	{ErrInvalidUserCookie, "unauthorized", http.StatusUnauthorized},
+	{ErrMissingUserCookie, "unauthorized", http.StatusUnauthorized},
+
+	{ErrSecondStep, "second_step_required", http.StatusUnauthorized},
+	{ErrInvalidCode, "invalid_code", http.StatusUnauthorized},
+	{ErrTOTPEnabled, "totp_enabled", http.StatusConflict},
+	{ErrTOTPDisabled, "totp_disabled", http.StatusConflict},
+	{ErrNotEnrolled, "totp_not_enrolled", http.StatusConflict},
+	{ErrMissingSession, "session_required", http.StatusUnauthorized},
+	{ErrInvalidSession, "session_invalid", http.StatusUnauthorized},
+	{ErrSessionMismatch, "session_invalid", http.StatusUnauthorized},
+
+	{ErrInvalidAuthorization, "invalid_authorization", http.StatusUnauthorized},
+	{ErrInvalidToken, "invalid_token", http.StatusUnauthorized},
+	{ErrMissingScope, "missing_scope", http.StatusForbidden},
+	{ErrTooManyTokens, "too_many_tokens", http.StatusConflict},
+
+	// wrappers
+	{ErrAccount, "account_rejected", http.StatusBadRequest},
+	{ErrInvalidLogin, "invalid_login", http.StatusUnauthorized},
+}
+
+// Code returns machine readable code and http status for an error
+func Code(err error) (string, int) {
+	for _, c := range ErrorCodes {
+		if errors.Is(err, c.Err) {
+			return c.Code, c.Status
+		}
+	}
+
+	return "internal", http.StatusInternalServerError
+}
+
+// NAPIError creates error object from error
+func NAPIError(err error) APIError {
+	code, _ := Code(err)
+	return APIError{Code: code, Message: err.Error()}
+}
+
+// APIFunc is handler of versioned api, returned value is encoded as json, nil value
+// means no content
+type APIFunc func(wr http.ResponseWriter, r *http.Request) (value interface{}, err error)
+
+// Route describes endpoint of versioned api, Query, Req and Resp are zero values of query
+// parameters, request body and responce body, Status is status of successful responce, 0
+// means 200 or 204 if there is no responce body
+type Route struct {
+	Method, Path, Summary string
+
+	Scope  core.Scope
+	Status int
+
+	Query, Req, Resp interface{}
+
+	Handler APIFunc
+}
+
+// Routes returns route table of versioned api
+func (w *WS) Routes() []Route {
+	return []Route{
+		// account
+		{"POST", "/accounts", "register new account", "", http.StatusCreated, nil, RegisterRequest{}, nil, w.APIRegister},
+		{"POST", "/accounts/verify", "verify account with emailed code", "", 0, nil, VerifyRequest{}, nil, w.APIVerify},
+		{"GET", "/accounts/{id}", "public account with follow counts", "", 0, nil, nil, PublicAccountBody{}, w.APIAccount},
+		{"GET", "/accounts/{id}/notes", "published notes of account", "", 0, nil, nil, DraftsBody{}, w.APIAccountNotes},
+		{"GET", "/accounts/{id}/notes/export/{format}", "published notes of account as html, md or pdf file, " + export.PDFCharset, "", 0, nil, nil, File{}, w.APIExportAccountNotes},
+		{"POST", "/session", "login, sets authentication cookies", "", 0, nil, LoginReqest{}, nil, w.APILogin},
+		{"GET", "/me", "authenticated account", core.ReadS, 0, nil, nil, SelfAccount{}, w.APIMe},
+		{"PATCH", "/me", "rename account or change colors", core.AccountS, 0, nil, ConfigBody{}, SelfAccount{}, w.APIConfigure},
+		{"GET", "/me/notes", "all notes of authenticated account", core.ReadS, 0, nil, nil, DraftsBody{}, w.APIMyNotes},
+		{"GET", "/me/notes/export/{format}", "all notes of authenticated account as html, md or pdf file, " + export.PDFCharset, core.ReadS, 0, nil, nil, File{}, w.APIExportMyNotes},
+		{"POST", ImportPath, "import md, html, txt or zip files as draft notes", core.NotesS, 0, nil, Upload{}, ImportBody{}, w.APIImportNotes},
+		{"GET", AttachmentsPath, "attachments of authenticated account and used quota", core.ReadS, 0, nil, nil, AttachmentsBody{}, w.APIAttachments},
+		{"POST", AttachmentsPath, "upload files that notes can embed with <embed:ID> tag", core.NotesS, http.StatusCreated, nil, Upload{}, AttachmentsBody{}, w.APIUploadAttachments},
+		{"DELETE", AttachmentsPath + "/{attachment}", "delete attachment", core.NotesS, 0, nil, nil, nil, w.APIDeleteAttachment},
+		{"POST", "/me/totp", "start two factor enrolment", core.AccountS, 0, nil, nil, EnrollBody{}, w.APIEnrollTOTP},
+		{"POST", "/me/totp/confirm", "enable two factor authentication", core.AccountS, 0, nil, CodeRequest{}, RecoveryBody{}, w.APIConfirmTOTP},
+		{"DELETE", "/me/totp", "disable two factor authentication", core.AccountS, 0, nil, CodeRequest{}, nil, w.APIDisableTOTP},
+		{"GET", "/me/tokens", "api tokens of authenticated account", core.AccountS, 0, nil, nil, TokensBody{}, w.APITokens},
+		{"POST", "/me/tokens", "create api token", core.AccountS, http.StatusCreated, nil, NewTokenBody{}, CreatedTokenBody{}, w.APICreateToken},
+		{"DELETE", "/me/tokens/{id}", "revoke api token", core.AccountS, 0, nil, nil, nil, w.APIRevokeToken},
+		// notebook
+		{"GET", "/me/notebooks", "root notebooks and notes of authenticated account", core.ReadS, 0, nil, nil, ShelfBody{}, w.APIMyNotebooks},
+		{"POST", "/me/notebooks", "create notebook", core.NotesS, http.StatusCreated, nil, NotebookBody{}, IDBody{}, w.APICreateNotebook},
+		{"GET", "/notebooks/{id}", "contents of published notebook or own notebook", "", 0, nil, nil, ShelfBody{}, w.APINotebook},
+		{"PATCH", "/notebooks/{id}", "rename, move or publish own notebook", core.NotesS, 0, nil, NotebookBody{}, core.Notebook{}, w.APIUpdateNotebook},
+		{"DELETE", "/notebooks/{id}", "delete own notebook, its contents are moved to its parent", core.NotesS, 0, nil, nil, nil, w.APIDeleteNotebook},
+		{"PUT", "/notes/{id}/notebook", "move own note to own notebook, 0 is the root", core.NotesS, 0, nil, MoveBody{}, nil, w.APIMoveNote},
+		// note
+		{"GET", "/notes", "search published notes", "", 0, core.SearchRequest{}, nil, SearchBody{}, w.APISearch},
+		{"POST", "/notes", "create note", core.NotesS, http.StatusCreated, nil, NoteBody{}, IDBody{}, w.APICreateNote},
+		{"GET", "/notes/{id}", "published note or own note", "", 0, nil, nil, core.Note{}, w.APINote},
+		{"GET", "/notes/{id}/export/{format}", "published note or own note as html, md or pdf file, " + export.PDFCharset, "", 0, nil, nil, File{}, w.APIExportNote},
+		{"PATCH", "/notes/{id}", "update own note", core.NotesS, 0, nil, NoteBody{}, core.Note{}, w.APIUpdateNote},
+		{"DELETE", "/notes/{id}", "delete own note", core.NotesS, 0, nil, nil, nil, w.APIDeleteNote},
+		{"POST", "/notes/{id}/comments", "comment a note", core.CommentsS, http.StatusCreated, nil, CommentBody{}, IDBody{}, w.APICommentNote},
+		{"POST", "/comments/{id}/comments", "reply to comment", core.CommentsS, http.StatusCreated, nil, CommentBody{}, IDBody{}, w.APIReply},
+		// tags
+		{"GET", "/tags", "most used tags of published notes for tag cloud", "", 0, LimitQuery{}, nil, TagsBody{}, w.APITags},
+		{"GET", "/tags/autocomplete", "used tags starting with query parameter q", "", 0, CompleteQuery{}, nil, TagsBody{}, w.APICompleteTags},
+		// follows
+		{"GET", "/feed", "published notes of followed accounts and subjects from the newest, query parameters cursor and limit page it", core.ReadS, 0, PageQuery{}, nil, FeedBody{}, w.APIFeed},
+		{"GET", "/me/follows", "accounts and subjects authenticated account follows", core.ReadS, 0, nil, nil, FollowsBody{}, w.APIFollows},
+		{"PUT", "/me/follows/accounts/{id}", "follow account", core.CommentsS, 0, nil, nil, nil, w.APIFollowAccount},
+		{"DELETE", "/me/follows/accounts/{id}", "unfollow account", core.CommentsS, 0, nil, nil, nil, w.APIFollowAccount},
+		{"PUT", "/me/follows/subjects/{subject}", "follow subject", core.CommentsS, 0, nil, nil, nil, w.APIFollowSubject},
+		{"DELETE", "/me/follows/subjects/{subject}", "unfollow subject", core.CommentsS, 0, nil, nil, nil, w.APIFollowSubject},
+		// bookmarks
+		{"GET", "/me/bookmarks", "bookmarks of authenticated account from the newest, query parameters collection, cursor and limit filter and page them", core.ReadS, 0, BookmarksQuery{}, nil, BookmarksBody{}, w.APIBookmarks},
+		{"GET", "/me/bookmarks/collections", "bookmark collections of authenticated account", core.ReadS, 0, nil, nil, CollectionsBody{}, w.APIBookmarkCollections},
+		{"PUT", "/notes/{id}/bookmark", "bookmark published note, optionally into collection", core.CommentsS, 0, nil, BookmarkBody{}, nil, w.APIBookmark},
+		{"DELETE", "/notes/{id}/bookmark", "remove bookmark", core.CommentsS, 0, nil, nil, nil, w.APIBookmark},
+		// taxonomy
+		{"GET", "/taxonomy", "schools, subjects and themes, query parameter kind limits them to one kind", "", 0, TaxonomyQuery{}, nil, TaxonomyBody{}, w.APITaxonomy},
+		{"PUT", "/admin/taxonomy/{kind}/{key}", "add or replace term, only for taxonomy admins", core.AccountS, 0, nil, TermBody{}, taxonomy.Term{}, w.APIPutTerm},
+		{"DELETE", "/admin/taxonomy/{kind}/{key}", "delete term that nothing uses, only for taxonomy admins", core.AccountS, 0, nil, nil, nil, w.APIDeleteTerm},
+		{"POST", "/admin/taxonomy/migrate", "map free text subjects and themes of notes onto terms, query parameter dry_run only reports", core.AccountS, 0, MigrateQuery{}, nil, taxonomy.Report{}, w.APIMigrateTaxonomy},
+		// rankings
+		{"GET", "/notes/top", "published notes with highest score of likes, comments and views, query parameters school, subject, window and limit filter them", "", 0, RankingQuery{}, nil, RankingBody{}, w.APITopNotes},
+		{"GET", "/notes/trending", "published notes with highest score decayed by age, query parameters school, subject, window and limit filter them", "", 0, RankingQuery{}, nil, RankingBody{}, w.APITrendingNotes},
+		// likes
+		{"GET", "/notes/{id}/like", "like state of note", core.ReadS, 0, nil, nil, LikeBody{}, w.APINoteLike},
+		{"PUT", "/notes/{id}/like", "like note", core.CommentsS, 0, nil, nil, LikeBody{}, w.APINoteLike},
+		{"DELETE", "/notes/{id}/like", "remove like from note", core.CommentsS, 0, nil, nil, LikeBody{}, w.APINoteLike},
+		{"GET", "/comments/{id}/like", "like state of comment", core.ReadS, 0, nil, nil, LikeBody{}, w.APICommentLike},
+		{"PUT", "/comments/{id}/like", "like comment", core.CommentsS, 0, nil, nil, LikeBody{}, w.APICommentLike},
+		{"DELETE", "/comments/{id}/like", "remove like from comment", core.CommentsS, 0, nil, nil, LikeBody{}, w.APICommentLike},
+	}
+}
+
+// RegisterAPI registers all routes of versioned api to the WS mux, scope of route is
+// required before its handler runs
+func (w *WS) RegisterAPI() {
+	for _, rt := range w.Routes() {
+		w.mux.Handle(rt.Method+" "+APIPrefix+rt.Path, Chain(w.API(rt.Status, rt.Handler), w.Authenticate, RequireScope(rt.Scope)))
+	}
+
+	if w.cfg.Features.OpenAPI {
+		w.mux.HandleFunc("GET "+OpenAPIPath, w.ServeOpenAPI)
+	}
+
+	w.mux.Handle(APIPrefix+"/", w.API(0, func(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+		return nil, ErrNoRoute
+	}))
+}
+
+// WriteError responds with error object of versioned api and status matching the error
+func WriteError(wr http.ResponseWriter, r *http.Request, err error) {
+	_, status := Code(err)
+	body := ErrorBody{Error: NAPIError(err)}
+	body.Error.RequestID = RequestIDFrom(r)
+
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	json.NewEncoder(wr).Encode(body)
+}
+
+// API adapts APIFunc to http handler, status is used for successful responces
+func (w *WS) API(status int, f APIFunc) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		status := status
+		value, err := f(wr, r)
+		if err != nil {
+			WriteError(wr, r, err)
+			return
+		}
+
+		if value == nil {
+			if status == 0 {
+				status = http.StatusNoContent
+			}
+			wr.WriteHeader(status)
+			return
+		}
+
+		if f, ok := value.(File); ok {
+			wr.Header().Set("Content-Type", f.Type)
+			wr.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+			wr.Write(f.Data)
+			return
+		}
+
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		wr.WriteHeader(status)
+		json.NewEncoder(wr).Encode(value)
+	})
+}
+
+// Decode decodes json body of request, unknown fields are rejected
+func Decode(r *http.Request, value interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(value)
+	if err != nil {
+		return ErrInvalidBody.Wrap(err)
+	}
+	return nil
+}
+
+// PathID parses id from path parameter
+func PathID(r *http.Request) (core.ID, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidParam.Args("id")
+	}
+	return id, nil
+}
+
+// QueryLimit parses query parameter limit of listing, def is used when it is missing and
+// it has to be between 1 and max
+func QueryLimit(r *http.Request, def, max int) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return def, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 || v > max {
+		return 0, ErrInvalidParam.Args("limit")
+	}
+	return v, nil
+}
+
+// APIRegister ...
+func (w *WS) APIRegister(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req RegisterRequest
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.register(req)
+}
+
+// APIVerify ...
+func (w *WS) APIVerify(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req VerifyRequest
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.verify(r, req)
+}
+
+// APILogin ...
+func (w *WS) APILogin(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req LoginReqest
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.login(wr, r, req)
+}
+
+// APIAccount ...
+func (w *WS) APIAccount(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := w.db.AccountByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ac.Censure()
+
+	followers, following, err := w.db.FollowCounts(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return PublicAccountBody{Account: ac, Followers: followers, Following: following}, nil
+}
+
+// APIAccountNotes ...
+func (w *WS) APIAccountNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var nts []core.Draft
+	err = w.db.UserNotes(id, &nts)
+	if err != nil {
+		return nil, err
+	}
+
+	published := []core.Draft{}
+	for _, nt := range nts {
+		if nt.Published {
+			published = append(published, nt)
+		}
+	}
+
+	return DraftsBody{Drafts: published}, nil
+}
+
+// APIMe ...
+func (w *WS) APIMe(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	return NSelfAccount(ac), nil
+}
+
+// APIConfigure ...
+func (w *WS) APIConfigure(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req ConfigBody
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	name, colors := ac.Name, ac.Cfg.Colors
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Colors != nil {
+		colors = req.Colors
+	}
+
+	err = w.configure(wr, ac, name, colors)
+	if err != nil {
+		return nil, err
+	}
+
+	ac.Name, ac.Cfg.Colors = name, colors
+
+	return NSelfAccount(ac), nil
+}
+
+// APIMyNotes ...
+func (w *WS) APIMyNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	nts := []core.Draft{}
+	err = w.db.UserNotes(ac.ID, &nts)
+	if err != nil {
+		return nil, err
+	}
+
+	return DraftsBody{Drafts: nts}, nil
+}
+
+// APIEnrollTOTP ...
+func (w *WS) APIEnrollTOTP(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, uri, err := w.enrollTOTP(ac)
+	if err != nil {
+		return nil, err
+	}
+
+	return EnrollBody{URI: uri, Secret: secret}, nil
+}
+
+// APIConfirmTOTP ...
+func (w *WS) APIConfirmTOTP(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req CodeRequest
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := w.confirmTOTP(wr, ac, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return RecoveryBody{Codes: codes}, nil
+}
+
+// APIDisableTOTP ...
+func (w *WS) APIDisableTOTP(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req CodeRequest
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.disableTOTP(ac, req.Code)
+}
+
+// APITokens ...
+func (w *WS) APITokens(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := w.db.UserTokens(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return TokensBody{Tokens: ts}, nil
+}
+
+// APICreateToken ...
+func (w *WS) APICreateToken(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req NewTokenBody
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := core.ParseScopes(strings.Join(req.Scopes, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	value, tk, err := w.createToken(ac, req.Name, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreatedTokenBody{Token: value, Info: tk}, nil
+}
+
+// APIRevokeToken ...
+func (w *WS) APIRevokeToken(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.db.RevokeToken(ac.ID, id)
+}
+
+// APISearch ...
+func (w *WS) APISearch(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	req := core.SearchRequest{
+		Name:    q.Get("name"),
+		School:  q.Get("school"),
+		Theme:   q.Get("theme"),
+		Author:  q.Get("author"),
+		Subject: q.Get("subject"),
+		Tags:    q.Get("tags"),
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"year", &req.Year},
+		{"month", &req.Month},
+	} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, ErrInvalidParam.Args(p.name)
+			}
+			*p.value = v
+		}
+	}
+
+	res, err := w.db.SearchNote(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return SearchBody{Results: res}, nil
+}
+
+// APICreateNote ...
+func (w *WS) APICreateNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req NoteBody
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	var nt core.Note
+	err = req.Apply(&nt, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	nt.Tags, err = core.NormalizeTags(nt.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.createNote(ac, &nt)
+	if err != nil {
+		return nil, err
+	}
+
+	return IDBody{ID: nt.ID}, nil
+}
+
+// APINote ...
+func (w *WS) APINote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := w.visibleNote(r, id)
+	if err != nil {
+		return nil, err
+	}
+	w.countView(r, nt)
+
+	return nt, nil
+}
+
+// visibleNote returns note if it is published or request is made by its author
+func (w *WS) visibleNote(r *http.Request, id core.ID) (core.Note, error) {
+	nt, err := w.db.NoteByID(id)
+	if err != nil {
+		return nt, err
+	}
+
+	if !nt.Published {
+		// unpublished notes look like missing ones to anybody except author
+		ac, err := AccountFrom(r, core.ReadS)
+		if err != nil || ac.ID != nt.Author {
+			return core.Note{}, ErrNotPublished
+		}
+	}
+
+	return nt, nil
+}
+
+// APIUpdateNote ...
+func (w *WS) APIUpdateNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req NoteBody
+	err = Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := w.authorNote(ac, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	err = req.Apply(&nt, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	nt.Tags, err = core.NormalizeTags(nt.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.db.UpdateNote(&nt)
+	if err != nil {
+		return nil, err
+	}
+
+	return nt, nil
+}
+
+// APIDeleteNote ...
+func (w *WS) APIDeleteNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.authorNote(ac, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.db.DeleteNote(id)
+}
+
+// APICommentNote ...
+func (w *WS) APICommentNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.apiComment(wr, r, core.NoteT)
+}
+
+// APIReply ...
+func (w *WS) APIReply(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.apiComment(wr, r, core.CommentT)
+}
+
+func (w *WS) apiComment(wr http.ResponseWriter, r *http.Request, tp core.TargetType) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req CommentBody
+	err = Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.CommentsS)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := w.comment(ac, core.Target{Type: tp, ID: id}, req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	return IDBody{ID: cm.ID}, nil
+}
+
+// APINoteLike reads like state of note on GET, likes on PUT and removes like on DELETE
+func (w *WS) APINoteLike(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.apiLike(r, core.NoteT)
+}
+
+// APICommentLike is APINoteLike for comments
+func (w *WS) APICommentLike(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.apiLike(r, core.CommentT)
+}
+
+func (w *WS) apiLike(r *http.Request, tp core.TargetType) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := core.CommentsS
+	if r.Method == http.MethodGet {
+		scope = core.ReadS
+	}
+
+	ac, err := AccountFrom(r, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := w.db.Coll(tp)
+	if r.Method == http.MethodGet {
+		state, count, err := w.db.Like(id, ac.ID, coll, false)
+		if err != nil {
+			return nil, err
+		}
+		return LikeBody{State: state, Count: count}, nil
+	}
+
+	state := r.Method == http.MethodPut
+	count, err := w.db.SetLike(id, ac.ID, coll, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return LikeBody{State: state, Count: count}, nil
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"myNotes/core"
+	"myNotes/core/mongo"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{mongo.ErrNotFound.Args("note", "id"), "not_found", http.StatusNotFound},
+		{core.EI(mongo.ErrNoDocuments), "not_found", http.StatusNotFound},
+		{ErrInvalidLogin.Wrap(ErrSecondStep), "second_step_required", http.StatusUnauthorized},
+		{ErrInvalidLogin.Wrap(mongo.ErrInvalidLogin), "invalid_login", http.StatusUnauthorized},
+		{ErrAccount.Wrap(mongo.ErrNameTaken), "name_taken", http.StatusConflict},
+		{ErrMissingScope.Args(core.NotesS), "missing_scope", http.StatusForbidden},
+		{core.ErrImpossible, "internal", http.StatusInternalServerError},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.code, func(t *testing.T) {
+			code, status := Code(tC.err)
+			if code != tC.code || status != tC.status {
+				t.Error(code, status, tC.code, tC.status)
+			}
+		})
+	}
+}
+
+func TestAPINote(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	handler := ws.Handler()
+
+	ac := MakeVerifiedAccount(db)
+	other := core.Account{Name: "other", Password: "password", Email: "other@gmail.com"}
+	db.Account(&other)
+	db.MakeAccountVerified(other.ID)
+
+	nt := core.Note{Author: ac.ID, Name: "note", Content: "<b>content<b>"}
+	db.Note(&nt)
+	path := APIPrefix + "/notes/" + strconv.FormatUint(nt.ID, 10)
+
+	testCases := []struct {
+		desc, method, body string
+		cookie             *http.Cookie
+		status             int
+		code               string
+	}{
+		{desc: "unpublished", method: "GET", status: http.StatusNotFound, code: "not_published"},
+		{desc: "author", method: "GET", cookie: cookie(ac), status: http.StatusOK},
+		{desc: "unknown field", method: "PATCH", body: `{"Author":1}`, cookie: cookie(ac), status: http.StatusBadRequest, code: "invalid_body"},
+		{desc: "not author", method: "PATCH", body: `{"Published":true}`, cookie: cookie(other), status: http.StatusForbidden, code: "not_author"},
+		{desc: "publish", method: "PATCH", body: `{"Published":true}`, cookie: cookie(ac), status: http.StatusOK},
+		{desc: "published", method: "GET", status: http.StatusOK},
+		{desc: "delete", method: "DELETE", cookie: cookie(ac), status: http.StatusNoContent},
+		{desc: "deleted", method: "GET", status: http.StatusNotFound, code: "not_found"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest(tC.method, path, strings.NewReader(tC.body))
+			if tC.cookie != nil {
+				req.AddCookie(tC.cookie)
+			}
+			withCSRF(req)
+
+			rc := httptest.NewRecorder()
+			handler.ServeHTTP(rc, req)
+			if rc.Code != tC.status {
+				t.Error(rc.Code, tC.status, rc.Body.String())
+			}
+
+			if tC.code == "" {
+				return
+			}
+
+			var body ErrorBody
+			json.Unmarshal(rc.Body.Bytes(), &body)
+			if body.Error.Code != tC.code {
+				t.Error(body.Error, tC.code)
+			}
+		})
+	}
+}
+
+func TestAPIMe(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	ac := MakeVerifiedAccount(db)
+
+	req := httptest.NewRequest("GET", APIPrefix+"/me", nil)
+	req.AddCookie(cookie(ac))
+	rc := httptest.NewRecorder()
+	ws.Handler().ServeHTTP(rc, req)
+
+	var body map[string]interface{}
+	json.Unmarshal(rc.Body.Bytes(), &body)
+	if rc.Code != http.StatusOK || body["Name"] != ac.Name {
+		t.Fatal(rc.Code, rc.Body.String())
+	}
+	for _, field := range []string{"Password", "Code"} {
+		if _, ok := body[field]; ok {
+			t.Error(field, "is exposed")
+		}
+	}
+}
+
+func TestQueryLimit(t *testing.T) {
+	testCases := []struct {
+		desc, query string
+		expected    int
+		err         error
+	}{
+		{desc: "default", query: "", expected: 20},
+		{desc: "valid", query: "?limit=5", expected: 5},
+		{desc: "max", query: "?limit=100", expected: 100},
+		{desc: "zero", query: "?limit=0", err: ErrInvalidParam},
+		{desc: "negative", query: "?limit=-1", err: ErrInvalidParam},
+		{desc: "large", query: "?limit=101", err: ErrInvalidParam},
+		{desc: "not number", query: "?limit=many", err: ErrInvalidParam},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			limit, err := QueryLimit(httptest.NewRequest("GET", "/"+tC.query, nil), 20, 100)
+			if !errors.Is(err, tC.err) || limit != tC.expected {
+				t.Error(limit, err)
+			}
+		})
+	}
+}
+
+// getJSON serves GET request of account and decodes its body, nil account is anonymous
+func getJSON(t *testing.T, handler http.Handler, path string, ac *core.Account, body interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	if ac != nil {
+		req.AddCookie(cookie(*ac))
+	}
+
+	rc := httptest.NewRecorder()
+	handler.ServeHTTP(rc, req)
+	if rc.Code != http.StatusOK {
+		t.Fatal(rc.Code, rc.Body.String())
+	}
+	if err := json.Unmarshal(rc.Body.Bytes(), body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouteScopes(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+	handler := ws.Handler()
+
+	for _, rt := range ws.Routes() {
+		if rt.Scope == "" {
+			continue
+		}
+		t.Run(rt.Method+" "+rt.Path, func(t *testing.T) {
+			req := httptest.NewRequest(rt.Method, APIPrefix+pathParam.ReplaceAllString(rt.Path, "1"), nil)
+			withCSRF(req)
+
+			rc := httptest.NewRecorder()
+			handler.ServeHTTP(rc, req)
+			if rc.Code != http.StatusUnauthorized {
+				t.Error(rc.Code, rc.Body.String())
+			}
+		})
+	}
+}
+
+func cookie(ac core.Account) *http.Cookie {
+	c := ac.Cookie()
+	return &c
+}
@@ -0,0 +1,285 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"myNotes/core"
+	"myNotes/web"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImmutableCache is Cache-Control of assets requested by hashed url, content behind
+// such url never changes
+const ImmutableCache = "public, max-age=31536000, immutable"
+
+var assetLink = regexp.MustCompile(`(src|href)="([^"?#:]+)"`)
+
+// Assets serves frontend files from memory, every file is reachable by its name and by
+// name with content hash (general.js and general.1a2b3c4d5e.js), pages link hashed names
+// so browsers can cache them forever, other files are revalidated with ETag
+type Assets struct {
+	files map[string]*asset
+}
+
+type asset struct {
+	name, hash      string
+	data, gzip, br  []byte
+	page, immutable bool
+}
+
+// NAssets loads all files of fsys, compress enables gzip variants, brotli variants are
+// taken from files with .br suffix
+func NAssets(fsys fs.FS, compress bool) (*Assets, error) {
+	a := &Assets{files: map[string]*asset{}}
+
+	var pages []*asset
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) == ".go" || path.Ext(name) == ".br" {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		f := &asset{name: name, hash: hex.EncodeToString(sum[:5]), data: data}
+		a.files[name] = f
+
+		// pages carry nonce of the request so they can be neither cached nor precompressed
+		if path.Ext(name) == ".html" {
+			f.page = true
+			pages = append(pages, f)
+			return nil
+		}
+
+		if br, err := fs.ReadFile(fsys, name+".br"); err == nil {
+			f.br = br
+		}
+		if compress {
+			f.gzip = gzipped(data)
+		}
+
+		hashed := *f
+		hashed.immutable = true
+		a.files[hashedName(name, f.hash)] = &hashed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// links are rewritten once pages know hashes of everything else
+	for _, p := range pages {
+		p.data = assetLink.ReplaceAllFunc(p.data, func(m []byte) []byte {
+			sm := assetLink.FindSubmatch(m)
+			link := string(sm[2])
+			f, ok := a.files[path.Join(path.Dir(p.name), link)]
+			if !ok || f.page {
+				return m
+			}
+			return []byte(string(sm[1]) + `="` + hashedName(link, f.hash) + `"`)
+		})
+	}
+
+	return a, nil
+}
+
+// AssetServer serves frontend files and resolves hashed urls of them for server rendered
+// pages
+type AssetServer interface {
+	http.Handler
+	URL(name string) string
+}
+
+// DevAssets serves files of dir without compression, files are reloaded when any of them
+// changes so changes show up without restart
+func DevAssets(dir string) AssetServer {
+	return &devAssets{fsys: os.DirFS(dir)}
+}
+
+type devAssets struct {
+	fsys fs.FS
+
+	mu     sync.Mutex
+	stamp  string
+	assets *Assets
+}
+
+func (d *devAssets) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
+	a, err := d.load()
+	if err != nil {
+		WriteError(wr, r, core.EI(err))
+		return
+	}
+	a.ServeHTTP(wr, r)
+}
+
+// URL returns hashed url of asset from current files, name is returned unchanged when
+// they can not be loaded
+func (d *devAssets) URL(name string) string {
+	a, err := d.load()
+	if err != nil {
+		return name
+	}
+	return a.URL(name)
+}
+
+// load returns assets, they are rebuilt only if names, sizes or modification times of
+// files changed since last build
+func (d *devAssets) load() (*Assets, error) {
+	stamp, err := filesStamp(d.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.assets == nil || stamp != d.stamp {
+		a, err := NAssets(d.fsys, false)
+		if err != nil {
+			return nil, err
+		}
+		d.assets, d.stamp = a, stamp
+	}
+
+	return d.assets, nil
+}
+
+// filesStamp hashes names, sizes and modification times of all files, it does not read
+// their content
+func filesStamp(fsys fs.FS) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+// pages returns handler of frontend files, embedded ones are used when dir is empty
+func pages(dir string) AssetServer {
+	if dir != "" {
+		return DevAssets(dir)
+	}
+
+	a, err := NAssets(web.FS, true)
+	if err != nil {
+		// embedded files are part of the binary so this can not happen
+		panic(err)
+	}
+	return a
+}
+
+// ServeHTTP serves asset by url path, directories resolve to their index.html, pages get
+// nonce of the request on every script tag so policy allows them
+func (a *Assets) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+
+	f, ok := a.files[name]
+	if !ok {
+		http.NotFound(wr, r)
+		return
+	}
+
+	h := wr.Header()
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+
+	if f.page {
+		page := f.data
+		if nonce := NonceFrom(r); nonce != "" {
+			page = bytes.ReplaceAll(page, []byte("<script"), []byte(`<script nonce="`+nonce+`"`))
+		}
+
+		// nonce differs with each responce so page can not be reused
+		h.Set("Cache-Control", "no-store")
+		http.ServeContent(wr, r, name, time.Time{}, bytes.NewReader(page))
+		return
+	}
+
+	if f.immutable {
+		h.Set("Cache-Control", ImmutableCache)
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	h.Add("Vary", "Accept-Encoding")
+
+	// each encoding is different representation so it needs its own tag
+	data, etag := f.data, f.hash
+	accept := r.Header.Get("Accept-Encoding")
+	switch {
+	case f.br != nil && accepts(accept, "br"):
+		data, etag = f.br, etag+"-br"
+		h.Set("Content-Encoding", "br")
+	case f.gzip != nil && accepts(accept, "gzip"):
+		data, etag = f.gzip, etag+"-gz"
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("ETag", `"`+etag+`"`)
+
+	http.ServeContent(wr, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// URL returns hashed url of asset, name is returned unchanged when there is no such asset
+func (a *Assets) URL(name string) string {
+	f, ok := a.files[strings.TrimPrefix(name, "/")]
+	if !ok || f.page {
+		return name
+	}
+	return hashedName(name, f.hash)
+}
+
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return name[:len(name)-len(ext)] + "." + hash + ext
+}
+
+// gzipped returns compressed data or nil when compression does not pay off
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() >= len(data)*9/10 {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// accepts reports whether Accept-Encoding header allows encoding
+func accepts(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
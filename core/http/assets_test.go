@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"myNotes/web"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestAssets(t *testing.T) {
+	js := []byte(strings.Repeat("console.log('hello')\n", 50))
+	files := fstest.MapFS{
+		"index.html":        {Data: []byte(`<link href="stiles/a.css"><script src="tools/a.js"></script><a href="view.html?id=1">`)},
+		"tools/a.js":        {Data: js},
+		"tools/a.js.br":     {Data: []byte("brotli")},
+		"stiles/a.css":      {Data: []byte("body{}")},
+		"assets/image.png":  {Data: []byte{0x89, 'P', 'N', 'G'}},
+		"components/x.html": {Data: []byte(`<div></div>`)},
+		"embed.go":          {Data: []byte("package web")},
+	}
+	a, err := NAssets(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := a.URL("tools/a.js")
+
+	testCases := []struct {
+		desc, path, encoding, etag string
+		status                     int
+		cache, contentEncoding     string
+		body                       []byte
+	}{
+		{desc: "plain name", path: "/tools/a.js", status: http.StatusOK, cache: "no-cache", body: js},
+		{desc: "hashed name", path: "/" + hashed, status: http.StatusOK, cache: ImmutableCache, body: js},
+		{desc: "gzip", path: "/" + hashed, encoding: "gzip, deflate", status: http.StatusOK, cache: ImmutableCache, contentEncoding: "gzip", body: js},
+		{desc: "brotli preferred", path: "/tools/a.js", encoding: "gzip, br", status: http.StatusOK, cache: "no-cache", contentEncoding: "br", body: []byte("brotli")},
+		{desc: "refused encoding", path: "/tools/a.js", encoding: "gzip;q=0, br; q=0", status: http.StatusOK, cache: "no-cache", body: js},
+		{desc: "not worth compressing", path: "/assets/image.png", encoding: "gzip", status: http.StatusOK, cache: "no-cache", body: files["assets/image.png"].Data},
+		{desc: "not modified", path: "/tools/a.js", etag: `"` + strings.Split(hashed, ".")[1] + `"`, status: http.StatusNotModified, cache: "no-cache"},
+		{desc: "other encoding modified", path: "/tools/a.js", encoding: "gzip", etag: `"` + strings.Split(hashed, ".")[1] + `"`, status: http.StatusOK, cache: "no-cache", contentEncoding: "gzip", body: js},
+		{desc: "sources hidden", path: "/embed.go", status: http.StatusNotFound},
+		{desc: "brotli file hidden", path: "/tools/a.js.br", status: http.StatusNotFound},
+		{desc: "missing", path: "/nope.js", status: http.StatusNotFound},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tC.path, nil)
+			req.Header.Set("Accept-Encoding", tC.encoding)
+			req.Header.Set("If-None-Match", tC.etag)
+
+			rc := httptest.NewRecorder()
+			a.ServeHTTP(rc, req)
+			if rc.Code != tC.status {
+				t.Fatal(rc.Code, tC.status)
+			}
+			if tC.status == http.StatusNotFound {
+				return
+			}
+
+			h := rc.Header()
+			if h.Get("Cache-Control") != tC.cache || h.Get("Content-Encoding") != tC.contentEncoding || h.Get("ETag") == "" {
+				t.Fatal(h)
+			}
+
+			body := rc.Body.Bytes()
+			if tC.contentEncoding == "gzip" {
+				zr, err := gzip.NewReader(rc.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ = io.ReadAll(zr)
+			}
+			if !bytes.Equal(body, tC.body) {
+				t.Error(string(body))
+			}
+		})
+	}
+}
+
+func TestAssetsPages(t *testing.T) {
+	a, err := NAssets(fstest.MapFS{
+		"index.html":      {Data: []byte(`<link href="stiles/a.css"><script src="a.js"></script><a href="view.html?id=1"><img src="//remote/x.png">`)},
+		"components/x.js": {Data: []byte(`x`)},
+		"components/p.html": {
+			Data: []byte(`<script src="x.js"></script>`),
+		},
+		"a.js":         {Data: []byte(`a`)},
+		"stiles/a.css": {Data: []byte(`body{}`)},
+		"view.html":    {Data: []byte(``)},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc, path, body string
+	}{
+		{desc: "index", path: "/", body: `<link href="` + a.URL("stiles/a.css") + `"><script src="` + a.URL("a.js") + `"></script><a href="view.html?id=1"><img src="//remote/x.png">`},
+		{desc: "relative to page", path: "/components/p.html", body: `<script src="` + strings.TrimPrefix(a.URL("components/x.js"), "components/") + `"></script>`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			a.ServeHTTP(rc, httptest.NewRequest("GET", tC.path, nil))
+			if rc.Code != http.StatusOK || rc.Header().Get("Cache-Control") != "no-store" || rc.Body.String() != tC.body {
+				t.Error(rc.Code, rc.Header(), rc.Body.String())
+			}
+			if a.URL("a.js") == "a.js" {
+				t.Error("asset is not hashed")
+			}
+		})
+	}
+}
+
+func TestAssetsEmbedded(t *testing.T) {
+	a, err := NAssets(web.FS, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := httptest.NewRecorder()
+	a.ServeHTTP(rc, httptest.NewRequest("GET", "/", nil))
+	if rc.Code != http.StatusOK || !strings.Contains(rc.Body.String(), a.URL("tools/general.js")) {
+		t.Error(rc.Code, rc.Body.String())
+	}
+}
+
+func TestDevAssets(t *testing.T) {
+	files := fstest.MapFS{"a.js": {Data: []byte("old"), ModTime: time.Unix(1, 0)}}
+	d := &devAssets{fsys: files}
+
+	first, err := d.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := d.load(); same != first {
+		t.Error("unchanged files are rebuilt")
+	}
+
+	files["a.js"] = &fstest.MapFile{Data: []byte("new"), ModTime: time.Unix(2, 0)}
+	changed, err := d.load()
+	if err != nil || changed == first || string(changed.files["a.js"].data) != "new" {
+		t.Error("changed file is not reloaded", err)
+	}
+
+	files["b.js"] = &fstest.MapFile{Data: []byte("b"), ModTime: time.Unix(1, 0)}
+	if added, _ := d.load(); added.files["b.js"] == nil {
+		t.Error("added file is not loaded")
+	}
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"context"
+	"mime"
+	"myNotes/core"
+	"myNotes/core/attach"
+	"myNotes/core/mongo"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AttachmentsPath is path of versioned api route that uploads attachments, it has larger
+// body limit
+const AttachmentsPath = "/me/attachments"
+
+// attachmentID matches ids generated by attach.New
+var attachmentID = regexp.MustCompile(`^[0-9a-f]+\.[0-9a-z]+$`)
+
+// SetStorage replaces storage of attachment content, directory from configuration is used
+// otherwise
+func (w *WS) SetStorage(s attach.Storage) {
+	w.files = s
+}
+
+// APIAttachments ...
+func (w *WS) APIAttachments(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	as, err := w.db.UserAttachments(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := AttachmentsBody{Attachments: as, Quota: w.cfg.Attachments.Quota}
+	for _, a := range as {
+		body.Used += a.Size
+	}
+
+	return body, nil
+}
+
+// APIUploadAttachments stores uploaded files, all of them are checked before anything is
+// stored so failed upload does not use the quota, upload is all or nothing so files stored
+// before a failure are removed again
+func (w *WS) APIUploadAttachments(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := uploadedFiles(r)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := w.db.AttachmentUsage(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type upload struct {
+		attachment  core.Attachment
+		data, thumb []byte
+	}
+	uploads := make([]upload, len(files))
+	for i, f := range files {
+		a, thumb, err := attach.New(ac.ID, f.Name, f.Data, w.cfg.Attachments.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		used += a.Size
+		if used > w.cfg.Attachments.Quota {
+			return nil, attach.ErrQuota.Args(w.cfg.Attachments.Quota)
+		}
+
+		uploads[i] = upload{a, f.Data, thumb}
+	}
+
+	body := AttachmentsBody{Attachments: []core.Attachment{}, Quota: w.cfg.Attachments.Quota}
+	for _, u := range uploads {
+		err = w.files.Put(u.attachment.ID, u.data)
+		if err == nil && u.thumb != nil {
+			err = w.files.Put(u.attachment.ID+attach.ThumbSuffix, u.thumb)
+		}
+		if err == nil {
+			err = w.db.Attachment(&u.attachment)
+		}
+		if err != nil {
+			// content without record would never be collected
+			w.deleteFiles(u.attachment.ID)
+			w.discardAttachments(r, ac.ID, body.Attachments)
+			return nil, err
+		}
+
+		body.Attachments = append(body.Attachments, u.attachment)
+	}
+
+	// concurrent uploads passed the check above against the same usage, the one that
+	// finds the quota exceeded once its records exist gives way
+	body.Used, err = w.db.AttachmentUsage(ac.ID)
+	if err == nil && body.Used > w.cfg.Attachments.Quota {
+		err = attach.ErrQuota.Args(w.cfg.Attachments.Quota)
+	}
+	if err != nil {
+		w.discardAttachments(r, ac.ID, body.Attachments)
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// discardAttachments deletes records and content of attachments of failed upload, failure
+// is only logged as the upload already failed
+func (w *WS) discardAttachments(r *http.Request, owner core.ID, as []core.Attachment) {
+	for _, a := range as {
+		err := w.db.DeleteAttachment(owner, a.ID)
+		if err == nil {
+			err = w.deleteFiles(a.ID)
+		}
+		if err != nil {
+			LoggerFrom(r).Error("failed to discard attachment", "id", a.ID, "err", err)
+		}
+	}
+}
+
+// APIDeleteAttachment ...
+func (w *WS) APIDeleteAttachment(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("attachment")
+	err = w.db.DeleteAttachment(ac.ID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.deleteFiles(id)
+}
+
+// deleteFiles deletes content of attachment and its thumbnail
+func (w *WS) deleteFiles(id string) error {
+	err := w.files.Delete(id)
+	if err != nil {
+		return err
+	}
+	return w.files.Delete(id + attach.ThumbSuffix)
+}
+
+// ServeAttachment serves content of attachment, only images are displayed in browser, other
+// files are downloaded
+func (w *WS) ServeAttachment(wr http.ResponseWriter, r *http.Request) {
+	w.serveAttachment(wr, r, false)
+}
+
+// ServeThumbnail serves thumbnail of image, original is served if image is small or has no
+// thumbnail
+func (w *WS) ServeThumbnail(wr http.ResponseWriter, r *http.Request) {
+	w.serveAttachment(wr, r, true)
+}
+
+func (w *WS) serveAttachment(wr http.ResponseWriter, r *http.Request, thumb bool) {
+	err := func() error {
+		id := r.PathValue("attachment")
+		if !attachmentID.MatchString(id) {
+			return mongo.ErrNotFound.Args("attachment", "id")
+		}
+
+		a, err := w.db.AttachmentByID(id)
+		if err != nil {
+			return err
+		}
+
+		key, contentType := a.ID, a.Type
+		if thumb && a.Thumb {
+			key += attach.ThumbSuffix
+			if contentType != "image/jpeg" {
+				contentType = "image/png"
+			}
+		}
+
+		f, err := w.files.Open(key)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		disposition := "attachment"
+		if core.ImageExts[a.ID[strings.LastIndexByte(a.ID, '.')+1:]] {
+			disposition = "inline"
+		}
+
+		// content of id never changes
+		wr.Header().Set("Content-Type", contentType)
+		wr.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+		wr.Header().Set("Cache-Control", ImmutableCache)
+		wr.Header().Set("ETag", `"`+key+`"`)
+		http.ServeContent(wr, r, "", time.UnixMilli(a.BornDate), f)
+		return nil
+	}()
+	if err != nil {
+		_, status := Code(err)
+		if status == http.StatusInternalServerError {
+			LoggerFrom(r).Error("failed to serve attachment", "err", err)
+		}
+		http.Error(wr, err.Error(), status)
+	}
+}
+
+// CollectAttachments deletes attachments uploaded before given time (in milliseconds) that no
+// note of their owner embeds, it returns how many were deleted
+func (w *WS) CollectAttachments(before int64) (int, error) {
+	as, err := w.db.AttachmentsBefore(before)
+	if err != nil {
+		return 0, err
+	}
+
+	embedded := map[core.ID]map[string]bool{}
+	deleted := 0
+	for _, a := range as {
+		ids, ok := embedded[a.Owner]
+		if !ok {
+			var nts []core.Note
+			err = w.db.UserNotes(a.Owner, &nts)
+			if err != nil {
+				return deleted, err
+			}
+
+			ids = map[string]bool{}
+			for _, nt := range nts {
+				for _, id := range core.Embeds(nt.Content) {
+					ids[id] = true
+				}
+			}
+			embedded[a.Owner] = ids
+		}
+
+		if ids[a.ID] {
+			continue
+		}
+
+		err = w.db.DeleteAttachment(a.Owner, a.ID)
+		if err == nil {
+			err = w.deleteFiles(a.ID)
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// collectAttachments runs CollectAttachments periodically until ctx is done
+func (w *WS) collectAttachments(ctx context.Context) {
+	cfg := w.cfg.Attachments
+	ticker := time.NewTicker(cfg.GCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := w.CollectAttachments(w.now().Add(-cfg.GCAge).UnixMilli())
+		if err != nil {
+			w.log.Error("failed to collect attachments", "err", err)
+		} else if n != 0 {
+			w.log.Info("collected attachments", "deleted", n)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"myNotes/core"
+	"myNotes/core/attach"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentInvalidID(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+
+	testCases := []struct {
+		desc, path string
+	}{
+		{desc: "no extension", path: "/a/0123abcd"},
+		{desc: "upper case", path: "/a/0123ABCD.png"},
+		{desc: "hidden file", path: "/a/.upload-1"},
+		{desc: "thumbnail key", path: "/a/0123abcd.png.thumb"},
+		{desc: "escaped separator", path: "/a/..%2Fconfig.yaml/thumb"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			ws.Handler().ServeHTTP(rc, httptest.NewRequest("GET", tC.path, nil))
+			if rc.Code != http.StatusNotFound {
+				t.Error(rc.Code, rc.Body.String())
+			}
+		})
+	}
+}
+
+// hookStorage is directory storage that lets test interfere with storing
+type hookStorage struct {
+	attach.Dir
+	put func(key string) error
+}
+
+func (h hookStorage) Put(key string, data []byte) error {
+	if err := h.put(key); err != nil {
+		return err
+	}
+	return h.Dir.Put(key, data)
+}
+
+func TestUploadAttachmentsRollback(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	ac := MakeVerifiedAccount(db)
+	handler := ws.Handler()
+	quota := ws.cfg.Attachments.Quota
+
+	errStorage := errors.New("storage failed")
+
+	testCases := []struct {
+		desc   string
+		put    func(key string, n int) error
+		status int
+	}{
+		{
+			desc: "storage fails on second file",
+			put: func(key string, n int) error {
+				if n == 1 {
+					return errStorage
+				}
+				return nil
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			desc: "concurrent upload uses quota",
+			put: func(key string, n int) error {
+				if n > 0 {
+					return nil
+				}
+				return db.Attachment(&core.Attachment{ID: "concurrent.txt", Owner: ac.ID, Size: quota})
+			},
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := attach.Dir(t.TempDir())
+			n := 0
+			ws.SetStorage(hookStorage{dir, func(key string) error {
+				defer func() { n++ }()
+				return tC.put(key, n)
+			}})
+
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			for _, name := range []string{"a.txt", "b.txt"} {
+				fw, _ := mw.CreateFormFile("files", name)
+				fw.Write([]byte("content of " + name))
+			}
+			mw.Close()
+
+			req := httptest.NewRequest("POST", APIPrefix+AttachmentsPath, &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req.AddCookie(cookie(ac))
+			withCSRF(req)
+
+			rc := httptest.NewRecorder()
+			handler.ServeHTTP(rc, req)
+			if rc.Code != tC.status {
+				t.Fatal(rc.Code, rc.Body.String())
+			}
+
+			as, err := db.UserAttachments(ac.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range as {
+				if !strings.HasPrefix(a.ID, "concurrent") {
+					t.Error("attachment was kept", a)
+				}
+			}
+			if entries, _ := os.ReadDir(string(dir)); len(entries) != 0 {
+				t.Error("content was kept", entries)
+			}
+		})
+	}
+}
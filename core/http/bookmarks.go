@@ -0,0 +1,129 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http"
+)
+
+// sizes of bookmark pages
+const (
+	BookmarksSize    = 20
+	MaxBookmarksSize = 100
+)
+
+// APIBookmarks lists bookmarks of authenticated account from the newest with previews of
+// notes, query parameter collection filters them, cursor is Next of previous page and
+// limit changes size of page
+func (w *WS) APIBookmarks(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+
+	limit, err := QueryLimit(r, BookmarksSize, MaxBookmarksSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := core.ParseCursor(q.Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := core.NormalizeCollection(q.Get("collection"))
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more bookmark tells whether there is next page
+	bms, err := w.db.UserBookmarks(ac.ID, collection, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	body := BookmarksBody{Bookmarks: []core.BookmarkEntry{}}
+	if len(bms) > limit {
+		bms = bms[:limit]
+		last := bms[limit-1]
+		body.Next = core.Cursor{Date: last.BornDate, ID: last.Note}.String()
+	}
+
+	ids := make([]core.ID, len(bms))
+	for i, b := range bms {
+		ids[i] = b.Note
+	}
+	previews, err := w.db.NotePreviews(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bms {
+		nt, ok := previews[b.Note]
+		body.Bookmarks = append(body.Bookmarks, b.Entry(nt, ok, ac.ID))
+	}
+
+	return body, nil
+}
+
+// APIBookmarkCollections ...
+func (w *WS) APIBookmarkCollections(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	cols, err := w.db.BookmarkCollections(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CollectionsBody{Collections: cols}, nil
+}
+
+// APIBookmark bookmarks published note on PUT, bookmarking it again moves it to other
+// collection, DELETE removes bookmark even if note is gone
+func (w *WS) APIBookmark(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.CommentsS)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Method == http.MethodDelete {
+		return nil, w.db.Unbookmark(ac.ID, id)
+	}
+
+	var req BookmarkBody
+	err = Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	b := core.Bookmark{Owner: ac.ID, Note: id}
+	b.Collection, err = core.NormalizeCollection(req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := w.visibleNote(r, id)
+	if err != nil {
+		return nil, err
+	}
+	b.Name = nt.Name
+
+	count, err := w.db.BookmarkCount(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= core.MaxBookmarks {
+		return nil, core.ErrBookmarkLimit.Args(core.MaxBookmarks)
+	}
+
+	return nil, w.db.Bookmark(b)
+}
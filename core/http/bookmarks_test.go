@@ -0,0 +1,63 @@
+package http
+
+import (
+	"myNotes/core"
+	"testing"
+	"time"
+)
+
+func TestBookmarks(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	handler := ws.Handler()
+
+	reader := MakeVerifiedAccount(db)
+	author := core.Account{Name: "author", Password: "password", Email: "author@gmail.com"}
+	db.Account(&author)
+
+	nts := []core.Note{
+		{Author: author.ID, Name: "kept", Content: "content", Published: true},
+		{Author: author.ID, Name: "hidden", Content: "content", Published: true},
+		{Author: author.ID, Name: "deleted", Content: "content", Published: true},
+		{Author: reader.ID, Name: "own draft", Content: "content", Published: true},
+	}
+	for i := range nts {
+		db.Note(&nts[i])
+		if err := db.Bookmark(core.Bookmark{Owner: reader.ID, Note: nts[i].ID, Name: nts[i].Name}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	db.SetPublished(nts[1].ID, false)
+	db.SetPublished(nts[3].ID, false)
+	db.DeleteNote(nts[2].ID)
+
+	var body BookmarksBody
+	getJSON(t, handler, APIPrefix+"/me/bookmarks", &reader, &body)
+	entries := map[string]core.BookmarkEntry{}
+	for _, e := range body.Bookmarks {
+		entries[e.Name] = e
+	}
+
+	testCases := []struct {
+		desc, name, state string
+	}{
+		{desc: "published", name: "kept", state: core.BookmarkOK},
+		{desc: "unpublished", name: "hidden", state: core.BookmarkUnpublished},
+		{desc: "deleted", name: "deleted", state: core.BookmarkDeleted},
+		{desc: "own unpublished", name: "own draft", state: core.BookmarkOK},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			e, ok := entries[tC.name]
+			if !ok || e.State != tC.state {
+				t.Fatal(e, ok)
+			}
+			// notes that cannot be read keep only the name they were bookmarked with
+			if hasNote := e.Note.Content != ""; hasNote != (tC.state == core.BookmarkOK) {
+				t.Error(e.Note)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"crypto/subtle"
+	"myNotes/core"
+	"net/http"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// names of double submit token, cookie is readable by scripts of the site which copy it
+// to the header, other sites can neither read the cookie nor set the header
+const (
+	CSRFCookie = "csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// ErrCSRF is returned when unsafe request does not repeat csrf cookie in header
+var ErrCSRF = sterr.New("missing or invalid csrf token")
+
+// SafeMethod reports whether method can not change state
+func SafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// CSRF issues csrf cookie and rejects unsafe requests that do not carry the same token in
+// header, requests authenticated by bearer token are exempt since browsers do not attach
+// it on their own, neither do browsers reporting policy violations
+func (w *WS) CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(CSRFCookie)
+		if err != nil || cookie.Value == "" {
+			token, err := core.RandomString(32)
+			if err != nil {
+				WriteError(wr, r, core.EI(err))
+				return
+			}
+
+			cookie = &http.Cookie{Name: CSRFCookie, Value: token, Path: "/", SameSite: http.SameSiteStrictMode}
+			w.setCookie(wr, cookie)
+			// request that came without cookie can not have matching header
+			cookie.Value = ""
+		}
+
+		if !SafeMethod(r.Method) && r.Header.Get("Authorization") == "" && r.URL.Path != CSPReportPath {
+			header := r.Header.Get(CSRFHeader)
+			if cookie.Value == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+				WriteError(wr, r, ErrCSRF)
+				return
+			}
+		}
+
+		next.ServeHTTP(wr, r)
+	})
+}
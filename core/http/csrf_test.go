@@ -0,0 +1,51 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+	ok := ws.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testCases := []struct {
+		desc, method, cookie, header, auth string
+		handler                            http.Handler
+		status                             int
+		issued                             bool
+	}{
+		{desc: "safe without cookie", method: "GET", handler: ok, status: http.StatusOK, issued: true},
+		{desc: "unsafe without cookie", method: "POST", handler: ok, status: http.StatusForbidden, issued: true},
+		{desc: "missing header", method: "POST", cookie: "token", handler: ok, status: http.StatusForbidden},
+		{desc: "mismatch", method: "DELETE", cookie: "token", header: "other", handler: ok, status: http.StatusForbidden},
+		{desc: "match", method: "PATCH", cookie: "token", header: "token", handler: ok, status: http.StatusOK},
+		{desc: "bearer", method: "POST", auth: "Bearer mn_x", handler: ok, status: http.StatusOK, issued: true},
+		{desc: "legacy change over get", method: "GET", cookie: "token", handler: ws.Handler(), status: http.StatusMethodNotAllowed},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest(tC.method, "/setpublished?id=1&publish=true", nil)
+			if tC.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tC.cookie})
+			}
+			req.Header.Set(CSRFHeader, tC.header)
+			if tC.auth != "" {
+				req.Header.Set("Authorization", tC.auth)
+			}
+
+			rc := httptest.NewRecorder()
+			tC.handler.ServeHTTP(rc, req)
+
+			issued := false
+			for _, c := range rc.Result().Cookies() {
+				issued = issued || (c.Name == CSRFCookie && c.SameSite == http.SameSiteStrictMode && c.Value != "")
+			}
+			if rc.Code != tC.status || issued != tC.issued {
+				t.Error(rc.Code, tC.status, issued, rc.Body.String())
+			}
+		})
+	}
+}
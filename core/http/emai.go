@@ -2,6 +2,7 @@ package http
 
 import (
 	"bytes"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -14,34 +15,16 @@ import (
 	"github.com/jakubDoka/sterr"
 )
 
-// BotAccount for obvious security reasons email and password of a bot is loaded from private file
-var BotAccount EmailAccount
-
-// EmailAccount stores Email address and password
-type EmailAccount struct {
-	Email, Password string
-}
-
-func init() {
-	path := "email.json"
-	if core.Testing() {
-		path = "C:/Users/jakub/Documents/programming/golang/src/myNotes/email.json"
-	}
-	bts, err := ioutil.ReadFile(path)
-	if err != nil {
-		panic(err)
-	}
-
-	err = json.Unmarshal(bts, &BotAccount)
-	if err != nil {
-		panic(err)
-	}
-}
+// verificationTemplate is used unless configuration points to other template
+//
+//go:embed template.html
+var verificationTemplate string
 
 // errors  related to email handling
 var (
 	ErrInvalidEmail   = sterr.New("invalid email")
 	ErrEmailVerifFail = sterr.New("verification of email failed")
+	ErrTemplate       = sterr.New("failed to format email")
 )
 
 // EmailStatus is for unmarshaling api responce
@@ -80,6 +63,11 @@ func ValidEmail(email string) (err error) {
 	return nil
 }
 
+// Mailer sends messages to targets, WS uses it for verification emails
+type Mailer interface {
+	Send(message []byte, targets ...string) error
+}
+
 // EmailSender handles sending of emails to targets
 type EmailSender struct {
 	Service, Sender string
@@ -87,9 +75,7 @@ type EmailSender struct {
 }
 
 // NEmailSender ...
-func NEmailSender(sender, password string, port int16) *EmailSender {
-	const host = "smtp.gmail.com"
-
+func NEmailSender(host, sender, password string, port int) *EmailSender {
 	return &EmailSender{
 		Sender:  sender,
 		Auth:    smtp.PlainAuth("", sender, password, host),
@@ -102,32 +88,29 @@ func (e *EmailSender) Send(message []byte, targets ...string) error {
 	return core.EI(smtp.SendMail(e.Service, e.Auth, e.Sender, targets, message))
 }
 
-// FormatVerificationEmail creates verification email
-func FormatVerificationEmail(code, name string) []byte {
-	path := "core/http/template.html"
-	if core.Testing() {
-		path = "C:/Users/jakub/Documents/programming/golang/src/myNotes/core/http/template.html"
+// FormatVerificationEmail creates verification email from template at path, empty path
+// means built-in template
+func FormatVerificationEmail(path, code, name string) ([]byte, error) {
+	t, err := template.New("verification").Parse(verificationTemplate)
+	if path != "" {
+		t, err = template.ParseFiles(path)
 	}
-	t, err := template.ParseFiles(path)
 	if err != nil {
-		panic(err)
+		return nil, ErrTemplate.Wrap(err)
 	}
 
 	var body bytes.Buffer
 
 	mimeHeaders := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	_, err = body.Write([]byte(fmt.Sprintf("Subject: This is a test subject \n%s\n\n", mimeHeaders)))
-	if err != nil {
-		panic(err)
-	}
+	fmt.Fprintf(&body, "Subject: This is a test subject \n%s\n\n", mimeHeaders)
 
 	err = t.Execute(&body, struct {
 		Name string
 		Code string
 	}{name, code})
 	if err != nil {
-		panic(err)
+		return nil, ErrTemplate.Wrap(err)
 	}
 
-	return body.Bytes()
+	return body.Bytes(), nil
 }
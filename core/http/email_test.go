@@ -3,6 +3,9 @@ package http
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"myNotes/core"
+	"path/filepath"
 	"strconv"
 	"testing"
 )
@@ -27,7 +30,11 @@ func TestValidEmail(t *testing.T) {
 }
 
 func TestEmailSender(t *testing.T) {
-	s := NEmailSender(BotAccount.Email, BotAccount.Password, 587)
+	cfg := testConfig()
+	if cfg.Mail.Sender == "" {
+		t.Skip("smtp account is not configured")
+	}
+	s := NEmailSender(cfg.Mail.Host, cfg.Mail.Sender, cfg.Mail.Password, cfg.Mail.Port)
 
 	err := s.Send([]byte("Hello there."), "jakub.doka2@gmail.com")
 	if err != nil {
@@ -35,6 +42,39 @@ func TestEmailSender(t *testing.T) {
 	}
 }
 
+type failingMailer struct{}
+
+func (failingMailer) Send(message []byte, targets ...string) error {
+	return errors.New("smtp is down")
+}
+
+func TestVerificationEmail(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.html")
+	ioutil.WriteFile(broken, []byte("{{.Name"), 0o600)
+
+	testCases := []struct {
+		desc, template string
+		bot            Mailer
+		err            error
+	}{
+		{"missing template", filepath.Join(dir, "missing.html"), &EmailSender{}, ErrTemplate},
+		{"broken template", broken, &EmailSender{}, ErrTemplate},
+		{"failing mailer", "", failingMailer{}, ErrSendEmail},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Mail.Template = tC.template
+			ws := NWS(cfg, nil, tC.bot)
+			err := ws.SendVerifycationEmail(&core.Account{Name: "name", Code: "code", Email: "name@gmail.com"})
+			if !errors.Is(err, tC.err) {
+				t.Error(err, tC.err)
+			}
+		})
+	}
+}
+
 // testing whether marshaler accepts anonymous struct
 func TestResponce(t *testing.T) {
 	m, err := json.Marshal(struct {
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"myNotes/core"
+	"myNotes/core/export"
+	"net/http"
+)
+
+// APIExportNote ...
+func (w *WS) APIExportNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	format, err := exportFormat(r)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := w.visibleNote(r, id)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := w.db.AccountByID(nt.Author)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := w.labeler(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return exportFile(format, core.Slug(nt.Name), []export.Document{{Note: nt, Author: author, Labels: labels(nt)}})
+}
+
+// APIExportMyNotes ...
+func (w *WS) APIExportMyNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	format, err := exportFormat(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.exportNotes(r, format, ac, false)
+}
+
+// APIExportAccountNotes ...
+func (w *WS) APIExportAccountNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	format, err := exportFormat(r)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := w.db.AccountByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.exportNotes(r, format, ac, true)
+}
+
+// exportNotes exports notes of author, published limits them to published ones
+func (w *WS) exportNotes(r *http.Request, format string, author core.Account, published bool) (File, error) {
+	var nts []core.Note
+	err := w.db.UserNotes(author.ID, &nts)
+	if err != nil {
+		return File{}, err
+	}
+
+	labels, err := w.labeler(r)
+	if err != nil {
+		return File{}, err
+	}
+
+	docs := []export.Document{}
+	for _, nt := range nts {
+		if nt.Published || !published {
+			docs = append(docs, export.Document{Note: nt, Author: author, Labels: labels(nt)})
+		}
+	}
+
+	return exportFile(format, core.Slug(author.Name)+"-notes", docs)
+}
+
+// exportFormat returns format path parameter if it is supported
+func exportFormat(r *http.Request) (string, error) {
+	format := r.PathValue("format")
+	if _, ok := export.ContentTypes[format]; !ok {
+		return "", export.ErrFormat.Args(format)
+	}
+	return format, nil
+}
+
+func exportFile(format, name string, docs []export.Document) (File, error) {
+	var buf bytes.Buffer
+	err := export.Write(&buf, format, docs)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Name: name + "." + format, Type: export.ContentTypes[format], Data: buf.Bytes()}, nil
+}
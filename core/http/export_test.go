@@ -0,0 +1,52 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportFile(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+
+	h := ws.API(0, func(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+		return File{Name: "linear-algebra.md", Type: "text/markdown; charset=utf-8", Data: []byte("# Linear Algebra")}, nil
+	})
+
+	rc := httptest.NewRecorder()
+	h.ServeHTTP(rc, httptest.NewRequest("GET", "/", nil))
+	if rc.Code != http.StatusOK ||
+		rc.Header().Get("Content-Type") != "text/markdown; charset=utf-8" ||
+		rc.Header().Get("Content-Disposition") != `attachment; filename=linear-algebra.md` ||
+		rc.Body.String() != "# Linear Algebra" {
+		t.Error(rc.Code, rc.Header(), rc.Body.String())
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+
+	testCases := []struct {
+		desc, path, code string
+		status           int
+	}{
+		{desc: "note", path: "/notes/1/export/docx", code: "invalid_format", status: http.StatusBadRequest},
+		// scope is checked before handler looks at format
+		{desc: "own notes", path: "/me/notes/export/txt", code: "unauthorized", status: http.StatusUnauthorized},
+		{desc: "account notes", path: "/accounts/1/notes/export/PDF", code: "invalid_format", status: http.StatusBadRequest},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			ws.Handler().ServeHTTP(rc, httptest.NewRequest("GET", APIPrefix+tC.path, nil))
+
+			var body ErrorBody
+			json.Unmarshal(rc.Body.Bytes(), &body)
+			if rc.Code != tC.status || body.Error.Code != tC.code {
+				t.Error(rc.Code, rc.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http"
+	"strings"
+)
+
+// sizes of feed pages
+const (
+	FeedSize    = 20
+	MaxFeedSize = 100
+)
+
+// APIFeed lists published notes of followed accounts and subjects from the newest, query
+// parameter cursor is Next of previous page and limit changes size of page
+func (w *WS) APIFeed(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+
+	limit, err := QueryLimit(r, FeedSize, MaxFeedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := core.ParseCursor(q.Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := w.db.UserFollows(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more note tells whether there is next page
+	notes, err := w.db.Feed(follows, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	body := FeedBody{Notes: notes}
+	if len(notes) > limit {
+		body.Notes = notes[:limit]
+		last := body.Notes[limit-1]
+		body.Next = core.Cursor{Date: last.PublishDate, ID: last.ID}.String()
+	}
+
+	return body, nil
+}
+
+// APIFollows ...
+func (w *WS) APIFollows(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := w.db.UserFollows(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return FollowsBody{Follows: follows}, nil
+}
+
+// APIFollowAccount follows account on PUT and unfollows it on DELETE
+func (w *WS) APIFollowAccount(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.CommentsS)
+	if err != nil {
+		return nil, err
+	}
+
+	f := core.Follow{Follower: ac.ID, Kind: core.FollowAccount, Account: id}
+	if r.Method == http.MethodDelete {
+		return nil, w.db.Unfollow(f)
+	}
+
+	if id == ac.ID {
+		return nil, core.ErrFollowSelf
+	}
+
+	_, err = w.db.AccountByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.follow(f)
+}
+
+// APIFollowSubject follows subject on PUT and unfollows it on DELETE, subject is resolved
+// through taxonomy so aliases follow the same subject
+func (w *WS) APIFollowSubject(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.CommentsS)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	subject := strings.TrimSpace(r.PathValue("subject"))
+	if subject == "" {
+		return nil, ErrInvalidParam.Args("subject")
+	}
+
+	canonical, _, err := tx.Canonical(0, subject, "")
+
+	// subject that was removed from taxonomy can still be unfollowed
+	f := core.Follow{Follower: ac.ID, Kind: core.FollowSubject, Subject: canonical}
+	if r.Method == http.MethodDelete {
+		if err != nil {
+			f.Subject = subject
+		}
+		return nil, w.db.Unfollow(f)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.follow(f)
+}
+
+// follow stores follow unless account already follows too much, following the same
+// source again is fine
+func (w *WS) follow(f core.Follow) error {
+	follows, err := w.db.UserFollows(f.Follower)
+	if err != nil {
+		return err
+	}
+
+	if len(follows) >= core.MaxFollows {
+		for _, other := range follows {
+			if other.Kind == f.Kind && other.Account == f.Account && other.Subject == f.Subject {
+				return nil
+			}
+		}
+		return core.ErrFollowLimit.Args(core.MaxFollows)
+	}
+
+	return w.db.Follow(f)
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	handler := ws.Handler()
+
+	reader := MakeVerifiedAccount(db)
+	author := core.Account{Name: "author", Password: "password", Email: "author@gmail.com"}
+	db.Account(&author)
+
+	for _, nt := range []core.Note{
+		{Author: author.ID, Name: "a", Published: true},
+		{Author: author.ID, Name: "draft"},
+		{Author: author.ID, Name: "b", Published: true},
+		{Author: author.ID, Name: "c", Published: true},
+		{Author: reader.ID, Name: "own", Published: true},
+	} {
+		db.Note(&nt)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := db.Follow(core.Follow{Follower: reader.ID, Kind: core.FollowAccount, Account: author.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		limit    int
+		expected []string
+	}{
+		{desc: "one page", limit: 10, expected: []string{"c", "b", "a"}},
+		{desc: "pages", limit: 2, expected: []string{"c", "b", "a"}},
+		{desc: "single notes", limit: 1, expected: []string{"c", "b", "a"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var names []string
+			cursor, pages := "", 0
+			for {
+				var body FeedBody
+				getJSON(t, handler, APIPrefix+"/feed?limit="+strconv.Itoa(tC.limit)+"&cursor="+url.QueryEscape(cursor), &reader, &body)
+				if len(body.Notes) > tC.limit {
+					t.Fatal("page is larger than limit", body.Notes)
+				}
+				for _, nt := range body.Notes {
+					names = append(names, nt.Name)
+				}
+
+				pages++
+				if body.Next == "" || pages > len(tC.expected) {
+					break
+				}
+				cursor = body.Next
+			}
+
+			if strings.Join(names, ",") != strings.Join(tC.expected, ",") {
+				t.Error(names)
+			}
+			if want := (len(tC.expected) + tC.limit - 1) / tC.limit; pages != want {
+				t.Error("pages", pages, want)
+			}
+		})
+	}
+}
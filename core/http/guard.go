@@ -0,0 +1,219 @@
+package http
+
+import (
+	"myNotes/core"
+	"myNotes/core/config"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limit describes how many failures key can make before it gets locked, limits are part of
+// configuration
+type Limit = config.Limit
+
+// SweepInterval is how often guard forgets keys that do not limit anything anymore
+const SweepInterval = time.Minute
+
+// Guard keeps track of failed login and verification attempts in memory, it tells whether
+// next attempt is allowed and locks keys that fail too many times, failures are forgotten
+// once key does not fail for duration of lockout, it also remembers recent views of notes
+type Guard struct {
+	m        sync.Mutex
+	now      func() time.Time
+	attempts map[string]*attempts
+	emails   map[string][]time.Time
+	views    map[string]time.Time
+	swept    time.Time
+}
+
+type attempts struct {
+	count                  int
+	last, release, expires time.Time
+}
+
+// NGuard creates guard with given clock, clock is replaced with fake one in tests
+func NGuard(now func() time.Time) *Guard {
+	return &Guard{
+		now:      now,
+		attempts: map[string]*attempts{},
+		emails:   map[string][]time.Time{},
+		views:    map[string]time.Time{},
+	}
+}
+
+// Check returns ErrLocked or ErrTooSoon if key cannot make attempt yet
+func (g *Guard) Check(key string, l Limit) error {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	a, ok := g.attempts[key]
+	if !ok {
+		return nil
+	}
+
+	now := g.now()
+	if now.Before(a.release) {
+		return ErrLocked.Args(Remaining(now, a.release))
+	}
+
+	if a.count >= l.Failures || !now.Before(a.expires) { // lockout expired or failures are old
+		delete(g.attempts, key)
+		return nil
+	}
+
+	next := a.last.Add(delay(l, a.count))
+	if now.Before(next) {
+		return ErrTooSoon.Args(Remaining(now, next))
+	}
+
+	return nil
+}
+
+// Fail records failed attempt, if key reached the limit, time of release is returned
+func (g *Guard) Fail(key string, l Limit) (locked bool, release time.Time) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.sweep()
+
+	a, ok := g.attempts[key]
+	if !ok {
+		a = &attempts{}
+		g.attempts[key] = a
+	}
+
+	a.count++
+	a.last = g.now()
+	a.expires = a.last.Add(l.Lockout)
+	if a.count == l.Failures {
+		a.release = a.last.Add(l.Lockout)
+		return true, a.release
+	}
+
+	return
+}
+
+// Reset forgets all failures of the key, it should be called after successful attempt
+func (g *Guard) Reset(key string) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	delete(g.attempts, key)
+}
+
+// Email returns ErrEmailLimit if key already received perHour emails during last hour,
+// otherwise email is recorded
+func (g *Guard) Email(key string, perHour int) error {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.sweep()
+
+	now := g.now()
+	sent := g.emails[key]
+	for len(sent) != 0 && now.Sub(sent[0]) >= time.Hour {
+		sent = sent[1:]
+	}
+
+	if len(sent) >= perHour {
+		g.emails[key] = sent
+		return ErrEmailLimit.Args(Remaining(now, sent[0].Add(time.Hour)))
+	}
+
+	g.emails[key] = append(sent, now)
+	return nil
+}
+
+// View reports whether view under key should be counted, it is counted once per window
+func (g *Guard) View(key string, window time.Duration) bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.sweep()
+
+	now := g.now()
+	if now.Before(g.views[key]) {
+		return false
+	}
+
+	g.views[key] = now.Add(window)
+	return true
+}
+
+// sweep deletes keys with expired failures, keys that received no email during last hour
+// and views out of their window, it does nothing if it swept recently, guard has to be
+// locked
+func (g *Guard) sweep() {
+	now := g.now()
+	if now.Sub(g.swept) < SweepInterval {
+		return
+	}
+	g.swept = now
+
+	for key, a := range g.attempts {
+		if !now.Before(a.expires) && !now.Before(a.release) {
+			delete(g.attempts, key)
+		}
+	}
+
+	for key, sent := range g.emails {
+		if len(sent) == 0 || now.Sub(sent[len(sent)-1]) >= time.Hour {
+			delete(g.emails, key)
+		}
+	}
+
+	for key, until := range g.views {
+		if !now.Before(until) {
+			delete(g.views, key)
+		}
+	}
+}
+
+func delay(l Limit, failures int) time.Duration {
+	d := l.Delay
+	for i := 1; i < failures && d < l.MaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > l.MaxDelay {
+		return l.MaxDelay
+	}
+
+	return d
+}
+
+// AccountKey is guard key for account name
+func AccountKey(name string) string {
+	return "account:" + name
+}
+
+// IPKey is guard key for ip address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ViewKey is guard key for view of note by viewer, viewer is AccountKey or IPKey
+func ViewKey(note core.ID, viewer string) string {
+	return "view:" + strconv.FormatUint(note, 10) + ":" + viewer
+}
+
+// RemoteIP returns ip address of request sender without port
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Remaining formats time left until deadline
+func Remaining(now, deadline time.Time) string {
+	d := deadline.Sub(now)
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.t }
+
+func (f *fakeClock) Advance(d time.Duration) { f.t = f.t.Add(d) }
+
+func TestGuardLockout(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := NGuard(clock.Now)
+	l := Limit{Failures: 3, Delay: time.Second, MaxDelay: time.Second * 3, Lockout: time.Minute}
+
+	testCases := []struct {
+		desc    string
+		advance time.Duration
+		fail    bool
+		locked  bool
+		result  error
+	}{
+		{desc: "first attempt", fail: true},
+		{desc: "too soon", result: ErrTooSoon},
+		{desc: "after delay", advance: time.Second, fail: true},
+		{desc: "delay doubled", advance: time.Second, result: ErrTooSoon},
+		{desc: "locked", advance: time.Second, fail: true, locked: true},
+		{desc: "still locked", advance: time.Second * 30, result: ErrLocked},
+		{desc: "released", advance: time.Second * 30},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			clock.Advance(tC.advance)
+			err := g.Check("key", l)
+			if !errors.Is(err, tC.result) {
+				t.Error(err, tC.result)
+			}
+
+			if tC.fail {
+				locked, _ := g.Fail("key", l)
+				if locked != tC.locked {
+					t.Error(locked, tC.locked)
+				}
+			}
+		})
+	}
+}
+
+func TestGuardEmail(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := NGuard(clock.Now)
+	const perHour = 3
+
+	for i := 0; i < perHour; i++ {
+		if err := g.Email("key", perHour); err != nil {
+			t.Error(err)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	if err := g.Email("key", perHour); !errors.Is(err, ErrEmailLimit) {
+		t.Error(err, ErrEmailLimit)
+	}
+
+	clock.Advance(time.Hour)
+
+	if err := g.Email("key", perHour); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGuardSweep(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := NGuard(clock.Now)
+	l := Limit{Failures: 3, Delay: time.Second, MaxDelay: time.Second * 3, Lockout: time.Minute}
+
+	g.Fail("old", l)
+	g.Fail("stale", l)
+	g.Email("old", 1)
+	clock.Advance(time.Hour)
+
+	// failures older than lockout do not count anymore
+	if err := g.Check("old", l); err != nil {
+		t.Error(err)
+	}
+
+	g.Fail("new", l)
+	if _, ok := g.attempts["stale"]; ok {
+		t.Error("expired failures are kept")
+	}
+	if _, ok := g.attempts["new"]; !ok {
+		t.Error("recent failure is forgotten")
+	}
+
+	g.Email("new", 1)
+	if _, ok := g.emails["old"]; ok {
+		t.Error("old emails are kept")
+	}
+	if _, ok := g.emails["new"]; !ok {
+		t.Error("recent email is forgotten")
+	}
+}
+
+func TestGuardView(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	g := NGuard(clock.Now)
+
+	testCases := []struct {
+		desc     string
+		key      string
+		advance  time.Duration
+		expected bool
+	}{
+		{desc: "first", key: ViewKey(1, IPKey("1.2.3.4")), expected: true},
+		{desc: "repeated", key: ViewKey(1, IPKey("1.2.3.4")), advance: time.Minute, expected: false},
+		{desc: "other viewer", key: ViewKey(1, AccountKey("bob")), expected: true},
+		{desc: "other note", key: ViewKey(2, IPKey("1.2.3.4")), expected: true},
+		{desc: "after window", key: ViewKey(1, IPKey("1.2.3.4")), advance: time.Hour, expected: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			clock.Advance(tC.advance)
+			if counted := g.View(tC.key, time.Hour); counted != tC.expected {
+				t.Error(counted)
+			}
+		})
+	}
+
+	clock.Advance(2 * time.Hour)
+	g.View("new", time.Hour)
+	if len(g.views) != 1 {
+		t.Error("views out of window are kept", g.views)
+	}
+}
@@ -3,13 +3,19 @@ package http
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"myNotes/core"
+	"myNotes/core/attach"
+	"myNotes/core/config"
 	"myNotes/core/mongo"
+	"myNotes/core/ranking"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/jakubDoka/gogen/str"
 	"github.com/jakubDoka/sterr"
@@ -30,48 +36,139 @@ var (
 	ErrInvalidUserCookie = sterr.New("user cookie is invalid")
 	ErrMissingUserCookie = sterr.New("missing user cookie")
 	ErrNotPublished      = sterr.New("this note is not published yet")
+	ErrLocked            = sterr.New("too many failed attempts, try again in %s")
+	ErrTooSoon           = sterr.New("you have to wait %s before next attempt")
+	ErrEmailLimit        = sterr.New("too many verification emails were sent, try again in %s")
+	ErrNoRegistration    = sterr.New("registration of new accounts is disabled")
 )
 
 // WS like a website, struct is main interface to frontend, it opens a server and handels requests
 type WS struct {
+	cfg           config.Config
 	db            *mongo.DB
-	fs            http.Handler
+	fs            AssetServer
 	targetAddress string
-	bot           EmailSender
+	bot           Mailer
 	ps            urlp.Parser
+	guard         *Guard
+	now           func() time.Time
+	mux           *http.ServeMux
+	log           *slog.Logger
+	metrics       *Metrics
+	queue         *MailQueue
+	files         attach.Storage
+	draining      atomic.Bool
+	ranks         atomic.Pointer[ranking.Ranking]
 }
 
-// NWS creates new WS that can then be runned by ws.Run()
-func NWS(domain, pageDir string, port int16, db *mongo.DB, bot EmailSender) (nws *WS) {
+// NWS creates new WS that can then be runned by ws.Run(), configuration is expected to be
+// valid
+func NWS(cfg config.Config, db *mongo.DB, bot Mailer) (nws *WS) {
 	return &WS{
+		cfg:           cfg,
 		db:            db,
-		fs:            http.FileServer(http.Dir(pageDir)),
-		targetAddress: fmt.Sprintf("%s:%d", domain, port),
+		fs:            pages(cfg.Server.PageDir),
+		targetAddress: net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port)),
 		bot:           bot,
 		ps:            urlp.New(urlp.LowerCase),
+		guard:         NGuard(time.Now),
+		now:           time.Now,
+		mux:           http.NewServeMux(),
+		log:           slog.Default(),
+		metrics:       NMetrics(db, time.Now),
+		files:         attach.Dir(cfg.Attachments.Dir),
 	}
 }
 
-// RegisterHandlers ...
+// SetLogger replaces logger of WS, slog.Default() is used otherwise
+func (w *WS) SetLogger(l *slog.Logger) {
+	w.log = l
+}
+
+// LegacyRoute is endpoint of original api, parameters are passed in url query and Req
+// describes them, Text is true if endpoint also reads plain text body, Post is true if
+// endpoint changes state and so accepts only POST
+type LegacyRoute struct {
+	Path    string
+	Handler http.HandlerFunc
+
+	Req, Resp interface{}
+
+	Text, Post bool
+}
+
+// Legacy returns route table of original api
+func (w *WS) Legacy() []LegacyRoute {
+	return []LegacyRoute{
+		// account
+		{"/register", w.RegisterAccount, RegisterRequest{}, Responce{}, false, true},
+		{"/verify", w.VerifyAccount, VerifyRequest{}, Responce{}, false, true},
+		{"/login", w.Login, LoginReqest{}, Responce{}, false, true},
+		{"/account", w.Account, Request{}, AccountResponce{}, false, false},
+		{"/publicaccount", w.PublicAccount, IDRequest{}, AccountResponce{}, false, false},
+		{"/config", w.Config, OptIDRequest{}, ConfigResponce{}, false, false},
+		{"/configure", w.Configure, ConfigureRequest{}, Responce{}, false, true},
+		{"/totp/enroll", w.EnrollTOTP, Request{}, EnrollResponce{}, false, true},
+		{"/totp/qr", w.TOTPQR, Request{}, PNG{}, false, false},
+		{"/totp/confirm", w.ConfirmTOTP, CodeRequest{}, RecoveryResponce{}, false, true},
+		{"/totp/disable", w.DisableTOTP, CodeRequest{}, Responce{}, false, true},
+		{"/tokens", w.Tokens, Request{}, TokensResponce{}, false, false},
+		{"/tokens/create", w.CreateToken, TokenRequest{}, TokenResponce{}, false, true},
+		{"/tokens/revoke", w.RevokeToken, IDRequest{}, Responce{}, false, true},
+		// note
+		{"/search", w.Search, core.SearchRequest{}, SearchResponce{}, false, false},
+		{"/save", w.SaveNote, SaveRequest{}, SaveResponce{}, true, true},
+		{"/publicnote", w.PublicNote, IDRequest{}, NoteResponce{}, false, false},
+		{"/privatenote", w.PrivateNote, IDRequest{}, NoteResponce{}, false, false},
+		{"/usernotes", w.UserNotes, IDRequest{}, DraftResponce{}, false, false},
+		{"/setpublished", w.SetPublished, PublishRequest{}, Responce{}, false, true},
+		// general
+		{"/like", w.Like, LikeRequest{}, LikeResponce{}, false, false},
+		{"/comment", w.Comment, CommentRequest{}, Responce{}, true, true},
+	}
+}
+
+// RegisterHandlers registers all routes to the WS mux
 func (w *WS) RegisterHandlers() {
-	http.Handle("/", w.fs)
-	// account
-	http.HandleFunc("/register", w.RegisterAccount)
-	http.HandleFunc("/verify", w.VerifyAccount)
-	http.HandleFunc("/login", w.Login)
-	http.HandleFunc("/account", w.Account)
-	http.HandleFunc("/publicaccount", w.PublicAccount)
-	http.HandleFunc("/config", w.Config)
-	http.HandleFunc("/configure", w.Configure)
-	// note
-	http.HandleFunc("/search", w.Search)
-	http.HandleFunc("/save", w.SaveNote)
-	http.HandleFunc("/publicnote", w.PublicNote)
-	http.HandleFunc("/privatenote", w.PrivateNote)
-	http.HandleFunc("/usernotes", w.UserNotes)
-	http.HandleFunc("/setpublished", w.SetPublished)
-	// general
-	http.HandleFunc("/like", w.Like)
+	w.mux.Handle("/", Methods(http.MethodGet, http.MethodHead)(w.fs))
+
+	for _, rt := range w.Legacy() {
+		methods := Methods(http.MethodGet, http.MethodHead, http.MethodPost)
+		if rt.Post {
+			methods = Methods(http.MethodPost)
+		}
+		w.mux.Handle(rt.Path, Chain(rt.Handler, methods, w.Authenticate))
+	}
+
+	if w.cfg.Features.Metrics {
+		w.mux.Handle("GET "+MetricsPath, w.metrics.Handler())
+	}
+	// author is recognized so own views are not counted
+	w.mux.Handle("GET "+NotePath+"{id}", w.Authenticate(http.HandlerFunc(w.ViewNote)))
+	w.mux.Handle("GET "+NotePath+"{id}/{slug}", w.Authenticate(http.HandlerFunc(w.ViewNote)))
+	w.mux.HandleFunc("GET "+NotebookPath+"{id}", w.ViewNotebook)
+	w.mux.HandleFunc("GET "+NotebookPath+"{id}/{slug}", w.ViewNotebook)
+	w.mux.HandleFunc("GET "+core.AttachmentPath+"{attachment}", w.ServeAttachment)
+	w.mux.HandleFunc("GET "+core.AttachmentPath+"{attachment}/thumb", w.ServeThumbnail)
+	w.mux.HandleFunc("POST "+CSPReportPath, w.ReportCSP)
+	w.mux.HandleFunc("GET "+HealthPath, w.Health)
+	w.mux.HandleFunc("GET "+ReadyPath, w.Ready)
+
+	w.RegisterAPI()
+}
+
+// Handler returns handler of WS with middleware applied
+func (w *WS) Handler() http.Handler {
+	uploads := map[string]int64{
+		APIPrefix + ImportPath:      MaxUploadSize,
+		APIPrefix + AttachmentsPath: max(MaxUploadSize, int64(w.cfg.Attachments.MaxSize)+MaxBodySize),
+	}
+	mws := []Middleware{RequestID, Security(w.cfg.Server.Security), Logging(w.log), w.metrics.Middleware, Recover, BodyLimit(MaxBodySize, uploads), w.CSRF}
+	if tls := w.cfg.Server.TLS; tls.Enabled() && tls.HSTSMaxAge > 0 {
+		mws = append(mws, HSTS(tls.HSTSMaxAge))
+	}
+
+	return Chain(w.mux, mws...)
 }
 
 // RegisterAccount handels registering account and responds whether registration wos successful
@@ -82,39 +179,49 @@ func (w *WS) RegisterAccount(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	encoder.Encode(NResponce(w.register(req)))
+}
+
+// register creates unverified account and sends verification email
+func (w *WS) register(req RegisterRequest) (err error) {
+	if !w.cfg.Features.Registration {
+		return ErrNoRegistration
+	}
+
 	ac := core.Account{
 		Name:     req.Name,
 		Password: req.Password,
 		Email:    req.Email,
 	}
 
-	err := func() (err error) {
-		err = ValidEmail(ac.Email)
-		if err != nil {
-			return
-		}
-
-		err = w.db.CanCreateAccount(&ac)
-		if err != nil {
-			return ErrAccount.Wrap(err)
-		}
+	err = ValidEmail(ac.Email)
+	if err != nil {
+		return
+	}
 
-		ac.Code = w.db.Code()
+	err = w.db.CanCreateAccount(&ac)
+	if err != nil {
+		return ErrAccount.Wrap(err)
+	}
 
-		err = w.SendVerifycationEmail(&ac)
-		if err != nil {
-			return
-		}
+	ac.Code = w.db.Code()
 
-		err = w.db.Account(&ac)
-		if err != nil {
-			return core.EI(err)
-		}
+	err = w.guard.Email(AccountKey(ac.Name), w.cfg.Limits.EmailsPerHour)
+	if err != nil {
+		return
+	}
 
+	err = w.SendVerifycationEmail(&ac)
+	if err != nil {
 		return
-	}()
+	}
 
-	encoder.Encode(NResponce(err))
+	err = w.db.Account(&ac)
+	if err != nil {
+		return core.EI(err)
+	}
+
+	return
 }
 
 // VerifyAccount ...
@@ -125,37 +232,53 @@ func (w *WS) VerifyAccount(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := func() (err error) {
-		ac, err := w.db.LoginAccount(req.Name, req.Password)
-		if err != nil && !errors.Is(err, mongo.ErrNotVerified) {
-			return ErrInvalidLogin.Wrap(err)
-		}
+	encoder.Encode(NResponce(w.verify(r, req)))
+}
 
-		err = nil
+// verify verifies account if code matches, otherwise new code is sent
+func (w *WS) verify(r *http.Request, req VerifyRequest) (err error) {
+	err = w.Attempt(r, req.Name)
+	if err != nil {
+		return
+	}
 
-		if ac.Code == mongo.Verified {
-			return ErrAlreadyVerified
-		}
+	ac, err := w.db.LoginAccount(req.Name, req.Password)
+	if err != nil && !errors.Is(err, mongo.ErrNotVerified) {
+		w.Failed(r, req.Name)
+		return ErrInvalidLogin.Wrap(err)
+	}
 
-		if ac.Code != req.Code {
-			ac.Code, err = w.db.ChangeAccountCode(ac.ID)
-			if err != nil {
-				return
-			}
+	err = nil
 
-			err = w.SendVerifycationEmail(&ac)
-			if err != nil {
-				return
-			}
-			return ErrIncorrectCode
+	if ac.Code == mongo.Verified {
+		return ErrAlreadyVerified
+	}
+
+	if ac.Code != req.Code {
+		w.Failed(r, req.Name)
+
+		// code is changed only when user gets new one, otherwise
+		// verification would turn into an email bomb
+		err = w.guard.Email(AccountKey(ac.Name), w.cfg.Limits.EmailsPerHour)
+		if err != nil {
+			return
 		}
 
-		w.db.MakeAccountVerified(ac.ID)
+		ac.Code, err = w.db.ChangeAccountCode(ac.ID)
+		if err != nil {
+			return
+		}
 
-		return
-	}()
+		err = w.SendVerifycationEmail(&ac)
+		if err != nil {
+			return
+		}
+		return ErrIncorrectCode
+	}
 
-	encoder.Encode(NResponce(err))
+	w.guard.Reset(AccountKey(req.Name))
+
+	return w.db.MakeAccountVerified(ac.ID)
 }
 
 // PublicAccount retrieves account from db, but only by id and password is censored
@@ -170,13 +293,20 @@ func (w *WS) PublicAccount(wr http.ResponseWriter, r *http.Request) {
 
 	ac.Censure()
 
+	var followers, following int64
+	if err == nil {
+		followers, following, err = w.db.FollowCounts(ac.ID)
+	}
+
 	encoder.Encode(AccountResponce{
-		Resp:    NResponce(err),
-		Account: ac,
+		Resp:      NResponce(err),
+		Account:   ac,
+		Followers: followers,
+		Following: following,
 	})
 }
 
-// Account retrieves account from db
+// Account retrieves authenticated account without its credentials
 func (w *WS) Account(wr http.ResponseWriter, r *http.Request) {
 	var req Request
 	encoder, failed := w.Setup(wr, r, &req)
@@ -184,7 +314,8 @@ func (w *WS) Account(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ac, err := w.GetAccountFromCookie(wr, r)
+	ac, err := AccountFrom(r, core.ReadS)
+	ac.HideCredentials()
 
 	encoder.Encode(AccountResponce{
 		Resp:    NResponce(err),
@@ -201,7 +332,7 @@ func (w *WS) Search(wr http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Author == "!!me" {
-		if ac, err := w.GetAccountFromCookie(wr, r); err == nil {
+		if ac, err := AccountFrom(r, core.ReadS); err == nil {
 			req.Author = mongo.ExactLabel + ac.Name
 		}
 	}
@@ -226,15 +357,55 @@ func (w *WS) Login(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ac, err := w.db.LoginAccount(req.Name, req.Password)
-	if err != nil {
-		err = ErrInvalidLogin.Wrap(err)
-	} else {
+	encoder.Encode(NResponce(w.login(wr, r, req)))
+}
+
+// login sets cookies that authenticate user, second factor is checked if enabled
+func (w *WS) login(wr http.ResponseWriter, r *http.Request, req LoginReqest) error {
+	err := func() (err error) {
+		err = w.Attempt(r, req.Name)
+		if err != nil {
+			return
+		}
+
+		ac, err := w.db.LoginAccount(req.Name, req.Password)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNotVerified) {
+				w.Failed(r, req.Name)
+			}
+			return
+		}
+
+		if ac.TOTP.Enabled {
+			if req.Code == "" {
+				return ErrSecondStep
+			}
+
+			err = w.SecondFactor(&ac, req.Code)
+			if err != nil {
+				w.Failed(r, req.Name)
+				return
+			}
+
+			err = w.StartSession(wr, ac.ID)
+			if err != nil {
+				return
+			}
+		}
+
+		w.guard.Reset(AccountKey(req.Name))
+
 		cookie := ac.Cookie()
-		http.SetCookie(wr, &cookie)
+		w.setCookie(wr, &cookie)
+
+		return
+	}()
+
+	if err != nil {
+		return ErrInvalidLogin.Wrap(err)
 	}
 
-	encoder.Encode(NResponce(err))
+	return nil
 }
 
 // Config returns user config to frontend based of cookie
@@ -250,7 +421,7 @@ func (w *WS) Config(wr http.ResponseWriter, r *http.Request) {
 	if req.ID != core.None {
 		ac, err = w.db.AccountByID(req.ID)
 	} else {
-		ac, err = w.GetAccountFromCookie(wr, r)
+		ac, err = AccountFrom(r, core.ReadS)
 	}
 
 	encoder.Encode(ConfigResponce{
@@ -268,38 +439,45 @@ func (w *WS) Configure(wr http.ResponseWriter, r *http.Request) {
 	}
 
 	err := func() (err error) {
-		ac, err := w.GetAccountFromCookie(wr, r)
+		ac, err := AccountFrom(r, core.AccountS)
 		if err != nil {
 			return
 		}
 
-		if req.Name != ac.Name {
-			ac.Name = req.Name
-			_, err = w.db.AccountByName(req.Name)
-			if err == nil {
-				return mongo.ErrNameTaken
-			}
-			err = nil
+		colors := strings.Split(req.Colors, " ")
+		for i, c := range colors {
+			colors[i] = "#" + c
 		}
 
-		ac.Cfg.Colors = strings.Split(req.Colors, " ")
-		for i, c := range ac.Cfg.Colors {
-			ac.Cfg.Colors[i] = "#" + c
-		}
+		return w.configure(wr, ac, req.Name, colors)
+	}()
 
-		err = w.db.Replace(w.db.Accounts, &ac)
-		if err != nil {
-			return
+	encoder.Encode(NResponce(err))
+}
+
+// configure renames account and changes its colors
+func (w *WS) configure(wr http.ResponseWriter, ac core.Account, name string, colors []string) (err error) {
+	if name != ac.Name {
+		ac.Name = name
+		_, err = w.db.AccountByName(name)
+		if err == nil {
+			return mongo.ErrNameTaken
 		}
+		err = nil
+	}
 
-		// name changes so cookie has to be restored
-		cookie := ac.Cookie()
-		http.SetCookie(wr, &cookie)
+	ac.Cfg.Colors = colors
 
+	err = w.db.Replace(w.db.Accounts, &ac)
+	if err != nil {
 		return
-	}()
+	}
 
-	encoder.Encode(NResponce(err))
+	// name changes so cookie has to be restored
+	cookie := ac.Cookie()
+	w.setCookie(wr, &cookie)
+
+	return
 }
 
 // Like changes like state of something
@@ -313,7 +491,16 @@ func (w *WS) Like(wr http.ResponseWriter, r *http.Request) {
 	var state bool
 	var amount int
 	err := func() (err error) {
-		ac, err := w.GetAccountFromCookie(wr, r)
+		// likes are feedback same as comments
+		scope := core.ReadS
+		if req.Change {
+			if SafeMethod(r.Method) {
+				return ErrMethodNotAllowed.Args(r.Method)
+			}
+			scope = core.CommentsS
+		}
+
+		ac, err := AccountFrom(r, scope)
 		if err != nil {
 			return
 		}
@@ -343,12 +530,7 @@ func (w *WS) Comment(wr http.ResponseWriter, r *http.Request) {
 	}
 
 	err := func() (err error) {
-		ac, err := w.GetAccountFromCookie(wr, r)
-		if err != nil {
-			return
-		}
-
-		act, err := w.db.TakeAction(ac.ID)
+		ac, err := AccountFrom(r, core.CommentsS)
 		if err != nil {
 			return
 		}
@@ -358,42 +540,56 @@ func (w *WS) Comment(wr http.ResponseWriter, r *http.Request) {
 			return core.EI(err)
 		}
 
-		cm := core.Comment{
-			Author:  ac.ID,
-			Note:    core.None,
-			Content: string(bytes),
-		}
-
 		tp, err := core.ParseTargetType(req.Target)
 		if err != nil {
 			return
 		}
 
-		cm.Target.Type = tp
-		switch tp {
-		case core.CommentT:
-			nt, err := w.db.NoteByID(req.ID)
-			if err != nil {
-				return err
-			}
-			cm.Target.ID = nt.ID
-		case core.NoteT:
-			ocm, err := w.db.CommentByID(req.ID)
-			if err != nil {
-				return err
-			}
-			cm.Target.ID = ocm.ID
-		}
+		_, err = w.comment(ac, core.Target{Type: tp, ID: req.ID}, string(bytes))
+		return
+	}()
+
+	encoder.Encode(NResponce(err))
+}
+
+// comment adds comment of account to note or another comment
+func (w *WS) comment(ac core.Account, target core.Target, content string) (cm core.Comment, err error) {
+	act, err := w.db.TakeAction(ac.ID)
+	if err != nil {
+		return
+	}
+
+	cm = core.Comment{
+		Author:  ac.ID,
+		Note:    core.None,
+		Target:  target,
+		Content: content,
+	}
 
-		err = w.db.Comment(&cm)
+	switch target.Type {
+	case core.NoteT:
+		nt, err := w.db.NoteByID(target.ID)
 		if err != nil {
-			return
+			return cm, err
+		}
+		if !nt.Published {
+			return cm, ErrNotPublished
+		}
+		cm.Note = nt.ID
+	case core.CommentT:
+		ocm, err := w.db.CommentByID(target.ID)
+		if err != nil {
+			return cm, err
 		}
+		cm.Note = ocm.Note
+	}
 
-		return act()
-	}()
+	err = w.db.Comment(&cm)
+	if err != nil {
+		return
+	}
 
-	encoder.Encode(NResponce(err))
+	return cm, act()
 }
 
 // SaveNote creates new note if id == "new" it creates new note otherwise, it just updates data
@@ -404,37 +600,18 @@ func (w *WS) SaveNote(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var (
-		ac   core.Account
-		note = core.Note{
-			Year:    req.Year,
-			Month:   req.Month,
-			Name:    req.Name,
-			School:  mongo.School(req.School),
-			Subject: req.Subject,
-			Theme:   req.Theme,
-		}
-	)
-
+	var note core.Note
 	err := func() (err error) {
-		ac, err = w.GetAccountFromCookie(wr, r)
+		ac, err := AccountFrom(r, core.NotesS)
 		if err != nil {
 			return
 		}
 
-		var act = func() error { return nil }
-		if req.ID == core.None {
-			act, err = w.db.TakeAction(ac.ID)
+		if req.ID != core.None {
+			note, err = w.authorNote(ac, req.ID)
 			if err != nil {
 				return
 			}
-
-			note.Author = ac.ID
-		} else {
-			note, err = w.db.NoteByID(req.ID)
-			if err != nil {
-				return err
-			}
 		}
 
 		bytes, err := ioutil.ReadAll(r.Body)
@@ -442,19 +619,36 @@ func (w *WS) SaveNote(wr http.ResponseWriter, r *http.Request) {
 			return core.EI(err)
 		}
 
-		note.Content = string(bytes)
-
-		if req.ID == core.None {
-			err = w.db.Note(&note)
-		} else {
-			err = w.db.UpdateNote(&note)
+		tx, err := w.db.Taxonomy()
+		if err != nil {
+			return err
 		}
 
+		note.Year = req.Year
+		note.Month = req.Month
+		note.Name = req.Name
+		note.School, err = tx.SchoolCode(req.School)
 		if err != nil {
-			return
+			return err
+		}
+		note.Subject, note.Theme, err = tx.Canonical(note.School, req.Subject, req.Theme)
+		if err != nil {
+			return err
+		}
+		note.Content = string(bytes)
+		// clients older than tags do not send them and must not wipe them
+		if _, ok := r.URL.Query()["tags"]; ok {
+			note.Tags, err = core.ParseTags(req.Tags)
+			if err != nil {
+				return
+			}
+		}
+
+		if req.ID == core.None {
+			return w.createNote(ac, &note)
 		}
 
-		return act()
+		return w.db.UpdateNote(&note)
 	}()
 
 	encoder.Encode(SaveResponce{
@@ -463,6 +657,37 @@ func (w *WS) SaveNote(wr http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// createNote inserts new note of account, notes cannot be created too often
+func (w *WS) createNote(ac core.Account, note *core.Note) error {
+	act, err := w.db.TakeAction(ac.ID)
+	if err != nil {
+		return err
+	}
+
+	note.Author = ac.ID
+
+	err = w.db.Note(note)
+	if err != nil {
+		return err
+	}
+
+	return act()
+}
+
+// authorNote retrieves note but only if account is its author
+func (w *WS) authorNote(ac core.Account, id core.ID) (core.Note, error) {
+	nt, err := w.db.NoteByID(id)
+	if err != nil {
+		return nt, err
+	}
+
+	if nt.Author != ac.ID {
+		return core.Note{}, ErrIllegalNoteAccess
+	}
+
+	return nt, nil
+}
+
 // SetPublished alters publicity of note
 func (w *WS) SetPublished(wr http.ResponseWriter, r *http.Request) {
 	var req PublishRequest
@@ -472,7 +697,7 @@ func (w *WS) SetPublished(wr http.ResponseWriter, r *http.Request) {
 	}
 
 	err := func() (err error) {
-		ac, err := w.GetAccountFromCookie(wr, r)
+		ac, err := AccountFrom(r, core.NotesS)
 		if err != nil {
 			return
 		}
@@ -482,12 +707,7 @@ func (w *WS) SetPublished(wr http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = w.db.SetPublished(req.ID, req.Publish)
-		if err != nil {
-			panic(err)
-		}
-
-		return
+		return w.db.SetPublished(req.ID, req.Publish)
 	}()
 
 	encoder.Encode(NResponce(err))
@@ -521,7 +741,7 @@ func (w *WS) Note(wr http.ResponseWriter, r *http.Request, private bool) {
 		}
 
 		if private {
-			ac, err := w.GetAccountFromCookie(wr, r)
+			ac, err := AccountFrom(r, core.ReadS)
 			if err != nil {
 				return err
 			}
@@ -559,18 +779,72 @@ func (w *WS) UserNotes(wr http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SendVerifycationEmail creates the message and sends it to targeted account
+// SendVerifycationEmail creates the message and sends it to targeted account, if WS is
+// running email goes through the queue but the call still waits for delivery so
+// registrant learns when their code did not arrive
 func (w *WS) SendVerifycationEmail(account *core.Account) error {
-	message := FormatVerificationEmail(account.Code, account.Name)
-	return w.bot.Send(message, account.Email)
+	message, err := FormatVerificationEmail(w.cfg.Mail.Template, account.Code, account.Name)
+	if err != nil {
+		return err
+	}
+
+	if w.queue != nil {
+		err = w.queue.Deliver(message, account.Email)
+		if err != nil && !errors.Is(err, ErrSendEmail) {
+			return ErrSendEmail.Wrap(err)
+		}
+		return err
+	}
+
+	return w.deliver(message, account.Email)
+}
+
+// deliver sends email right away
+func (w *WS) deliver(message []byte, targets ...string) error {
+	err := w.bot.Send(message, targets...)
+	w.metrics.Email(err)
+	if err != nil {
+		return ErrSendEmail.Wrap(err)
+	}
+
+	return nil
 }
 
-// Run launches the WS, server will be running until this method exits ends
-func (w *WS) Run() {
-	fmt.Println("server listening on", w.targetAddress)
-	err := http.ListenAndServe(w.targetAddress, nil)
+// Attempt returns error if request sender or targeted account is not allowed to
+// authenticate yet
+func (w *WS) Attempt(r *http.Request, name string) error {
+	err := w.guard.Check(IPKey(RemoteIP(r)), w.cfg.Limits.IP)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	return w.guard.Check(AccountKey(name), w.cfg.Limits.Account)
+}
+
+// Failed records failed authentication of account and request sender, lockouts are audited
+func (w *WS) Failed(r *http.Request, name string) {
+	ip := RemoteIP(r)
+	for _, k := range []struct {
+		key string
+		l   Limit
+	}{
+		{AccountKey(name), w.cfg.Limits.Account},
+		{IPKey(ip), w.cfg.Limits.IP},
+	} {
+		locked, release := w.guard.Fail(k.key, k.l)
+		if !locked {
+			continue
+		}
+
+		err := w.db.Audit(&core.Audit{
+			Kind:    core.LockoutA,
+			Subject: k.key,
+			IP:      ip,
+			Until:   release.UnixNano() / int64(time.Millisecond),
+		})
+		if err != nil {
+			LoggerFrom(r).Error("failed to audit lockout", "subject", k.key, "err", err)
+		}
 	}
 }
 
@@ -589,6 +863,11 @@ func (w *WS) GetAccountFromCookie(wr http.ResponseWriter, r *http.Request) (ac c
 		}
 		return
 	}
+
+	// password alone is not enough when two factor authentication is on
+	if ac.TOTP.Enabled {
+		err = w.CheckSession(r, ac.ID)
+	}
 	return
 }
 
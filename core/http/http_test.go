@@ -3,11 +3,15 @@ package http
 import (
 	"encoding/json"
 	"myNotes/core"
+	"myNotes/core/config"
 	"myNotes/core/mongo"
+	"myNotes/core/totp"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestWSRegisterAccount(t *testing.T) {
@@ -58,7 +62,7 @@ func TestWSRegisterAccount(t *testing.T) {
 	}
 
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("register", tC.args, tC.result, ws.RegisterAccount))
+		t.Run(tC.desc, DoTest("register", tC.args, tC.result, ws.Handler()))
 	}
 }
 
@@ -122,7 +126,7 @@ func TestVerify(t *testing.T) {
 		},
 	}
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("verify", tC.args, tC.result, ws.VerifyAccount))
+		t.Run(tC.desc, DoTest("verify", tC.args, tC.result, ws.Handler()))
 	}
 }
 
@@ -176,7 +180,134 @@ func TestLogin(t *testing.T) {
 		},
 	}
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("login", tC.args, tC.result, ws.Login))
+		t.Run(tC.desc, DoTest("login", tC.args, tC.result, ws.Handler()))
+	}
+}
+
+func TestLoginTOTP(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	clock := &fakeClock{t: time.Unix(1111111111, 0)}
+	ws.now = clock.Now
+	ws.guard = NGuard(clock.Now)
+
+	ac := MakeVerifiedAccount(db)
+	secret, _ := totp.NSecret()
+	db.SetTOTP(ac.ID, core.TOTP{
+		Enabled:  true,
+		Secret:   secret,
+		Recovery: []string{core.Hash("recovery")},
+	})
+
+	code, _ := totp.Code(secret, clock.Now())
+
+	testCases := []struct {
+		desc    string
+		advance time.Duration
+		code    string
+		result  Responce
+	}{
+		{
+			desc:   "missing code",
+			result: Responce{ErrInvalidLogin.Wrap(ErrSecondStep).Error()},
+		},
+		{
+			desc:   "incorrect code",
+			code:   "000000",
+			result: Responce{ErrInvalidLogin.Wrap(ErrInvalidCode).Error()},
+		},
+		{
+			desc:    "successful",
+			advance: time.Second * 2,
+			code:    code,
+			result:  Responce{success},
+		},
+		{
+			desc:    "replayed code",
+			advance: time.Second * 2,
+			code:    code,
+			result:  Responce{ErrInvalidLogin.Wrap(ErrInvalidCode).Error()},
+		},
+		{
+			desc:    "recovery code",
+			advance: time.Second * 2,
+			code:    "recovery",
+			result:  Responce{success},
+		},
+		{
+			desc:    "used recovery code",
+			advance: time.Second * 2,
+			code:    "recovery",
+			result:  Responce{ErrInvalidLogin.Wrap(ErrInvalidCode).Error()},
+		},
+	}
+	for _, tC := range testCases {
+		clock.Advance(tC.advance)
+		args := url.Values{
+			"name":     {ac.Name},
+			"password": {ac.Password},
+		}
+		if tC.code != "" {
+			args["code"] = []string{tC.code}
+		}
+		t.Run(tC.desc, DoTest("login", args, tC.result, ws.Handler()))
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	ac := MakeVerifiedAccount(db)
+	db.Token(&core.APIToken{
+		Owner:  ac.ID,
+		Hash:   core.Hash("mn_read"),
+		Scopes: []core.Scope{core.ReadS},
+	})
+
+	testCases := []struct {
+		desc   string
+		auth   string
+		path   string
+		args   url.Values
+		result interface{}
+	}{
+		{
+			desc: "read",
+			auth: "Bearer mn_read",
+			path: "account",
+			result: AccountResponce{
+				Resp:    Responce{success},
+				Account: ac,
+			},
+		},
+		{
+			desc: "missing scope",
+			auth: "Bearer mn_read",
+			path: "configure",
+			args: url.Values{
+				"name":   {"name3"},
+				"colors": {""},
+			},
+			result: Responce{ErrMissingScope.Args(core.AccountS).Error()},
+		},
+		{
+			desc:   "invalid token",
+			auth:   "Bearer mn_other",
+			path:   "account",
+			result: AccountResponce{Resp: Responce{ErrInvalidToken.Error()}},
+		},
+		{
+			desc:   "invalid header",
+			auth:   "mn_read",
+			path:   "account",
+			result: AccountResponce{Resp: Responce{ErrInvalidAuthorization.Error()}},
+		},
+	}
+	for _, tC := range testCases {
+		header := http.Header{"Authorization": {tC.auth}}
+		t.Run(tC.desc, DoHeaderTest(tC.path, tC.args, tC.result, ws.Handler(), header))
 	}
 }
 
@@ -185,6 +316,8 @@ func TestAccount(t *testing.T) {
 	defer db.Cancel()
 
 	ac := MakeVerifiedAccount(db)
+	self := ac
+	self.HideCredentials()
 
 	testCases := []struct {
 		desc   string
@@ -194,12 +327,12 @@ func TestAccount(t *testing.T) {
 			desc: "success",
 			result: AccountResponce{
 				Resp:    Responce{success},
-				Account: ac,
+				Account: self,
 			},
 		},
 	}
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("account", url.Values{}, tC.result, ws.Account, ac.Cookie()))
+		t.Run(tC.desc, DoTest("account", url.Values{}, tC.result, ws.Handler(), ac.Cookie()))
 	}
 }
 
@@ -240,7 +373,7 @@ func TestConfig(t *testing.T) {
 		},
 	}
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("config", url.Values{}, tC.result, ws.Config, tC.cookie))
+		t.Run(tC.desc, DoTest("config", url.Values{}, tC.result, ws.Handler(), tC.cookie))
 	}
 }
 
@@ -282,25 +415,29 @@ func TestConfigure(t *testing.T) {
 	}
 
 	for _, tC := range testCases {
-		t.Run(tC.desc, DoTest("configure", tC.args, tC.result, ws.Configure, tC.cookie))
+		t.Run(tC.desc, DoTest("configure", tC.args, tC.result, ws.Handler(), tC.cookie))
 	}
 }
 
-func DoTest(callback string, args url.Values, result interface{}, call func(w http.ResponseWriter, r *http.Request), cookies ...http.Cookie) func(t *testing.T) {
+func DoTest(callback string, args url.Values, result interface{}, handler http.Handler, cookies ...http.Cookie) func(t *testing.T) {
+	return DoHeaderTest(callback, args, result, handler, http.Header{}, cookies...)
+}
+
+func DoHeaderTest(callback string, args url.Values, result interface{}, handler http.Handler, header http.Header, cookies ...http.Cookie) func(t *testing.T) {
 	return func(t *testing.T) {
 		rc := httptest.NewRecorder()
 		for _, c := range cookies {
 			http.SetCookie(rc, &c)
 		}
 
-		req, err := http.NewRequest("GET", "/"+callback+"?"+args.Encode(), nil)
+		req, err := http.NewRequest("POST", "/"+callback+"?"+args.Encode(), nil)
 		if err != nil {
 			panic(err)
 		}
 
+		req.Header = header.Clone()
 		req.Header["Cookie"] = rc.HeaderMap["Set-Cookie"]
-
-		handler := http.HandlerFunc(call)
+		withCSRF(req)
 
 		handler.ServeHTTP(rc, req)
 		if rc.Code != http.StatusOK {
@@ -345,9 +482,28 @@ func SetupTest() (*mongo.DB, *WS) {
 		panic(err)
 	}
 
-	bot := NEmailSender(BotAccount.Email, BotAccount.Password, 587)
+	cfg := testConfig()
+	bot := NEmailSender(cfg.Mail.Host, cfg.Mail.Sender, cfg.Mail.Password, cfg.Mail.Port)
 
-	ws := NWS("127.0.0.1", "./web", 3000, db, *bot)
+	ws := NWS(cfg, db, bot)
+	ws.RegisterHandlers()
 
 	return db, ws
 }
+
+// withCSRF attaches matching csrf cookie and header
+func withCSRF(req *http.Request) {
+	req.Header.Add("Cookie", CSRFCookie+"=token")
+	req.Header.Set(CSRFHeader, "token")
+}
+
+// testConfig is default configuration with frontend from this package, smtp account is
+// taken from environment
+func testConfig() config.Config {
+	cfg := config.Default()
+	cfg.Server.Port = 0
+	cfg.Features.Registration = true
+	cfg.Mail.Sender = os.Getenv(config.EnvPrefix + "MAIL_SENDER")
+	cfg.Mail.Password = os.Getenv(config.EnvPrefix + "MAIL_PASSWORD")
+	return cfg
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"io"
+	"myNotes/core"
+	"myNotes/core/importer"
+	"net/http"
+)
+
+// ImportPath is path of versioned api route that imports notes, it has larger body limit
+const ImportPath = "/me/notes/import"
+
+// APIImportNotes creates draft notes from uploaded files, whole import is one action so it
+// is rate limited the same way as creating single note
+func (w *WS) APIImportNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := uploadedFiles(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := importer.Convert(files)
+	if err != nil {
+		return nil, err
+	}
+
+	act, err := w.db.TakeAction(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ImportBody{Files: []ImportedFile{}}
+	for _, e := range entries {
+		if e.Err == nil {
+			e.Note.Author = ac.ID
+			e.Err = w.db.Note(&e.Note)
+		}
+
+		res := ImportedFile{File: e.File}
+		if e.Err != nil {
+			apiErr := NAPIError(e.Err)
+			res.Error = &apiErr
+		} else {
+			res.ID, res.Name = e.Note.ID, e.Note.Name
+		}
+		body.Files = append(body.Files, res)
+	}
+
+	return body, act()
+}
+
+// uploadedFiles reads files of multipart form field files
+func uploadedFiles(r *http.Request) ([]importer.File, error) {
+	err := r.ParseMultipartForm(MaxUploadSize)
+	if err != nil {
+		return nil, ErrInvalidBody.Wrap(err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var files []importer.File
+	for _, fh := range r.MultipartForm.File["files"] {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, ErrInvalidBody.Wrap(err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, ErrInvalidBody.Wrap(err)
+		}
+
+		files = append(files, importer.File{Name: fh.Filename, Data: data})
+	}
+
+	if len(files) == 0 {
+		return nil, ErrInvalidParam.Args("files")
+	}
+
+	return files, nil
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportFiles(t *testing.T) {
+	form := func(field string, names ...string) (string, *bytes.Buffer) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, n := range names {
+			w, _ := mw.CreateFormFile(field, n)
+			w.Write([]byte("# " + n))
+		}
+		mw.Close()
+		return mw.FormDataContentType(), &buf
+	}
+
+	testCases := []struct {
+		desc, field string
+		names       []string
+		contentType string
+		err         error
+	}{
+		{desc: "files", field: "files", names: []string{"a.md", "b.zip"}},
+		{desc: "other field", field: "file", names: []string{"a.md"}, err: ErrInvalidParam},
+		{desc: "not multipart", contentType: "application/json", err: ErrInvalidBody},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ct, body := form(tC.field, tC.names...)
+			if tC.contentType != "" {
+				ct = tC.contentType
+			}
+			r := httptest.NewRequest("POST", APIPrefix+ImportPath, body)
+			r.Header.Set("Content-Type", ct)
+
+			files, err := uploadedFiles(r)
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if tC.err != nil {
+				return
+			}
+
+			if len(files) != len(tC.names) {
+				t.Fatal(files)
+			}
+			for i, f := range files {
+				if f.Name != tC.names[i] || !strings.HasSuffix(string(f.Data), tC.names[i]) {
+					t.Error(f)
+				}
+			}
+		})
+	}
+}
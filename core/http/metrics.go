@@ -0,0 +1,141 @@
+package http
+
+import (
+	"myNotes/core/mongo"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsPath is path where prometheus scrapes metrics
+const MetricsPath = "/metrics"
+
+const namespace = "mynotes"
+
+// Metrics holds prometheus collectors of WS, each WS has its own registry so multiple
+// instances do not collide
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	db       *prometheus.HistogramVec
+	emails   *prometheus.CounterVec
+}
+
+// NMetrics creates metrics and registers them, if db is not nil its methods are timed and
+// totals are collected on every scrape
+func NMetrics(db *mongo.DB, now func() time.Time) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Handled requests by route and status.",
+		}, []string{"route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of requests by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		db: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_duration_seconds",
+			Help:      "Latency of database methods.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		emails: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "emails_total",
+			Help:      "Sent emails by result.",
+		}, []string{"result"}),
+	}
+
+	m.Registry.MustRegister(
+		m.requests, m.latency, m.db, m.emails,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if db != nil {
+		db.Observe = m.ObserveDB
+		m.Registry.MustRegister(&totals{db: db, now: now})
+	}
+
+	return m
+}
+
+// Middleware records count and latency of requests, it has to be placed outside of mux
+// so route is known after handler returns
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &StatusRecorder{ResponseWriter: wr}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(rec.Status())
+		m.requests.WithLabelValues(route, status).Inc()
+		m.latency.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveDB records duration of database method
+func (m *Metrics) ObserveDB(method string, took time.Duration) {
+	m.db.WithLabelValues(method).Observe(took.Seconds())
+}
+
+// Email counts email send attempt
+func (m *Metrics) Email(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.emails.WithLabelValues(result).Inc()
+}
+
+// Handler serves metrics in prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+var (
+	accountsDesc     = prometheus.NewDesc(namespace+"_accounts", "Number of accounts.", nil, nil)
+	notesDesc        = prometheus.NewDesc(namespace+"_notes", "Number of notes.", nil, nil)
+	totpSessionsDesc = prometheus.NewDesc(namespace+"_totp_sessions", "Number of unexpired sessions of accounts with two factor authentication.", nil, nil)
+)
+
+// totals queries database on scrape so values can not drift from the truth
+type totals struct {
+	db  *mongo.DB
+	now func() time.Time
+}
+
+func (t *totals) Describe(ch chan<- *prometheus.Desc) {
+	ch <- accountsDesc
+	ch <- notesDesc
+	ch <- totpSessionsDesc
+}
+
+func (t *totals) Collect(ch chan<- prometheus.Metric) {
+	accounts, notes, sessions, err := t.db.Totals(t.now().UnixNano() / int64(time.Millisecond))
+	if err != nil {
+		for _, d := range []*prometheus.Desc{accountsDesc, notesDesc, totpSessionsDesc} {
+			ch <- prometheus.NewInvalidMetric(d, err)
+		}
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(accountsDesc, prometheus.GaugeValue, float64(accounts))
+	ch <- prometheus.MustNewConstMetric(notesDesc, prometheus.GaugeValue, float64(notes))
+	ch <- prometheus.MustNewConstMetric(totpSessionsDesc, prometheus.GaugeValue, float64(sessions))
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	ws := NWS(testConfig(), nil, failingMailer{})
+	ws.RegisterHandlers()
+	handler := ws.Handler()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", APIPrefix+"/notes/abc", nil))
+	ws.SendVerifycationEmail(&core.Account{Name: "name", Email: "name@gmail.com"})
+
+	rc := httptest.NewRecorder()
+	handler.ServeHTTP(rc, httptest.NewRequest("GET", MetricsPath, nil))
+	body := rc.Body.String()
+
+	for _, line := range []string{
+		`mynotes_http_requests_total{route="GET /api/v1/notes/{id}",status="400"} 1`,
+		`mynotes_http_request_duration_seconds_count{route="GET /api/v1/notes/{id}",status="400"} 1`,
+		`mynotes_emails_total{result="failure"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Error(line)
+		}
+	}
+}
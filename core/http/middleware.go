@@ -0,0 +1,263 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"myNotes/core"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// Middleware wraps handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// RequestIDHeader carries request id, incoming value is reused if it looks sane
+const RequestIDHeader = "X-Request-ID"
+
+// errors produced by middleware
+var (
+	ErrMethodNotAllowed = sterr.New("method %s is not allowed")
+	ErrUnauthenticated  = sterr.New("route does not provide authentication")
+	ErrPanic            = sterr.New("internal server error")
+)
+
+var validRequestID = regexp.MustCompile(`^[\w\-]{1,64}$`)
+
+type (
+	requestIDKey struct{}
+	authKey      struct{}
+	logKey       struct{}
+)
+
+// Chain wraps handler with middleware, first middleware is the outermost
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// RequestID assigns id to every request, id is also sent back in responce header
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			var err error
+			id, err = core.RandomString(8)
+			if err != nil {
+				id = "unknown"
+			}
+		}
+
+		wr.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(wr, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom returns id assigned by RequestID middleware
+func RequestIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logging logs every request with its route, status, latency and account if request
+// was authenticated, handlers can reach request scoped logger with LoggerFrom
+func Logging(l *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rl := &requestLog{Logger: l.With("request_id", RequestIDFrom(r))}
+			rec := &StatusRecorder{ResponseWriter: wr}
+			r = r.WithContext(context.WithValue(r.Context(), logKey{}, rl))
+			next.ServeHTTP(rec, r)
+
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", r.Pattern,
+				"status", rec.Status(),
+				"latency", time.Since(start),
+			}
+			if rl.authenticated {
+				attrs = append(attrs, "account", rl.account)
+			}
+
+			level := slog.LevelInfo
+			if rec.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			rl.Log(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+type requestLog struct {
+	*slog.Logger
+
+	authenticated bool
+	account       core.ID
+}
+
+// LoggerFrom returns logger with request scoped fields, if request did not pass Logging
+// default logger is returned
+func LoggerFrom(r *http.Request) *slog.Logger {
+	if rl, ok := r.Context().Value(logKey{}).(*requestLog); ok {
+		return rl.Logger
+	}
+
+	return slog.Default()
+}
+
+// Recover turns panic inside handler into internal error, responce is json error object
+// with request id so the stack trace in logs can be paired with report of the client
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				LoggerFrom(r).Error("panic", "panic", err, "stack", string(debug.Stack()))
+				WriteError(wr, r, ErrPanic)
+			}
+		}()
+
+		next.ServeHTTP(wr, r)
+	})
+}
+
+// BodyLimit limits size of request body, limits override size for exact paths
+func BodyLimit(size int64, limits map[string]int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			limit, ok := limits[r.URL.Path]
+			if !ok {
+				limit = size
+			}
+			r.Body = http.MaxBytesReader(wr, r.Body, limit)
+			next.ServeHTTP(wr, r)
+		})
+	}
+}
+
+// Methods rejects requests with other methods
+func Methods(methods ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			for _, m := range methods {
+				if r.Method == m {
+					next.ServeHTTP(wr, r)
+					return
+				}
+			}
+
+			http.Error(wr, ErrMethodNotAllowed.Args(r.Method).Error(), http.StatusMethodNotAllowed)
+		})
+	}
+}
+
+// Authenticate injects authentication into request context, account is resolved from api
+// token or cookies when handler asks for it for the first time
+func (w *WS) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		a := &auth{}
+		a.resolve = func() {
+			a.ac, a.token, a.err = w.authenticate(wr, r)
+		}
+
+		next.ServeHTTP(wr, r.WithContext(context.WithValue(r.Context(), authKey{}, a)))
+	})
+}
+
+// RequireScope rejects requests that are not authenticated with scope before they reach
+// handler, empty scope means the route is public
+func RequireScope(scope core.Scope) Middleware {
+	return func(next http.Handler) http.Handler {
+		if scope == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			if _, err := AccountFrom(r, scope); err != nil {
+				WriteError(wr, r, err)
+				return
+			}
+			next.ServeHTTP(wr, r)
+		})
+	}
+}
+
+type auth struct {
+	once    sync.Once
+	resolve func()
+
+	ac    core.Account
+	token *core.APIToken
+	err   error
+}
+
+// AccountFrom returns authenticated account of request, requests authenticated by api token
+// also have to have the scope
+func AccountFrom(r *http.Request, scope core.Scope) (core.Account, error) {
+	a, ok := r.Context().Value(authKey{}).(*auth)
+	if !ok {
+		return core.Account{}, ErrUnauthenticated
+	}
+
+	a.once.Do(a.resolve)
+	if a.err != nil {
+		return core.Account{}, a.err
+	}
+
+	if a.token != nil && (scope == core.AccountS || !a.token.Allows(scope)) {
+		return core.Account{}, ErrMissingScope.Args(scope)
+	}
+
+	if rl, ok := r.Context().Value(logKey{}).(*requestLog); ok {
+		rl.authenticated, rl.account = true, a.ac.ID
+	}
+
+	return a.ac, nil
+}
+
+// StatusRecorder remembers status written to the responce
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (s *StatusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (s *StatusRecorder) Write(bts []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(bts)
+}
+
+// Status returns written status
+func (s *StatusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Unwrap allows http.ResponseController to reach underlying writer
+func (s *StatusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"myNotes/core"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bts, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(bts)
+	})
+
+	panics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+
+	testCases := []struct {
+		desc    string
+		handler http.Handler
+		method  string
+		body    string
+		status  int
+	}{
+		{"pass", Chain(echo, RequestID, Recover), "GET", "hello", http.StatusOK},
+		{"recover", Chain(panics, RequestID, Recover), "GET", "", http.StatusInternalServerError},
+		{"method", Chain(echo, Methods("POST")), "GET", "", http.StatusMethodNotAllowed},
+		{"body limit", Chain(echo, BodyLimit(4, nil)), "POST", "hello", http.StatusRequestEntityTooLarge},
+		{"body limit of path", Chain(echo, BodyLimit(4, map[string]int64{"/": 8})), "POST", "hello", http.StatusOK},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			tC.handler.ServeHTTP(rc, httptest.NewRequest(tC.method, "/", strings.NewReader(tC.body)))
+			if rc.Code != tC.status {
+				t.Error(rc.Code, tC.status, rc.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var id string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestIDFrom(r)
+	}))
+
+	testCases := []struct {
+		desc, header string
+		reused       bool
+	}{
+		{"generated", "", false},
+		{"reused", "abc-123", true},
+		{"invalid", "<script>", false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(RequestIDHeader, tC.header)
+			rc := httptest.NewRecorder()
+			h.ServeHTTP(rc, req)
+
+			if id == "" || rc.Header().Get(RequestIDHeader) != id || (id == tC.header) != tC.reused {
+				t.Error(id, rc.Header().Get(RequestIDHeader), tC.header)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	}), RequestID, Recover)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rc := httptest.NewRecorder()
+	h.ServeHTTP(rc, req)
+
+	var body ErrorBody
+	err := json.Unmarshal(rc.Body.Bytes(), &body)
+	if err != nil || rc.Code != http.StatusInternalServerError || body.Error.Code != "internal" || body.Error.RequestID != "abc-123" {
+		t.Error(err, rc.Code, rc.Body.String())
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := core.NLogger(&buf, core.JSONLog, slog.LevelInfo)
+
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.SetLogger(l)
+	ws.RegisterHandlers()
+
+	req := httptest.NewRequest("GET", APIPrefix+"/notes/abc", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	ws.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry struct {
+		RequestID string `json:"request_id"`
+		Route     string
+		Status    int
+	}
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil || entry.RequestID != "abc-123" || entry.Route != "GET "+APIPrefix+"/notes/{id}" || entry.Status != http.StatusBadRequest {
+		t.Error(err, buf.String())
+	}
+}
+
+// two servers used to share http.DefaultServeMux and registering second one panicked
+func TestWSCoexist(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		NWS(testConfig(), nil, &EmailSender{}).RegisterHandlers()
+	}
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	_ "embed"
+	"html/template"
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// NotePath is prefix of server rendered notes, full path of note is /n/{id}/{slug}
+const NotePath = "/n/"
+
+//go:embed note.html
+var notePage string
+
+var noteTemplate = template.Must(template.New("note").Parse(notePage))
+
+// NotePage is data of server rendered note
+type NotePage struct {
+	Note             core.Note
+	Labels           taxonomy.Labels
+	Author           string
+	Content          template.HTML
+	Description, URL string
+	Published, Nonce string
+	// Asset resolves hashed url of stylesheet or script so it can be cached forever
+	Asset func(name string) string
+}
+
+// NoteURL returns canonical path of server rendered note
+func NoteURL(nt core.Note) string {
+	return NotePath + strconv.FormatUint(nt.ID, 10) + "/" + core.Slug(nt.Name)
+}
+
+// ViewNote renders published note on server so it is readable by search engines and
+// without javascript, requests with outdated or missing slug are redirected to canonical url
+func (w *WS) ViewNote(wr http.ResponseWriter, r *http.Request) {
+	err := func() error {
+		id, err := PathID(r)
+		if err != nil {
+			return err
+		}
+
+		nt, err := w.db.NoteByID(id)
+		if err != nil {
+			return err
+		}
+		if !nt.Published {
+			return ErrNotPublished
+		}
+
+		if canonical := NoteURL(nt); r.URL.Path != canonical {
+			http.Redirect(wr, r, canonical, http.StatusMovedPermanently)
+			return nil
+		}
+
+		author, err := w.db.AccountByID(nt.Author)
+		if err != nil {
+			return err
+		}
+
+		labels, err := w.labeler(r)
+		if err != nil {
+			return err
+		}
+		w.countView(r, nt)
+
+		return w.writeNote(wr, r, nt, author, labels(nt))
+	}()
+	if err != nil {
+		_, status := Code(err)
+		if status == http.StatusInternalServerError {
+			LoggerFrom(r).Error("failed to render note", "err", err)
+		}
+		http.Error(wr, err.Error(), status)
+	}
+}
+
+func (w *WS) writeNote(wr http.ResponseWriter, r *http.Request, nt core.Note, author core.Account, labels taxonomy.Labels) error {
+	markup := core.NMarkup(author.Cfg.Colors)
+
+	scheme := "http"
+	if r.TLS != nil || w.cfg.Server.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	// notes published before publish date was tracked only have their creation date
+	published := nt.PublishDate
+	if published == 0 {
+		published = nt.BornDate
+	}
+
+	page := NotePage{
+		Note:        nt,
+		Author:      author.Name,
+		Content:     template.HTML(markup.Render(nt.Content)),
+		Description: excerpt(markup.Plain(nt.Content), 160),
+		URL:         scheme + "://" + r.Host + NoteURL(nt),
+		Published:   time.UnixMilli(published).UTC().Format(time.RFC3339),
+		Nonce:       NonceFrom(r),
+		Labels:      labels,
+		Asset:       w.fs.URL,
+	}
+
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	return noteTemplate.Execute(wr, page)
+}
+
+// excerpt returns first limit characters of text with whitespace collapsed
+func excerpt(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	text = string([]rune(text)[:limit])
+	if i := strings.LastIndexByte(text, ' '); i > 0 {
+		text = text[:i]
+	}
+	return text + "…"
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"encoding/json"
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestViewNote(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	nt := core.Note{
+		ID:        42,
+		Author:    7,
+		Name:      "Linear Algebra",
+		Subject:   "math",
+		School:    3,
+		Published: true,
+		Content:   "<t>Vectors<t>\n<1>red<1> <script>alert(1)</script>",
+		Tags:      []string{"linear-algebra", "c++"},
+	}
+	author := core.Account{Name: "bob", Cfg: core.Config{Colors: []string{"#123456"}}}
+
+	rc := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", NoteURL(nt), nil)
+	tx, _ := taxonomy.New(taxonomy.Defaults())
+	err := ws.writeNote(rc, req, nt, author, tx.Labels(nt, "cs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := rc.Body.String()
+
+	testCases := []struct {
+		desc, contains string
+	}{
+		{desc: "rendered", contains: `<span class="title">Vectors</span><br><hr><span class="base" style="color: #123456;">red</span>`},
+		{desc: "escaped", contains: `&lt;script&gt;alert(1)&lt;/script&gt;`},
+		{desc: "canonical", contains: `<link rel="canonical" href="http://example.com/n/42/linear-algebra">`},
+		{desc: "open graph", contains: `<meta property="og:title" content="Linear Algebra">`},
+		{desc: "description", contains: `<meta property="og:description" content="Vectors red &lt;script&gt;alert(1)&lt;/script&gt;">`},
+		{desc: "author", contains: `<a href="/account.html?id=7" rel="author">bob</a>`},
+		{desc: "school", contains: `vysoká škola`},
+		{desc: "subject", contains: `<meta property="article:section" content="math">`},
+		{desc: "enhanced", contains: `<body data-note="42">`},
+		{desc: "tags", contains: `<a href="/index.html?tags=linear-algebra" rel="tag">#linear-algebra</a> <a href="/index.html?tags=c%2b%2b" rel="tag">#c&#43;&#43;</a>`},
+		{desc: "hashed stylesheet", contains: `<link rel="stylesheet" href="` + ws.fs.URL("/stiles/markdown.css") + `">`},
+		{desc: "hashed script", contains: `src="` + ws.fs.URL("/view.js") + `"`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if !strings.Contains(body, tC.contains) {
+				t.Error(body)
+			}
+		})
+	}
+
+	if strings.Contains(body, "<script>alert") {
+		t.Error("content is not escaped")
+	}
+	if strings.Contains(body, `src="/tools/general.js"`) {
+		t.Error("script is linked without hash")
+	}
+}
+
+func TestViewNotePublished(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	born := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli()
+	published := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC).UnixMilli()
+
+	testCases := []struct {
+		desc     string
+		nt       core.Note
+		expected string
+	}{
+		{desc: "publish date", nt: core.Note{BornDate: born, PublishDate: published}, expected: "2025-06-07T08:09:10Z"},
+		{desc: "untracked publish date", nt: core.Note{BornDate: born}, expected: "2024-01-02T03:04:05Z"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			err := ws.writeNote(rc, httptest.NewRequest("GET", NoteURL(tC.nt), nil), tC.nt, core.Account{}, taxonomy.Labels{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta := `<meta property="article:published_time" content="` + tC.expected + `">`; !strings.Contains(rc.Body.String(), meta) {
+				t.Error(rc.Body.String())
+			}
+		})
+	}
+}
+
+func TestViewNoteInvalidID(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+
+	rc := httptest.NewRecorder()
+	ws.Handler().ServeHTTP(rc, httptest.NewRequest("GET", "/n/abc/slug", nil))
+	if rc.Code != http.StatusBadRequest {
+		t.Error(rc.Code, rc.Body.String())
+	}
+}
+
+func TestSaveNoteTags(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	handler := ws.Handler()
+	ac := MakeVerifiedAccount(db)
+
+	nt := core.Note{Author: ac.ID, Name: "note", Tags: []string{"math"}}
+	db.Note(&nt)
+
+	testCases := []struct {
+		desc, query string
+		expected    []string
+	}{
+		{desc: "absent", query: "", expected: []string{"math"}},
+		{desc: "replaced", query: "&tags=algebra,geometry", expected: []string{"algebra", "geometry"}},
+		{desc: "cleared", query: "&tags=", expected: nil},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := "/save?id=" + strconv.FormatUint(nt.ID, 10) + "&name=note&school=&theme=&subject=&year=0&month=0" + tC.query
+			req := httptest.NewRequest("POST", path, strings.NewReader("content"))
+			req.AddCookie(cookie(ac))
+			withCSRF(req)
+
+			rc := httptest.NewRecorder()
+			handler.ServeHTTP(rc, req)
+			var res SaveResponce
+			json.NewDecoder(rc.Body).Decode(&res)
+			if res.Resp != (Responce{success}) {
+				t.Fatal(rc.Code, res)
+			}
+
+			saved, err := db.NoteByID(nt.ID)
+			if err != nil || len(saved.Tags) != len(tC.expected) {
+				t.Fatal(saved.Tags, err)
+			}
+			for i, tag := range tC.expected {
+				if saved.Tags[i] != tag {
+					t.Error(saved.Tags)
+				}
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	testCases := []struct {
+		desc, in, out string
+		limit         int
+	}{
+		{desc: "short", in: "a  b\nc", out: "a b c", limit: 10},
+		{desc: "cut at word", in: "hello there world", out: "hello…", limit: 8},
+		{desc: "single word", in: "ěščřžýáíé", out: "ěšč…", limit: 3},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if out := excerpt(tC.in, tC.limit); out != tC.out {
+				t.Error(out)
+			}
+		})
+	}
+}
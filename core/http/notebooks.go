@@ -0,0 +1,351 @@
+package http
+
+import (
+	_ "embed"
+	"html/template"
+	"myNotes/core"
+	"net/http"
+	"strconv"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// NotebookPath is prefix of server rendered notebooks, full path of notebook is /b/{id}/{slug}
+const NotebookPath = "/b/"
+
+// ErrIllegalNotebookAccess is returned when account changes notebook of other account
+var ErrIllegalNotebookAccess = sterr.New("you are not an owner of this notebook so you cannot change it")
+
+//go:embed notebook.html
+var notebookPage string
+
+var notebookTemplate = template.Must(template.New("notebook").Parse(notebookPage))
+
+// NotebookPage is data of server rendered notebook
+type NotebookPage struct {
+	Notebook               core.Notebook
+	Author, URL            string
+	Path, Notebooks, Notes []Link
+	// Asset resolves hashed url of stylesheet so it can be cached forever
+	Asset func(name string) string
+}
+
+// Link is item of server rendered notebook, Notes is amount of notes in linked notebook
+type Link struct {
+	Name, URL string
+	Notes     int
+}
+
+// NotebookURL returns canonical path of server rendered notebook
+func NotebookURL(nb core.Notebook) string {
+	return NotebookPath + strconv.FormatUint(nb.ID, 10) + "/" + core.Slug(nb.Name)
+}
+
+// APIMyNotebooks lists the root of notebooks of authenticated account
+func (w *WS) APIMyNotebooks(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ac, err := AccountFrom(r, core.ReadS)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := w.shelf(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shelfBody(s, 0, false), nil
+}
+
+// APINotebook lists notebook, only published notebooks and notes are listed to anybody
+// except owner
+func (w *WS) APINotebook(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := w.db.NotebookByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.ReadS)
+	own := err == nil && ac.ID == nb.Owner
+	if !own && !nb.Published {
+		return nil, ErrNotPublished
+	}
+
+	s, err := w.shelf(nb.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return shelfBody(s, id, !own), nil
+}
+
+// APICreateNotebook ...
+func (w *WS) APICreateNotebook(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req NotebookBody
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := w.shelf(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Len() >= core.MaxNotebooks {
+		return nil, core.ErrNotebookLimit.Args(core.MaxNotebooks)
+	}
+
+	nb := core.Notebook{Owner: ac.ID}
+	if req.Name == nil {
+		return nil, ErrInvalidParam.Args("name")
+	}
+	err = req.Apply(&nb, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// new notebook is empty so there is nothing else to publish
+	err = w.db.Notebook(&nb)
+	if err != nil {
+		return nil, err
+	}
+
+	return IDBody{ID: nb.ID}, nil
+}
+
+// APIUpdateNotebook renames, moves or publishes notebook, publicity is changed for nested
+// notebooks as well, notes keep their own publicity
+func (w *WS) APIUpdateNotebook(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req NotebookBody
+	err = Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := w.ownNotebook(ac, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := w.shelf(ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	published := nb.Published
+	err = req.Apply(&nb, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if nb.Published != published {
+		err = w.db.PublishNotebooks(s.Descendants(nb.ID), nb.Published)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.db.UpdateNotebook(&nb)
+	if err != nil {
+		return nil, err
+	}
+
+	return nb, nil
+}
+
+// APIDeleteNotebook deletes notebook, its contents are moved to its parent
+func (w *WS) APIDeleteNotebook(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := w.ownNotebook(ac, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.db.DeleteNotebook(nb)
+}
+
+// APIMoveNote moves note to notebook, publicity of note does not change
+func (w *WS) APIMoveNote(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id, err := PathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req MoveBody
+	err = Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := AccountFrom(r, core.NotesS)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.authorNote(ac, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Notebook != 0 {
+		_, err = w.ownNotebook(ac, req.Notebook)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, w.db.MoveNote(id, req.Notebook)
+}
+
+// ViewNotebook renders published notebook on server as list of its published notebooks
+// and notes, requests with outdated or missing slug are redirected to canonical url
+func (w *WS) ViewNotebook(wr http.ResponseWriter, r *http.Request) {
+	err := func() error {
+		id, err := PathID(r)
+		if err != nil {
+			return err
+		}
+
+		nb, err := w.db.NotebookByID(id)
+		if err != nil {
+			return err
+		}
+		if !nb.Published {
+			return ErrNotPublished
+		}
+
+		if canonical := NotebookURL(nb); r.URL.Path != canonical {
+			http.Redirect(wr, r, canonical, http.StatusMovedPermanently)
+			return nil
+		}
+
+		author, err := w.db.AccountByID(nb.Owner)
+		if err != nil {
+			return err
+		}
+
+		s, err := w.shelf(nb.Owner)
+		if err != nil {
+			return err
+		}
+
+		return w.writeNotebook(wr, r, s, nb, author)
+	}()
+	if err != nil {
+		_, status := Code(err)
+		if status == http.StatusInternalServerError {
+			LoggerFrom(r).Error("failed to render notebook", "err", err)
+		}
+		http.Error(wr, err.Error(), status)
+	}
+}
+
+func (w *WS) writeNotebook(wr http.ResponseWriter, r *http.Request, s *core.Shelf, nb core.Notebook, author core.Account) error {
+	scheme := "http"
+	if r.TLS != nil || w.cfg.Server.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	body := shelfBody(s, nb.ID, true)
+	page := NotebookPage{
+		Notebook:  nb,
+		Author:    author.Name,
+		URL:       scheme + "://" + r.Host + NotebookURL(nb),
+		Path:      []Link{},
+		Notebooks: []Link{},
+		Notes:     []Link{},
+		Asset:     w.fs.URL,
+	}
+	for _, p := range body.Path {
+		if p.ID != nb.ID {
+			page.Path = append(page.Path, Link{Name: p.Name, URL: NotebookURL(p)})
+		}
+	}
+	for _, e := range body.Notebooks {
+		page.Notebooks = append(page.Notebooks, Link{Name: e.Name, URL: NotebookURL(e.Notebook), Notes: e.Notes})
+	}
+	for _, nt := range body.Notes {
+		page.Notes = append(page.Notes, Link{Name: nt.Name, URL: NoteURL(core.Note{ID: nt.ID, Name: nt.Name})})
+	}
+
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	return notebookTemplate.Execute(wr, page)
+}
+
+// shelf loads all notebooks and notes of account
+func (w *WS) shelf(owner core.ID) (*core.Shelf, error) {
+	nbs, err := w.db.UserNotebooks(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var nts []core.Draft
+	err = w.db.UserNotes(owner, &nts)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NShelf(nbs, nts), nil
+}
+
+// ownNotebook retrieves notebook but only if account is its owner
+func (w *WS) ownNotebook(ac core.Account, id core.ID) (core.Notebook, error) {
+	nb, err := w.db.NotebookByID(id)
+	if err != nil {
+		return nb, err
+	}
+
+	if nb.Owner != ac.ID {
+		return core.Notebook{}, ErrIllegalNotebookAccess
+	}
+
+	return nb, nil
+}
+
+// shelfBody lists notebook of shelf, when published is true unpublished ancestors are
+// left out of the path as well so their names do not leak
+func shelfBody(s *core.Shelf, id core.ID, published bool) ShelfBody {
+	body := ShelfBody{Path: s.Path(id)}
+	body.Notebooks, body.Notes = s.Contents(id, published)
+
+	if published {
+		for i := len(body.Path) - 1; i >= 0; i-- {
+			if !body.Path[i].Published {
+				body.Path = body.Path[i+1:]
+				break
+			}
+		}
+	}
+
+	return body
+}
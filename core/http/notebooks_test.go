@@ -0,0 +1,53 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestViewNotebook(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	nbs := []core.Notebook{
+		{ID: 1, Owner: 7, Name: "Private"},
+		{ID: 2, Owner: 7, Parent: 1, Name: "Math", Published: true},
+		{ID: 3, Owner: 7, Parent: 2, Name: "Algebra", Published: true},
+		{ID: 4, Owner: 7, Parent: 2, Name: "Drafts"},
+	}
+	s := core.NShelf(nbs, []core.Draft{
+		{ID: 10, Notebook: 2, Name: "Vectors", Published: true},
+		{ID: 11, Notebook: 2, Name: "Unfinished"},
+		{ID: 12, Notebook: 3, Name: "Matrices", Published: true},
+	})
+
+	rc := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", NotebookURL(nbs[1]), nil)
+	err := ws.writeNotebook(rc, req, s, nbs[1], core.Account{Name: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := rc.Body.String()
+
+	testCases := []struct {
+		desc, contains string
+		missing        bool
+	}{
+		{desc: "canonical", contains: `<link rel="canonical" href="http://example.com/b/2/math">`},
+		{desc: "notebook", contains: `<li><a href="/b/3/algebra">Algebra</a> (1)</li>`},
+		{desc: "note", contains: `<li><a href="/n/10/vectors">Vectors</a></li>`},
+		{desc: "author", contains: `<a href="/account.html?id=7" rel="author">bob</a>`},
+		{desc: "unpublished notebook", contains: "Drafts", missing: true},
+		{desc: "unpublished note", contains: "Unfinished", missing: true},
+		{desc: "unpublished parent", contains: "Private", missing: true},
+		{desc: "hashed stylesheet", contains: `<link rel="stylesheet" href="` + ws.fs.URL("/stiles/view.css") + `">`},
+		{desc: "unhashed stylesheet", contains: `href="/stiles/view.css"`, missing: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if strings.Contains(body, tC.contains) == tC.missing {
+				t.Error(body)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package http
+
+import (
+	"encoding/json"
+	"myNotes/core/export"
+	"myNotes/core/taxonomy"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIPath is well known path where specification is served
+const OpenAPIPath = APIPrefix + "/openapi.json"
+
+// Spec is a json object of OpenAPI document
+type Spec = map[string]interface{}
+
+var pathParam = regexp.MustCompile(`{(\w+)}`)
+
+// OpenAPI generates OpenAPI 3 document from route tables, schemas are derived from request
+// and responce types
+func (w *WS) OpenAPI() Spec {
+	g := specGen{schemas: Spec{}, types: map[string]reflect.Type{}}
+	paths := Spec{}
+
+	routes := w.Routes()
+	shared := map[string]int{}
+	for _, rt := range routes {
+		shared[operationID("", rt.Handler)]++
+	}
+
+	for _, rt := range routes {
+		path := APIPrefix + rt.Path
+		item, ok := paths[path].(Spec)
+		if !ok {
+			item = Spec{}
+			paths[path] = item
+		}
+
+		id := operationID("", rt.Handler)
+		if shared[id] > 1 {
+			id = operationID(rt.Method, rt.Handler)
+		}
+
+		op := Spec{
+			"operationId": id,
+			"summary":     rt.Summary,
+			"responses":   g.responses(rt.Status, rt.Resp),
+		}
+
+		var params []interface{}
+		for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+			schema := Spec{"type": "string"}
+			switch m[1] {
+			case "id":
+				schema = Spec{"type": "integer", "format": "int64", "minimum": 0}
+			case "format":
+				schema = Spec{"type": "string", "enum": []string{export.HTML, export.Markdown, export.PDF}}
+			case "kind":
+				schema = Spec{"type": "string", "enum": taxonomy.Kinds}
+			}
+			params = append(params, Spec{"name": m[1], "in": "path", "required": true, "schema": schema})
+		}
+		if rt.Query != nil {
+			params = append(params, g.query(reflect.TypeOf(rt.Query), false)...)
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		switch rt.Req.(type) {
+		case nil:
+		case Upload:
+			files := Spec{"type": "array", "items": Spec{"type": "string", "format": "binary"}}
+			op["requestBody"] = Spec{
+				"required": true,
+				"content": Spec{"multipart/form-data": Spec{"schema": Spec{
+					"type": "object", "required": []string{"files"}, "properties": Spec{"files": files},
+				}}},
+			}
+		default:
+			op["requestBody"] = Spec{
+				"required": true,
+				"content":  Spec{"application/json": Spec{"schema": g.schema(reflect.TypeOf(rt.Req))}},
+			}
+		}
+
+		if rt.Scope != "" {
+			op["security"] = security(string(rt.Scope))
+		}
+
+		item[methodKey(rt.Method)] = op
+	}
+
+	for _, rt := range w.Legacy() {
+		item := Spec{}
+		paths[rt.Path] = item
+
+		// routes that are not post only accept parameters over get as well
+		methods := []string{"get", "post"}
+		if rt.Post {
+			methods = methods[1:]
+		}
+		for _, method := range methods {
+			id := operationID("", rt.Handler)
+			if len(methods) > 1 {
+				id = operationID(method, rt.Handler)
+			}
+
+			op := Spec{
+				"operationId": id,
+				"tags":        []string{"legacy"},
+				"parameters":  g.query(reflect.TypeOf(rt.Req), true),
+				"responses":   g.responses(http.StatusOK, rt.Resp),
+			}
+
+			if rt.Text {
+				op["requestBody"] = Spec{
+					"content": Spec{"text/plain": Spec{"schema": Spec{"type": "string"}}},
+				}
+			}
+
+			item[method] = op
+		}
+	}
+
+	return Spec{
+		"openapi": "3.0.3",
+		"info": Spec{
+			"title":   "myNotes",
+			"version": "1",
+			"description": "routes under " + APIPrefix + " are versioned api, routes tagged legacy take parameters from query and always respond with status 200, " +
+				"requests with unsafe method that are not authenticated by bearer token have to repeat value of " + CSRFCookie + " cookie in " + CSRFHeader + " header",
+		},
+		"paths": paths,
+		"components": Spec{
+			"schemas": g.schemas,
+			"securitySchemes": Spec{
+				"cookie": Spec{"type": "apiKey", "in": "cookie", "name": "user"},
+				"bearer": Spec{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// ServeOpenAPI responds with generated OpenAPI document
+func (w *WS) ServeOpenAPI(wr http.ResponseWriter, r *http.Request) {
+	bts, err := json.Marshal(w.OpenAPI())
+	if err != nil {
+		InternalErr(wr, err.Error())
+		return
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	wr.Write(bts)
+}
+
+func methodKey(method string) string {
+	return strings.ToLower(method)
+}
+
+func security(scope string) []interface{} {
+	return []interface{}{
+		Spec{"cookie": []string{}},
+		Spec{"bearer": []string{scope}},
+	}
+}
+
+// operationID is derived from name of handler method so renaming handler changes the spec,
+// handlers serving several methods are told apart by method prefix
+func operationID(method string, handler interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	name = name[strings.LastIndex(name, ".")+1:]
+	if method != "" {
+		name = strings.ToLower(method) + strings.TrimPrefix(name, "API")
+	}
+	return name
+}
+
+type specGen struct {
+	schemas Spec
+	types   map[string]reflect.Type
+}
+
+func (g *specGen) responses(status int, resp interface{}) Spec {
+	res := Spec{
+		"default": Spec{
+			"description": "error",
+			"content":     Spec{"application/json": Spec{"schema": g.schema(reflect.TypeOf(ErrorBody{}))}},
+		},
+	}
+
+	switch resp.(type) {
+	case nil:
+		if status == 0 {
+			status = http.StatusNoContent
+		}
+		res[code(status)] = Spec{"description": http.StatusText(status)}
+	case File:
+		content := Spec{}
+		for _, ct := range export.ContentTypes {
+			content[ct] = Spec{"schema": Spec{"type": "string", "format": "binary"}}
+		}
+		res[code(http.StatusOK)] = Spec{"description": "downloaded file", "content": content}
+	case PNG:
+		res[code(http.StatusOK)] = Spec{
+			"description": "png image",
+			"content":     Spec{"image/png": Spec{"schema": Spec{"type": "string", "format": "binary"}}},
+		}
+	default:
+		if status == 0 {
+			status = http.StatusOK
+		}
+		res[code(status)] = Spec{
+			"description": http.StatusText(status),
+			"content":     Spec{"application/json": Spec{"schema": g.schema(reflect.TypeOf(resp))}},
+		}
+	}
+
+	return res
+}
+
+// query describes fields of struct as query parameters, names are lower cased same as urlp
+// does unless query tag sets them, parameters of legacy requests are required unless they
+// are marked optional for urlp, others are always optional
+func (g *specGen) query(t reflect.Type, legacy bool) []interface{} {
+	params := []interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("query")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		params = append(params, Spec{
+			"name":     name,
+			"in":       "query",
+			"required": legacy && f.Tag.Get("urlp") != "optional",
+			"schema":   g.schema(f.Type),
+		})
+	}
+	return params
+}
+
+func (g *specGen) schema(t reflect.Type) Spec {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := Spec{}
+		for k, v := range g.schema(t.Elem()) {
+			s[k] = v
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return Spec{"type": "string"}
+	case reflect.Bool:
+		return Spec{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return Spec{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return Spec{"type": "integer", "format": "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Spec{"type": "integer", "format": "int32", "minimum": 0}
+	case reflect.Uint, reflect.Uint64:
+		return Spec{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Spec{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return Spec{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Spec{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+
+		name := schemaName(t)
+		if other, ok := g.types[name]; !ok {
+			g.types[name] = t
+			g.schemas[name] = Spec{} // recursion guard
+			g.schemas[name] = g.object(t)
+		} else if other != t {
+			panic("schema " + name + " is used by " + t.PkgPath() + " and " + other.PkgPath())
+		}
+		return Spec{"$ref": "#/components/schemas/" + name}
+	}
+
+	return Spec{}
+}
+
+func (g *specGen) object(t reflect.Type) Spec {
+	props := g.properties(t)
+	obj := Spec{"type": "object", "properties": props}
+	if len(props) == 0 {
+		delete(obj, "properties")
+	}
+
+	return obj
+}
+
+// properties describes json fields of struct, fields of embedded structs without json name
+// are promoted into it same as encoding/json does, own fields win over promoted ones
+func (g *specGen) properties(t reflect.Type) Spec {
+	props, promoted := Spec{}, Spec{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range g.properties(ft) {
+				promoted[k] = v
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+
+	for k, v := range promoted {
+		if _, ok := props[k]; !ok {
+			props[k] = v
+		}
+	}
+
+	return props
+}
+
+// schemaName qualifies name of type with name of its package so types of same name from
+// different packages do not share schema
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
+
+func code(status int) string {
+	return strconv.Itoa(status)
+}
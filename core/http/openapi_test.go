@@ -0,0 +1,310 @@
+package http
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"myNotes/core"
+	"myNotes/core/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json with generated specification")
+
+// openapi.json is committed so changes of request and responce types show up in review,
+// run go test -run TestOpenAPI -update after changing them
+func TestOpenAPI(t *testing.T) {
+	ws := &WS{}
+	bts, err := json.MarshalIndent(ws.OpenAPI(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bts = append(bts, '\n')
+
+	if *update {
+		err = ioutil.WriteFile("openapi.json", bts, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(expected) != string(bts) {
+		t.Error("openapi.json is out of date with route tables, run go test -run TestOpenAPI -update")
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	ws := &WS{}
+	paths := ws.OpenAPI()["paths"].(Spec)
+
+	for _, rt := range ws.Routes() {
+		item, ok := paths[APIPrefix+rt.Path].(Spec)
+		if !ok || item[methodKey(rt.Method)] == nil {
+			t.Error("missing", rt.Method, rt.Path)
+		}
+	}
+
+	for _, rt := range ws.Legacy() {
+		item, _ := paths[rt.Path].(Spec)
+		if item["post"] == nil || (!rt.Post && item["get"] == nil) {
+			t.Error("missing", rt.Path)
+		}
+	}
+
+	ids := map[string]bool{}
+	for path, item := range paths {
+		for method, op := range item.(Spec) {
+			id := op.(Spec)["operationId"].(string)
+			if ids[id] {
+				t.Error("duplicate operation id", id, method, path)
+			}
+			ids[id] = true
+		}
+	}
+}
+
+func TestSchemaNames(t *testing.T) {
+	g := specGen{schemas: Spec{}, types: map[string]reflect.Type{}}
+
+	testCases := []struct {
+		desc     string
+		value    interface{}
+		expected string
+	}{
+		{desc: "core", value: core.Config{}, expected: "core.Config"},
+		{desc: "same name", value: config.Config{}, expected: "config.Config"},
+		{desc: "this package", value: LikeBody{}, expected: "http.LikeBody"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ref := g.schema(reflect.TypeOf(tC.value))["$ref"]
+			if ref != "#/components/schemas/"+tC.expected || g.schemas[tC.expected] == nil {
+				t.Error(ref)
+			}
+		})
+	}
+
+	// schema of anonymous struct is inlined
+	if s := g.schema(reflect.TypeOf(struct{ A int }{})); s["type"] != "object" {
+		t.Error(s)
+	}
+}
+
+func TestResponseSchemas(t *testing.T) {
+	ws := &WS{}
+	spec := ws.OpenAPI()
+
+	for _, rt := range ws.Routes() {
+		schema := responseSchema(spec, rt)
+		if schema == nil {
+			continue
+		}
+		t.Run(rt.Method+" "+rt.Path, func(t *testing.T) {
+			// every slice, map and pointer is filled so whole schema is checked
+			value := sample(reflect.TypeOf(rt.Resp), 0).Interface()
+			if err := validateJSON(spec, schema, value); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// responseSchema returns schema of successful json responce of route, nil if route does
+// not respond with json
+func responseSchema(spec Spec, rt Route) Spec {
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	op := spec["paths"].(Spec)[APIPrefix+rt.Path].(Spec)[methodKey(rt.Method)].(Spec)
+	resp, _ := op["responses"].(Spec)[code(status)].(Spec)
+	content, _ := resp["content"].(Spec)
+	media, _ := content["application/json"].(Spec)
+	schema, _ := media["schema"].(Spec)
+	return schema
+}
+
+// validateJSON encodes value as json and checks it against schema, properties schema does
+// not know about and values of wrong type are reported
+func validateJSON(spec Spec, schema Spec, value interface{}) error {
+	bts, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(bts, &decoded); err != nil {
+		return err
+	}
+
+	return validate(spec, schema, decoded, "$")
+}
+
+func validate(spec Spec, schema Spec, v interface{}, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		schema = spec["components"].(Spec)["schemas"].(Spec)[name].(Spec)
+	}
+
+	tp, _ := schema["type"].(string)
+	if v == nil {
+		// encoding/json writes nil slices, maps and pointers as null
+		if tp == "" || tp == "array" || tp == "object" || schema["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not %s", at, tp)
+	}
+
+	ok := true
+	switch tp {
+	case "object":
+		var m map[string]interface{}
+		if m, ok = v.(map[string]interface{}); !ok {
+			break
+		}
+		props, _ := schema["properties"].(Spec)
+		extra, _ := schema["additionalProperties"].(Spec)
+		for k, val := range m {
+			s, known := props[k].(Spec)
+			if !known {
+				if extra == nil {
+					return fmt.Errorf("%s: property %s is not documented", at, k)
+				}
+				s = extra
+			}
+			if err := validate(spec, s, val, at+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		var items []interface{}
+		if items, ok = v.([]interface{}); !ok {
+			break
+		}
+		for i, item := range items {
+			if err := validate(spec, schema["items"].(Spec), item, at+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		_, ok = v.(string)
+	case "boolean":
+		_, ok = v.(bool)
+	case "number":
+		_, ok = v.(float64)
+	case "integer":
+		var f float64
+		f, ok = v.(float64)
+		ok = ok && f == math.Trunc(f)
+	}
+
+	if !ok {
+		return fmt.Errorf("%s: %v is not %s", at, v, tp)
+	}
+	return nil
+}
+
+// sample creates value of type with every field set, depth stops recursive types
+func sample(t reflect.Type, depth int) reflect.Value {
+	v := reflect.New(t).Elem()
+	if depth > 5 {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(t.Elem())
+		p.Elem().Set(sample(t.Elem(), depth+1))
+		v.Set(p)
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), sample(t.Elem(), depth+1)))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		v.SetMapIndex(sample(t.Key(), depth+1), sample(t.Elem(), depth+1))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				f.Set(sample(f.Type(), depth+1))
+			}
+		}
+	case reflect.String:
+		v.SetString("a")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	}
+
+	return v
+}
+
+func TestRouteResponses(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	spec := ws.OpenAPI()
+
+	ac := MakeVerifiedAccount(db)
+	other := core.Account{Name: "other", Password: "password", Email: "other@gmail.com"}
+	db.Account(&other)
+
+	nb := core.Notebook{Owner: ac.ID, Name: "math", Published: true}
+	db.Notebook(&nb)
+	nt := core.Note{Author: ac.ID, Name: "note", Content: "content", Published: true, Tags: []string{"math"}, Notebook: nb.ID}
+	db.Note(&nt)
+	cm := core.Comment{Author: other.ID, Note: nt.ID, Content: "comment"}
+	db.Comment(&cm)
+	db.Bookmark(core.Bookmark{Owner: ac.ID, Note: nt.ID, Name: nt.Name, Collection: "exam"})
+	db.Follow(core.Follow{Follower: ac.ID, Kind: core.FollowAccount, Account: other.ID})
+	db.Like(nt.ID, other.ID, db.Notes, true)
+
+	// path parameters are filled by resource the path starts with
+	ids := map[string]core.ID{"accounts": ac.ID, "notes": nt.ID, "notebooks": nb.ID, "comments": cm.ID}
+
+	for _, rt := range ws.Routes() {
+		schema := responseSchema(spec, rt)
+		if rt.Method != "GET" || schema == nil {
+			continue
+		}
+		t.Run(rt.Path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", APIPrefix+rt.Path, nil)
+			req.AddCookie(cookie(ac))
+			for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+				req.SetPathValue(m[1], strconv.FormatUint(ids[strings.Split(rt.Path, "/")[1]], 10))
+			}
+
+			var value interface{}
+			var err error
+			ws.Authenticate(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+				value, err = rt.Handler(wr, r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reflect.TypeOf(value) != reflect.TypeOf(rt.Resp) {
+				t.Fatalf("handler returned %T, route declares %T", value, rt.Resp)
+			}
+			if err := validateJSON(spec, schema, value); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// errors of mail queue
+var (
+	ErrQueueFull   = sterr.New("email queue is full")
+	ErrQueueClosed = sterr.New("email queue is closed")
+)
+
+// MailQueue delivers emails in background so requests do not wait for smtp server, it
+// implements Mailer
+type MailQueue struct {
+	send func(message []byte, targets ...string) error
+	log  *slog.Logger
+
+	mu     sync.Mutex
+	closed bool
+	jobs   chan mail
+	done   chan struct{}
+}
+
+type mail struct {
+	message []byte
+	targets []string
+
+	// result receives outcome of delivery if sender waits for it
+	result chan error
+}
+
+// NMailQueue creates queue and starts its worker, send is called for each email, failures
+// are logged
+func NMailQueue(size int, send func(message []byte, targets ...string) error, l *slog.Logger) *MailQueue {
+	q := &MailQueue{
+		send: send,
+		log:  l,
+		jobs: make(chan mail, size),
+		done: make(chan struct{}),
+	}
+
+	go q.work()
+
+	return q
+}
+
+func (q *MailQueue) work() {
+	defer close(q.done)
+	for m := range q.jobs {
+		err := q.send(m.message, m.targets...)
+		if m.result != nil {
+			m.result <- err
+		} else if err != nil {
+			q.log.Error("failed to deliver email", "err", err)
+		}
+	}
+}
+
+// Send enqueues email, it does not block when queue is full
+func (q *MailQueue) Send(message []byte, targets ...string) error {
+	return q.enqueue(mail{message: message, targets: targets})
+}
+
+// Deliver enqueues email and waits until it is sent, it is for emails whose recipient
+// cannot continue without them so failure has to reach them, shutdown still waits for it
+func (q *MailQueue) Deliver(message []byte, targets ...string) error {
+	m := mail{message: message, targets: targets, result: make(chan error, 1)}
+	err := q.enqueue(m)
+	if err != nil {
+		return err
+	}
+	return <-m.result
+}
+
+func (q *MailQueue) enqueue(m mail) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Flush closes the queue and waits until all queued emails are delivered or ctx is done
+func (q *MailQueue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"myNotes/core"
+	"myNotes/core/ranking"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sizes of ranking pages
+const (
+	RankingSize    = 20
+	MaxRankingSize = 100
+)
+
+// APITopNotes lists published notes with highest score, query parameters school, subject
+// and window (day, week, month, year or all) filter them and limit changes their amount
+func (w *WS) APITopNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.rankedNotes(r, "all", (*ranking.Ranking).Top)
+}
+
+// APITrendingNotes is same as APITopNotes but score decays with age of note, window is
+// week by default
+func (w *WS) APITrendingNotes(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return w.rankedNotes(r, "week", (*ranking.Ranking).Trending)
+}
+
+func (w *WS) rankedNotes(r *http.Request, window string, list func(*ranking.Ranking, ranking.Filter, int) []ranking.Entry) (interface{}, error) {
+	q := r.URL.Query()
+
+	limit, err := QueryLimit(r, RankingSize, MaxRankingSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw := q.Get("window"); raw != "" {
+		window = raw
+	}
+	period, err := ranking.Window(window)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	var f ranking.Filter
+	f.School, err = tx.SchoolCode(strings.TrimSpace(q.Get("school")))
+	if err != nil {
+		return nil, err
+	}
+	f.Subject, _, err = tx.Canonical(0, strings.TrimSpace(q.Get("subject")), "")
+	if err != nil {
+		return nil, err
+	}
+
+	rk, err := w.rankingNow()
+	if err != nil {
+		return nil, err
+	}
+	if period != 0 {
+		f.Since = rk.Computed - period.Milliseconds()
+	}
+
+	return RankingBody{Notes: list(rk, f, limit), Computed: rk.Computed}, nil
+}
+
+// rankingNow returns last computed ranking, it is computed right away if background
+// recomputation did not finish yet
+func (w *WS) rankingNow() (*ranking.Ranking, error) {
+	if rk := w.ranks.Load(); rk != nil {
+		return rk, nil
+	}
+	return w.RankNotes()
+}
+
+// RankNotes computes scores of all published notes and makes them visible to ranking
+// endpoints
+func (w *WS) RankNotes() (*ranking.Ranking, error) {
+	stats, err := w.db.NoteStats()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := w.cfg.Ranking
+	weights := ranking.Weights{
+		Like:    float64(cfg.LikeWeight),
+		Comment: float64(cfg.CommentWeight),
+		View:    float64(cfg.ViewWeight),
+	}
+	rk := ranking.Compute(stats, weights, cfg.HalfLife, w.now().UnixMilli())
+	w.ranks.Store(rk)
+
+	return rk, nil
+}
+
+// rankNotes runs RankNotes right away and then periodically until ctx is done
+func (w *WS) rankNotes(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Ranking.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RankNotes(); err != nil {
+			w.log.Error("failed to rank notes", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// countView counts view of published note by anybody except its author, repeated views of
+// the same account or ip are counted once per window, failure is only logged so reader
+// still gets the note
+func (w *WS) countView(r *http.Request, nt core.Note) {
+	if !nt.Published {
+		return
+	}
+
+	viewer := IPKey(RemoteIP(r))
+	if ac, err := AccountFrom(r, core.ReadS); err == nil {
+		if ac.ID == nt.Author {
+			return
+		}
+		viewer = AccountKey(ac.Name)
+	}
+	if !w.guard.View(ViewKey(nt.ID, viewer), w.cfg.Ranking.ViewWindow) {
+		return
+	}
+
+	if err := w.db.AddView(nt.ID); err != nil {
+		LoggerFrom(r).Error("failed to count view", "err", err)
+	}
+}
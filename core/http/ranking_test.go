@@ -0,0 +1,47 @@
+package http
+
+import (
+	"myNotes/core"
+	"testing"
+)
+
+func TestRankingUnpublished(t *testing.T) {
+	db, ws := SetupTest()
+	defer db.Cancel()
+
+	handler := ws.Handler()
+
+	ac := MakeVerifiedAccount(db)
+	nt := core.Note{Author: ac.ID, Name: "ranked", Published: true}
+	db.Note(&nt)
+	if _, _, err := db.Like(nt.ID, ac.ID+1, db.Notes, true); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc      string
+		published bool
+	}{
+		{desc: "published", published: true},
+		{desc: "unpublished", published: false},
+		{desc: "published again", published: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if err := db.SetPublished(nt.ID, tC.published); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ws.RankNotes(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{"/notes/top", "/notes/trending"} {
+				var body RankingBody
+				getJSON(t, handler, APIPrefix+path, nil, &body)
+				if ranked := len(body.Notes) == 1 && body.Notes[0].ID == nt.ID; ranked != tC.published {
+					t.Error(path, body.Notes)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package http
 
-import "myNotes/core"
+import (
+	"myNotes/core"
+	"myNotes/core/mongo"
+	"myNotes/core/taxonomy"
+	"strings"
+)
 
 type (
 	// Request is just a placeholder
@@ -9,6 +14,7 @@ type (
 	// LoginReqest ...
 	LoginReqest struct {
 		Name, Password string
+		Code           string `urlp:"optional"`
 	}
 
 	// RegisterRequest ...
@@ -54,6 +60,17 @@ type (
 		ID                           core.ID `urlp:"optional"`
 		Name, School, Theme, Subject string
 		Year, Month                  int
+		Tags                         string `urlp:"optional"`
+	}
+
+	// CodeRequest ...
+	CodeRequest struct {
+		Code string
+	}
+
+	// TokenRequest ...
+	TokenRequest struct {
+		Name, Scopes string
 	}
 
 	// PublishRequest ...
@@ -62,3 +79,188 @@ type (
 		Publish bool
 	}
 )
+
+// bodies of versioned api requests, they are decoded from json
+type (
+	// ConfigBody ...
+	ConfigBody struct {
+		Name   *string
+		Colors []string
+	}
+
+	// NoteBody holds note fields, on update nil fields are left unchanged
+	NoteBody struct {
+		Name, School, Theme, Subject, Content *string
+		Year, Month                           *int
+		Published                             *bool
+		Tags                                  []string
+	}
+
+	// CommentBody ...
+	CommentBody struct {
+		Content string
+	}
+
+	// NotebookBody holds notebook fields, on update nil fields are left unchanged
+	NotebookBody struct {
+		Name      *string
+		Parent    *core.ID
+		Published *bool
+	}
+
+	// MoveBody ...
+	MoveBody struct {
+		Notebook core.ID
+	}
+
+	// BookmarkBody ...
+	BookmarkBody struct {
+		Collection string
+	}
+
+	// TermBody holds term fields, kind and key are taken from path
+	TermBody struct {
+		Code    int
+		Parent  string
+		Schools []string
+		Names   map[string]string
+		Aliases []string
+	}
+
+	// NewTokenBody ...
+	NewTokenBody struct {
+		Name   string
+		Scopes []string
+	}
+
+	// query parameters of versioned api, they are only documented, names are lower cased
+	// field names unless query tag says otherwise and all of them are optional
+
+	// LimitQuery ...
+	LimitQuery struct {
+		Limit int
+	}
+
+	// CompleteQuery holds prefix of completed tag
+	CompleteQuery struct {
+		Q string
+	}
+
+	// PageQuery pages list, Cursor is Next of previous page
+	PageQuery struct {
+		Cursor string
+		Limit  int
+	}
+
+	// BookmarksQuery is PageQuery filtered by collection
+	BookmarksQuery struct {
+		Collection, Cursor string
+		Limit              int
+	}
+
+	// RankingQuery filters ranked notes, Window is day, week, month, year or all
+	RankingQuery struct {
+		School, Subject, Window string
+		Limit                   int
+	}
+
+	// TaxonomyQuery ...
+	TaxonomyQuery struct {
+		Kind taxonomy.Kind
+	}
+
+	// MigrateQuery ...
+	MigrateQuery struct {
+		DryRun bool `query:"dry_run"`
+	}
+)
+
+// Upload marks endpoints that take files in multipart form field files
+type Upload struct{}
+
+// Apply copies present fields to the note, school, subject and theme are resolved through
+// taxonomy when any of them changes
+func (n *NoteBody) Apply(nt *core.Note, tx *taxonomy.Taxonomy) (err error) {
+	for _, f := range []struct {
+		src *string
+		dst *string
+	}{
+		{n.Name, &nt.Name},
+		{n.Theme, &nt.Theme},
+		{n.Subject, &nt.Subject},
+		{n.Content, &nt.Content},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+
+	if n.School != nil {
+		nt.School, err = tx.SchoolCode(*n.School)
+		if err != nil {
+			return
+		}
+	}
+	if n.Year != nil {
+		nt.Year = *n.Year
+	}
+	if n.Month != nil {
+		nt.Month = *n.Month
+	}
+	if n.Published != nil {
+		nt.Published = *n.Published
+	}
+	if n.Tags != nil {
+		nt.Tags = n.Tags
+	}
+
+	if n.School != nil || n.Subject != nil || n.Theme != nil {
+		nt.Subject, nt.Theme, err = tx.Canonical(nt.School, nt.Subject, nt.Theme)
+	}
+
+	return
+}
+
+// Apply copies present fields to the notebook, parent has to be notebook of the same shelf
+func (n *NotebookBody) Apply(nb *core.Notebook, s *core.Shelf) error {
+	if n.Name != nil {
+		name := strings.TrimSpace(*n.Name)
+		if name == "" {
+			return ErrInvalidParam.Args("name")
+		}
+		nb.Name = name
+	}
+
+	if n.Parent != nil {
+		if _, ok := s.Notebook(*n.Parent); !ok && *n.Parent != 0 {
+			return mongo.ErrNotFound.Args("notebook", "parent")
+		}
+
+		err := s.CanMove(nb.ID, *n.Parent)
+		if err != nil {
+			return err
+		}
+		nb.Parent = *n.Parent
+	}
+
+	if n.Published != nil {
+		nb.Published = *n.Published
+	}
+
+	return nil
+}
+
+// Term builds term of kind and key, references and aliases are normalized, key is not so
+// path of term stays canonical
+func (t *TermBody) Term(kind taxonomy.Kind, key string) taxonomy.Term {
+	term := taxonomy.Term{Kind: kind, Key: key, Code: t.Code, Parent: core.NormalizeTag(t.Parent), Names: t.Names}
+	for _, s := range t.Schools {
+		term.Schools = append(term.Schools, core.NormalizeTag(s))
+	}
+	for _, a := range t.Aliases {
+		if a = core.NormalizeTag(a); a != "" {
+			term.Aliases = append(term.Aliases, a)
+		}
+	}
+	return term
+}
@@ -1,6 +1,10 @@
 package http
 
-import "myNotes/core"
+import (
+	"myNotes/core"
+	"myNotes/core/ranking"
+	"myNotes/core/taxonomy"
+)
 
 type (
 	// LikeResponce ...
@@ -20,6 +24,9 @@ type (
 	AccountResponce struct {
 		Resp    Responce
 		Account core.Account
+
+		// Followers and Following are filled only by PublicAccount
+		Followers, Following int64
 	}
 
 	// ConfigResponce ...
@@ -40,6 +47,31 @@ type (
 		ID   core.ID
 	}
 
+	// EnrollResponce ...
+	EnrollResponce struct {
+		Resp        Responce
+		URI, Secret string
+	}
+
+	// RecoveryResponce ...
+	RecoveryResponce struct {
+		Resp  Responce
+		Codes []string
+	}
+
+	// TokenResponce ...
+	TokenResponce struct {
+		Resp  Responce
+		Token string
+		Info  core.APIToken
+	}
+
+	// TokensResponce ...
+	TokensResponce struct {
+		Resp   Responce
+		Tokens []core.APIToken
+	}
+
 	// NoteResponce ...
 	NoteResponce struct {
 		Resp Responce
@@ -47,6 +79,166 @@ type (
 	}
 )
 
+// PNG marks endpoints that respond with png image
+type PNG []byte
+
+// File is responce of versioned api that is downloaded instead of encoded as json
+type File struct {
+	Name, Type string
+	Data       []byte
+}
+
+// bodies of versioned api responces
+type (
+	// ErrorBody ...
+	ErrorBody struct {
+		Error APIError
+	}
+
+	// IDBody ...
+	IDBody struct {
+		ID core.ID
+	}
+
+	// LikeBody ...
+	LikeBody struct {
+		Count int
+		State bool
+	}
+
+	// SearchBody ...
+	SearchBody struct {
+		Results []core.NotePreview
+	}
+
+	// DraftsBody ...
+	DraftsBody struct {
+		Drafts []core.Draft
+	}
+
+	// ShelfBody lists notebook, Path leads from the root to the notebook and is empty
+	// for the root itself
+	ShelfBody struct {
+		Path      []core.Notebook
+		Notebooks []core.NotebookEntry
+		Notes     []core.Draft
+	}
+
+	// TagsBody ...
+	TagsBody struct {
+		Tags []core.TagCount
+	}
+
+	// SelfAccount is account as its owner sees it, password and verification code are
+	// left out because tokens with read scope can read it
+	SelfAccount struct {
+		ID                   core.ID
+		BornDate, LastAction int64
+		Name, Email          string
+		Cfg                  core.Config
+		TOTP                 core.TOTP
+	}
+
+	// PublicAccountBody is account with its follow counts
+	PublicAccountBody struct {
+		core.Account
+		Followers, Following int64
+	}
+
+	// FollowsBody ...
+	FollowsBody struct {
+		Follows []core.Follow
+	}
+
+	// FeedBody is page of feed, Next is cursor of next page and is empty on the last page
+	FeedBody struct {
+		Notes []core.NotePreview
+		Next  string
+	}
+
+	// BookmarksBody is page of bookmarks, Next is cursor of next page and is empty on the
+	// last page
+	BookmarksBody struct {
+		Bookmarks []core.BookmarkEntry
+		Next      string
+	}
+
+	// CollectionsBody ...
+	CollectionsBody struct {
+		Collections []core.CollectionCount
+	}
+
+	// RankingBody is ranked notes, Computed is time of computing scores in milliseconds
+	RankingBody struct {
+		Notes    []ranking.Entry
+		Computed int64
+	}
+
+	// TaxonomyBody ...
+	TaxonomyBody struct {
+		Terms []TermEntry
+	}
+
+	// TermEntry is term with display name in locale preferred by client
+	TermEntry struct {
+		taxonomy.Term
+		Name string
+	}
+
+	// EnrollBody ...
+	EnrollBody struct {
+		URI, Secret string
+	}
+
+	// RecoveryBody ...
+	RecoveryBody struct {
+		Codes []string
+	}
+
+	// TokensBody ...
+	TokensBody struct {
+		Tokens []core.APIToken
+	}
+
+	// ImportBody reports every imported file in order they were uploaded
+	ImportBody struct {
+		Files []ImportedFile
+	}
+
+	// ImportedFile is either note created from file or error why it was skipped
+	ImportedFile struct {
+		File  string
+		ID    core.ID   `json:",omitempty"`
+		Name  string    `json:",omitempty"`
+		Error *APIError `json:",omitempty"`
+	}
+
+	// AttachmentsBody lists attachments, Used and Quota are in bytes
+	AttachmentsBody struct {
+		Attachments []core.Attachment
+		Used, Quota int
+	}
+
+	// CreatedTokenBody contains the only copy of token value
+	CreatedTokenBody struct {
+		Token string
+		Info  core.APIToken
+	}
+)
+
+// NSelfAccount creates SelfAccount from account
+func NSelfAccount(ac core.Account) SelfAccount {
+	return SelfAccount{
+		ID:         ac.ID,
+		BornDate:   ac.BornDate,
+		LastAction: ac.LastAction,
+		Name:       ac.Name,
+		Email:      ac.Email,
+		Cfg:        ac.Cfg,
+		TOTP:       ac.TOTP,
+	}
+}
+
 // Responce is responce sent by RegisterAccount callback
 type Responce struct {
 	Status string
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"myNotes/core"
+	"myNotes/core/config"
+	"net/http"
+	"strings"
+)
+
+// CSPReportPath receives reports of violated Content-Security-Policy
+const CSPReportPath = "/csp-report"
+
+type nonceKey struct{}
+
+// Security sets Content-Security-Policy with fresh nonce and other security headers on
+// every responce, pages can reach the nonce with NonceFrom
+func Security(cfg config.Security) Middleware {
+	header := "Content-Security-Policy"
+	if cfg.ReportOnly {
+		header += "-Report-Only"
+	}
+	policy := cfg.CSP + "; report-uri " + CSPReportPath
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			nonce, err := core.RandomString(16)
+			if err != nil {
+				WriteError(wr, r, err)
+				return
+			}
+
+			h := wr.Header()
+			h.Set(header, strings.ReplaceAll(policy, "{nonce}", nonce))
+			h.Set("X-Content-Type-Options", "nosniff")
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+			}
+
+			next.ServeHTTP(wr, r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce)))
+		})
+	}
+}
+
+// NonceFrom returns nonce assigned by Security middleware
+func NonceFrom(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
+// CSPReport is body browsers send to report-uri of policy
+type CSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// ReportCSP logs policy violations reported by browsers
+func (w *WS) ReportCSP(wr http.ResponseWriter, r *http.Request) {
+	var report CSPReport
+	err := json.NewDecoder(r.Body).Decode(&report)
+	if err != nil {
+		WriteError(wr, r, ErrInvalidBody.Wrap(err))
+		return
+	}
+
+	rp := report.Report
+	LoggerFrom(r).Warn("content security policy violated",
+		"document", rp.DocumentURI,
+		"directive", rp.EffectiveDirective,
+		"violated", rp.ViolatedDirective,
+		"blocked", rp.BlockedURI,
+		"source", rp.SourceFile,
+		"line", rp.LineNumber,
+		"disposition", rp.Disposition,
+	)
+
+	wr.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSecurity(t *testing.T) {
+	files, err := NAssets(fstest.MapFS{
+		"index.html": {Data: []byte(`<script src="index.js"></script>`)},
+		"view.html":  {Data: []byte(`<script src="a.js"></script><script src="b.js"></script>`)},
+		"index.js":   {Data: []byte(`<script`)},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc, path, header string
+		reportOnly         bool
+		nonces             int
+	}{
+		{desc: "directory index", path: "/", header: "Content-Security-Policy", nonces: 1},
+		{desc: "page", path: "/view.html", header: "Content-Security-Policy", nonces: 2},
+		{desc: "script untouched", path: "/index.js", header: "Content-Security-Policy"},
+		{desc: "report only", path: "/view.html", header: "Content-Security-Policy-Report-Only", reportOnly: true, nonces: 2},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cfg := testConfig().Server.Security
+			cfg.ReportOnly = tC.reportOnly
+			h := Chain(files, Security(cfg))
+
+			rc := httptest.NewRecorder()
+			h.ServeHTTP(rc, httptest.NewRequest("GET", tC.path, nil))
+
+			policy := rc.Header().Get(tC.header)
+			_, nonce, _ := strings.Cut(policy, "'nonce-")
+			nonce, _, _ = strings.Cut(nonce, "'")
+			if rc.Code != http.StatusOK || len(nonce) != 32 || !strings.Contains(policy, "report-uri "+CSPReportPath) {
+				t.Fatal(rc.Code, rc.Header())
+			}
+			if got := strings.Count(rc.Body.String(), `nonce="`+nonce+`"`); got != tC.nonces {
+				t.Error(got, rc.Body.String())
+			}
+			for k, v := range map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"Referrer-Policy":        cfg.ReferrerPolicy,
+				"Permissions-Policy":     cfg.PermissionsPolicy,
+			} {
+				if rc.Header().Get(k) != v {
+					t.Error(k, rc.Header().Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityNonceUnique(t *testing.T) {
+	h := Security(testConfig().Server.Security)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		rc := httptest.NewRecorder()
+		h.ServeHTTP(rc, httptest.NewRequest("GET", "/", nil))
+		policy := rc.Header().Get("Content-Security-Policy")
+		if seen[policy] {
+			t.Fatal("nonce reused", policy)
+		}
+		seen[policy] = true
+	}
+}
+
+func TestCSPReport(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+	h := ws.Handler()
+
+	testCases := []struct {
+		desc, body string
+		status     int
+	}{
+		{desc: "report", body: `{"csp-report":{"document-uri":"https://a/view.html","effective-directive":"script-src"}}`, status: http.StatusNoContent},
+		{desc: "garbage", body: `not json`, status: http.StatusBadRequest},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest("POST", CSPReportPath, strings.NewReader(tC.body))
+			req.Header.Set("Content-Type", "application/csp-report")
+
+			rc := httptest.NewRecorder()
+			h.ServeHTTP(rc, req)
+			if rc.Code != tC.status {
+				t.Error(rc.Code, rc.Body.String())
+			}
+		})
+	}
+}
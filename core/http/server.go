@@ -0,0 +1,207 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// health check paths
+const (
+	HealthPath = "/healthz"
+	ReadyPath  = "/readyz"
+)
+
+// errors reported by readiness check
+var (
+	ErrDraining    = sterr.New("server is shutting down")
+	ErrNoStorage   = sterr.New("storage is not configured")
+	ErrUnreachable = sterr.New("storage is unreachable")
+)
+
+// HealthBody is responce of health checks, reason of failure is only logged because the
+// endpoints are public
+type HealthBody struct {
+	Status string
+}
+
+// Server creates http server of WS with timeouts
+func (w *WS) Server() *http.Server {
+	return w.server(w.targetAddress, w.Handler())
+}
+
+func (w *WS) server(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: w.cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       w.cfg.Server.ReadTimeout,
+		WriteTimeout:      w.cfg.Server.WriteTimeout,
+		IdleTimeout:       w.cfg.Server.IdleTimeout,
+	}
+}
+
+// Run listens on configured address, and on redirect port when tls is configured, and
+// serves until ctx is done. Orphaned attachments are collected periodically meanwhile.
+// On shutdown it stops accepting connections, waits for in-flight requests and delivers
+// queued emails, closing the database is left to the caller.
+func (w *WS) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", w.targetAddress)
+	if err != nil {
+		return err
+	}
+
+	var redirect net.Listener
+	if tls := w.cfg.Server.TLS; tls.Enabled() && tls.RedirectPort != 0 {
+		redirect, err = net.Listen("tcp", net.JoinHostPort(w.cfg.Server.Host, strconv.Itoa(tls.RedirectPort)))
+		if err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	return w.Serve(ctx, ln, redirect)
+}
+
+// Serve is Run with custom listeners, redirect listener is optional and used only with tls
+func (w *WS) Serve(ctx context.Context, ln, redirect net.Listener) error {
+	srv := w.Server()
+	servers := []*http.Server{srv}
+	serve := []func() error{func() error { return srv.Serve(ln) }}
+
+	if tls := w.cfg.Server.TLS; tls.Enabled() {
+		certs, err := NCertReloader(tls.Cert, tls.Key, tls.ReloadInterval, w.log)
+		if err != nil {
+			ln.Close()
+			if redirect != nil {
+				redirect.Close()
+			}
+			return err
+		}
+
+		srv.TLSConfig = TLSConfig(certs)
+		serve[0] = func() error { return srv.ServeTLS(ln, "", "") }
+
+		if redirect != nil {
+			rsrv := w.server(redirect.Addr().String(), Redirect(w.cfg.Server.Port))
+			servers = append(servers, rsrv)
+			serve = append(serve, func() error { return rsrv.Serve(redirect) })
+			w.log.Info("redirecting to https", "address", redirect.Addr().String())
+		}
+	}
+
+	w.queue = NMailQueue(w.cfg.Mail.QueueSize, w.deliver, w.log)
+
+	// collector and ranking are stopped before shutdown so they do not outlive the database
+	gctx, stopGC := context.WithCancel(ctx)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		if w.db != nil && w.cfg.Attachments.GCInterval > 0 {
+			w.collectAttachments(gctx)
+		}
+	}()
+	ranked := make(chan struct{})
+	go func() {
+		defer close(ranked)
+		if w.db != nil {
+			w.rankNotes(gctx)
+		}
+	}()
+
+	errs := make(chan error, len(serve))
+	for _, s := range serve {
+		go func(s func() error) {
+			errs <- s()
+		}(s)
+	}
+
+	w.log.Info("server listening", "address", ln.Addr().String(), "tls", w.cfg.Server.TLS.Enabled())
+
+	// failure of any listener stops the whole server
+	var err error
+	running := len(serve)
+	select {
+	case err = <-errs:
+		running--
+	case <-ctx.Done():
+	}
+
+	w.log.Info("shutting down")
+	w.draining.Store(true)
+	stopGC()
+	<-collected
+	<-ranked
+
+	sctx, cancel := context.WithTimeout(context.Background(), w.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	for _, s := range servers {
+		if serr := s.Shutdown(sctx); serr != nil {
+			w.log.Error("failed to drain requests", "err", serr)
+			err = errors.Join(err, serr)
+		}
+	}
+
+	if ferr := w.queue.Flush(sctx); ferr != nil {
+		w.log.Error("failed to flush email queue", "err", ferr)
+		err = errors.Join(err, ferr)
+	}
+
+	for ; running > 0; running-- {
+		if serr := <-errs; serr != http.ErrServerClosed {
+			err = errors.Join(err, serr)
+		}
+	}
+
+	return err
+}
+
+// Health responds whenever process is able to handle requests
+func (w *WS) Health(wr http.ResponseWriter, r *http.Request) {
+	writeHealth(wr, r, nil)
+}
+
+// Ready responds with 503 when storage is unreachable or server is shutting down so load
+// balancer stops sending traffic
+func (w *WS) Ready(wr http.ResponseWriter, r *http.Request) {
+	writeHealth(wr, r, w.ready(r.Context()))
+}
+
+func (w *WS) ready(ctx context.Context) error {
+	if w.draining.Load() {
+		return ErrDraining
+	}
+
+	if w.db == nil {
+		return ErrNoStorage
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Storage.PingTimeout)
+	defer cancel()
+
+	err := w.db.Ping(ctx)
+	if err != nil {
+		return ErrUnreachable.Wrap(err)
+	}
+
+	return nil
+}
+
+func writeHealth(wr http.ResponseWriter, r *http.Request, err error) {
+	body, status := HealthBody{Status: "ok"}, http.StatusOK
+	if err != nil {
+		LoggerFrom(r).Warn("not ready", "err", err)
+		body, status = HealthBody{Status: "unavailable"}, http.StatusServiceUnavailable
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(status)
+	json.NewEncoder(wr).Encode(body)
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+	ws.RegisterHandlers()
+	handler := ws.Handler()
+
+	testCases := []struct {
+		desc, path string
+		draining   bool
+		status     int
+	}{
+		{"alive", HealthPath, false, http.StatusOK},
+		{"no storage", ReadyPath, false, http.StatusServiceUnavailable},
+		{"draining", ReadyPath, true, http.StatusServiceUnavailable},
+		{"alive while draining", HealthPath, true, http.StatusOK},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ws.draining.Store(tC.draining)
+			rc := httptest.NewRecorder()
+			handler.ServeHTTP(rc, httptest.NewRequest("GET", tC.path, nil))
+			if rc.Code != tC.status {
+				t.Error(rc.Code, tC.status, rc.Body.String())
+			}
+
+			// reason is not exposed to anonymous clients
+			var body map[string]interface{}
+			json.Unmarshal(rc.Body.Bytes(), &body)
+			if len(body) != 1 || (body["Status"] != "ok" && body["Status"] != "unavailable") {
+				t.Error(rc.Body.String())
+			}
+		})
+	}
+}
+
+type slowMailer struct {
+	sync.Mutex
+	sent int
+}
+
+func (s *slowMailer) Send(message []byte, targets ...string) error {
+	time.Sleep(10 * time.Millisecond)
+	s.Lock()
+	s.sent++
+	s.Unlock()
+	return nil
+}
+
+func TestMailQueue(t *testing.T) {
+	bot := &slowMailer{}
+	q := NMailQueue(2, bot.Send, slog.Default())
+
+	testCases := []error{nil, nil, ErrQueueFull}
+	for i, err := range testCases {
+		// first email is picked by worker right away, so queue holds the next two
+		if i == 0 {
+			q.Send(nil, "first")
+			time.Sleep(time.Millisecond)
+		}
+		if res := q.Send(nil, "target"); !errors.Is(res, err) {
+			t.Error(i, res, err)
+		}
+	}
+
+	if err := q.Flush(context.Background()); err != nil || bot.sent != 3 {
+		t.Error(err, bot.sent)
+	}
+
+	if err := q.Send(nil, "target"); !errors.Is(err, ErrQueueClosed) {
+		t.Error(err)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	w := testWS()
+	release := make(chan struct{})
+	w.mux.HandleFunc("/slow", func(wr http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Serve(ctx, ln, nil)
+	}()
+
+	status := make(chan int)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+
+	// in-flight request has to finish even though shutdown already started
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if s := <-status; s != http.StatusOK {
+		t.Error(s)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func testWS() *WS {
+	return NWS(testConfig(), nil, &EmailSender{})
+}
+
+func TestMailQueueDeliver(t *testing.T) {
+	q := NMailQueue(1, func(message []byte, targets ...string) error {
+		if targets[0] == "broken" {
+			return ErrSendEmail
+		}
+		return nil
+	}, slog.Default())
+
+	testCases := []struct {
+		desc, target string
+		err          error
+	}{
+		{desc: "delivered", target: "target"},
+		{desc: "failed", target: "broken", err: ErrSendEmail},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if err := q.Deliver(nil, tC.target); !errors.Is(err, tC.err) {
+				t.Error(err)
+			}
+		})
+	}
+
+	q.Flush(context.Background())
+	if err := q.Deliver(nil, "target"); !errors.Is(err, ErrQueueClosed) {
+		t.Error(err)
+	}
+}
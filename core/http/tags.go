@@ -0,0 +1,48 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http"
+	"strconv"
+)
+
+// amounts of tags listed by tag endpoints
+const (
+	TagCloudSize     = 50
+	MaxTagCloudSize  = 200
+	AutocompleteSize = 10
+)
+
+// APITags lists most used tags, query parameter limit changes amount of tags
+func (w *WS) APITags(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	limit := TagCloudSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > MaxTagCloudSize {
+			return nil, ErrInvalidParam.Args("limit")
+		}
+		limit = v
+	}
+
+	tags, err := w.db.TagCounts("", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return TagsBody{Tags: tags}, nil
+}
+
+// APICompleteTags lists most used tags that start with normalized query parameter q
+func (w *WS) APICompleteTags(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	prefix := core.NormalizeTag(r.URL.Query().Get("q"))
+	if prefix == "" {
+		return TagsBody{Tags: []core.TagCount{}}, nil
+	}
+
+	tags, err := w.db.TagCounts(prefix, AutocompleteSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return TagsBody{Tags: tags}, nil
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// ErrNotAdmin is returned when account that is not listed in taxonomy.admins changes taxonomy
+var ErrNotAdmin = sterr.New("only taxonomy admins can change taxonomy")
+
+// APITaxonomy lists terms with display names in locale preferred by client, query
+// parameter kind limits them to one kind
+func (w *WS) APITaxonomy(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	kind := taxonomy.Kind(r.URL.Query().Get("kind"))
+	if kind != "" && !knownKind(kind) {
+		return nil, taxonomy.ErrKind.Args(kind)
+	}
+
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	return taxonomyBody(tx, kind, w.locales(r)), nil
+}
+
+// APIPutTerm adds or replaces term, key is taken from path and has to be normalized
+func (w *WS) APIPutTerm(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req TermBody
+	err := Decode(r, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.admin(r)
+	if err != nil {
+		return nil, err
+	}
+
+	term := req.Term(taxonomy.Kind(r.PathValue("kind")), r.PathValue("key"))
+	tx, err := w.db.PutTerm(term)
+	if err != nil {
+		return nil, err
+	}
+
+	// stored term is returned so client sees normalized aliases
+	term, _ = tx.Lookup(term.Kind, term.Key)
+	return term, nil
+}
+
+// APIDeleteTerm deletes term, it cannot be used by other terms or notes
+func (w *WS) APIDeleteTerm(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	_, err := w.admin(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, w.db.DeleteTerm(taxonomy.Kind(r.PathValue("kind")), r.PathValue("key"))
+}
+
+// APIMigrateTaxonomy maps free text subjects and themes of notes onto terms, query
+// parameter dry_run=true only reports what would change
+func (w *WS) APIMigrateTaxonomy(wr http.ResponseWriter, r *http.Request) (interface{}, error) {
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, ErrInvalidParam.Args("dry_run")
+		}
+		dryRun = v
+	}
+
+	ac, err := w.admin(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := w.db.MigrateTaxonomy(dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		LoggerFrom(r).Info("taxonomy migrated", "admin", ac.ID, "notes", report.Notes, "changed", report.Changed, "unresolved", len(report.Unresolved))
+	}
+
+	return report, nil
+}
+
+// admin returns account of request if it can change taxonomy
+func (w *WS) admin(r *http.Request) (core.Account, error) {
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		return ac, err
+	}
+
+	for _, id := range w.cfg.Taxonomy.AdminIDs() {
+		if id == ac.ID {
+			return ac, nil
+		}
+	}
+
+	return core.Account{}, ErrNotAdmin
+}
+
+// locales returns languages of Accept-Language header in order of preference followed by
+// configured locale, region is dropped so "cs-CZ" is "cs"
+func (w *WS) locales(r *http.Request) []string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+
+	var prefs []pref
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		lang, _, _ = strings.Cut(lang, "-")
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		prefs = append(prefs, pref{lang, q})
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	locales := []string{}
+	for _, p := range prefs {
+		if p.q > 0 {
+			locales = append(locales, p.lang)
+		}
+	}
+
+	return append(locales, w.cfg.Taxonomy.Locale)
+}
+
+// labeler returns function giving display names of note values in locale preferred by client
+func (w *WS) labeler(r *http.Request) (func(core.Note) taxonomy.Labels, error) {
+	tx, err := w.db.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	locales := w.locales(r)
+	return func(nt core.Note) taxonomy.Labels {
+		return tx.Labels(nt, locales...)
+	}, nil
+}
+
+func taxonomyBody(tx *taxonomy.Taxonomy, kind taxonomy.Kind, locales []string) TaxonomyBody {
+	body := TaxonomyBody{Terms: []TermEntry{}}
+	for _, t := range tx.Terms(kind) {
+		body.Terms = append(body.Terms, TermEntry{Term: t, Name: t.Name(locales...)})
+	}
+	return body
+}
+
+func knownKind(kind taxonomy.Kind) bool {
+	for _, k := range taxonomy.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLocales(t *testing.T) {
+	ws := NWS(testConfig(), nil, &EmailSender{})
+
+	testCases := []struct {
+		desc, header string
+		locales      []string
+	}{
+		{desc: "none", locales: []string{"en"}},
+		{desc: "region", header: "cs-CZ", locales: []string{"cs", "en"}},
+		{desc: "quality", header: "de;q=0.5, cs-CZ;q=0.8, sk, *;q=0.1", locales: []string{"sk", "cs", "de", "en"}},
+		{desc: "refused", header: "fr;q=0, de", locales: []string{"de", "en"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/taxonomy", nil)
+			req.Header.Set("Accept-Language", tC.header)
+			if locales := ws.locales(req); !reflect.DeepEqual(locales, tC.locales) {
+				t.Error(locales)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// ErrCertificate is returned when certificate can not be loaded
+var ErrCertificate = sterr.New("failed to load certificate %s")
+
+// CertReloader serves certificate loaded from files and reloads it when files change, so
+// rotated certificates are picked up without restart
+type CertReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	now               func() time.Time
+	log               *slog.Logger
+
+	m         sync.Mutex
+	cert      *tls.Certificate
+	modified  time.Time
+	lastCheck time.Time
+}
+
+// NCertReloader loads certificate and returns reloader, files are checked for changes at
+// most once per interval
+func NCertReloader(certFile, keyFile string, interval time.Duration, l *slog.Logger) (*CertReloader, error) {
+	c := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		now:      time.Now,
+		log:      l,
+	}
+
+	err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, if reload fails previous
+// certificate is kept
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if now := c.now(); now.Sub(c.lastCheck) >= c.interval {
+		c.lastCheck = now
+		if c.modTime().After(c.modified) {
+			if err := c.loadLocked(); err != nil {
+				c.log.Error("failed to reload certificate", "err", err)
+			} else {
+				c.log.Info("certificate reloaded", "cert", c.certFile)
+			}
+		}
+	}
+
+	return c.cert, nil
+}
+
+func (c *CertReloader) load() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.lastCheck = c.now()
+	return c.loadLocked()
+}
+
+func (c *CertReloader) loadLocked() error {
+	modified := c.modTime()
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return ErrCertificate.Args(c.certFile).Wrap(err)
+	}
+
+	c.cert, c.modified = &cert, modified
+	return nil
+}
+
+// modTime is latest modification of certificate and key files
+func (c *CertReloader) modTime() (latest time.Time) {
+	for _, f := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return
+}
+
+// TLSConfig creates tls configuration serving certificate of reloader
+func TLSConfig(c *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+// Redirect responds with permanent redirect to the same url on https with given port
+func Redirect(port int) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		u := *r.URL
+		u.Scheme, u.Host = "https", host
+		http.Redirect(wr, r, u.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// HSTS tells browsers to use https only for maxAge
+func HSTS(maxAge time.Duration) Middleware {
+	value := "max-age=" + strconv.Itoa(int(maxAge/time.Second)) + "; includeSubDomains"
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+			wr.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(wr, r)
+		})
+	}
+}
+
+// setCookie sets cookie, cookies are marked secure when server speaks https, they are sent
+// only along same site requests and top level navigation unless stated otherwise
+func (w *WS) setCookie(wr http.ResponseWriter, c *http.Cookie) {
+	c.Secure = w.cfg.Server.TLS.Enabled()
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	http.SetCookie(wr, c)
+}
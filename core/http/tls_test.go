@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes self signed certificate for localhost with given common name
+func writeCert(t *testing.T, dir, name string, modified time.Time) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	os.Chtimes(certFile, modified, modified)
+	os.Chtimes(keyFile, modified, modified)
+
+	return
+}
+
+func commonName(t *testing.T, c *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	certFile, keyFile := writeCert(t, dir, "first", start)
+
+	c, err := NCertReloader(certFile, keyFile, time.Minute, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Now()}
+	c.now = clock.Now
+
+	writeCert(t, dir, "second", start.Add(time.Minute))
+
+	testCases := []struct {
+		desc    string
+		advance time.Duration
+		broken  bool
+		name    string
+	}{
+		{"checked recently", 0, false, "first"},
+		{"rotated", time.Minute, false, "second"},
+		{"broken rotation keeps old", time.Minute, true, "second"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if tC.broken {
+				os.WriteFile(certFile, []byte("garbage"), 0o600)
+			}
+			clock.Advance(tC.advance)
+
+			cert, err := c.GetCertificate(nil)
+			if err != nil || commonName(t, cert) != tC.name {
+				t.Error(err, commonName(t, cert), tC.name)
+			}
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	testCases := []struct {
+		desc, target string
+		port         int
+		location     string
+	}{
+		{"default port", "http://example.com:80/notes?id=1", 443, "https://example.com/notes?id=1"},
+		{"custom port", "http://example.com/", 8443, "https://example.com:8443/"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rc := httptest.NewRecorder()
+			Redirect(tC.port).ServeHTTP(rc, httptest.NewRequest("GET", tC.target, nil))
+			if rc.Code != http.StatusPermanentRedirect || rc.Header().Get("Location") != tC.location {
+				t.Error(rc.Code, rc.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.TLS.Cert, cfg.Server.TLS.Key = writeCert(t, t.TempDir(), "server", time.Now())
+
+	ws := NWS(cfg, nil, &EmailSender{})
+	ws.RegisterHandlers()
+	ws.mux.HandleFunc("/cookie", func(wr http.ResponseWriter, r *http.Request) {
+		ws.setCookie(wr, &http.Cookie{Name: "user", Value: "value"})
+	})
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	redirect, _ := net.Listen("tcp", "127.0.0.1:0")
+	cfg.Server.Port = ln.Addr().(*net.TCPAddr).Port
+	ws.cfg = cfg
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- ws.Serve(ctx, ln, redirect)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get("https://" + ln.Addr().String() + "/cookie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Strict-Transport-Security") == "" || len(res.Cookies()) == 0 {
+		t.Error(res.Header)
+	}
+	for _, c := range res.Cookies() {
+		if !c.Secure {
+			t.Error(c)
+		}
+	}
+
+	res, err = client.Get("http://" + redirect.Addr().String() + HealthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusPermanentRedirect {
+		t.Error(res.StatusCode, res.Header.Get("Location"))
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"myNotes/core"
+	"net/http"
+	"strings"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// TokenPrefix makes api tokens recognizable when they leak into logs or repositories
+const (
+	TokenPrefix = "mn_"
+	TokenSize   = 32
+	MaxTokens   = 20
+)
+
+// errors related to api tokens
+var (
+	ErrInvalidAuthorization = sterr.New("authorization header has to be in form 'Bearer <token>'")
+	ErrInvalidToken         = sterr.New("api token is invalid")
+	ErrMissingScope         = sterr.New("api token does not have %s scope")
+	ErrTooManyTokens        = sterr.New("you cannot have more then %d tokens")
+)
+
+// Tokens lists api tokens of account, token values are never returned again after creation
+func (w *WS) Tokens(wr http.ResponseWriter, r *http.Request) {
+	var req Request
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	var ts []core.APIToken
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		ts, err = w.db.UserTokens(ac.ID)
+		return
+	}()
+
+	encoder.Encode(TokensResponce{
+		Resp:   NResponce(err),
+		Tokens: ts,
+	})
+}
+
+// CreateToken creates named api token with given scopes, responce contains the only copy
+// of token value
+func (w *WS) CreateToken(wr http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	var (
+		value string
+		tk    core.APIToken
+	)
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		scopes, err := core.ParseScopes(req.Scopes)
+		if err != nil {
+			return
+		}
+
+		value, tk, err = w.createToken(ac, req.Name, scopes)
+		return
+	}()
+
+	encoder.Encode(TokenResponce{
+		Resp:  NResponce(err),
+		Token: value,
+		Info:  tk,
+	})
+}
+
+// createToken generates and stores new token of account
+func (w *WS) createToken(ac core.Account, name string, scopes []core.Scope) (value string, tk core.APIToken, err error) {
+	ts, err := w.db.UserTokens(ac.ID)
+	if err != nil {
+		return
+	}
+
+	if len(ts) >= MaxTokens {
+		return "", tk, ErrTooManyTokens.Args(MaxTokens)
+	}
+
+	value, err = core.RandomString(TokenSize)
+	if err != nil {
+		return
+	}
+	value = TokenPrefix + value
+
+	tk = core.APIToken{
+		Owner:  ac.ID,
+		Name:   name,
+		Hash:   core.Hash(value),
+		Scopes: scopes,
+	}
+
+	err = w.db.Token(&tk)
+	if err != nil {
+		return "", tk, err
+	}
+
+	return
+}
+
+// RevokeToken deletes api token of account
+func (w *WS) RevokeToken(wr http.ResponseWriter, r *http.Request) {
+	var req IDRequest
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		return w.db.RevokeToken(ac.ID, req.ID)
+	}()
+
+	encoder.Encode(NResponce(err))
+}
+
+// authenticate resolves account either from api token in Authorization header or from
+// cookies, token is nil for cookie authentication
+func (w *WS) authenticate(wr http.ResponseWriter, r *http.Request) (core.Account, *core.APIToken, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		ac, tk, err := w.GetAccountFromToken(auth)
+		return ac, &tk, err
+	}
+
+	ac, err := w.GetAccountFromCookie(wr, r)
+	return ac, nil, err
+}
+
+// GetAccountFromToken extracts account and token from Authorization header value
+func (w *WS) GetAccountFromToken(auth string) (ac core.Account, tk core.APIToken, err error) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return ac, tk, ErrInvalidAuthorization
+	}
+
+	tk, err = w.db.TokenByHash(core.Hash(strings.TrimSpace(auth[len(prefix):])))
+	if err != nil {
+		return ac, tk, ErrInvalidToken
+	}
+
+	ac, err = w.db.AccountByID(tk.Owner)
+	if err != nil {
+		return ac, tk, ErrInvalidToken
+	}
+
+	return ac, tk, w.db.UseToken(tk.ID)
+}
@@ -0,0 +1,285 @@
+package http
+
+import (
+	"myNotes/core"
+	"myNotes/core/totp"
+	"net/http"
+	"time"
+
+	"github.com/jakubDoka/sterr"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// two factor settings
+const (
+	Issuer          = "myNotes"
+	RecoveryCount   = 10
+	SessionLifetime = time.Hour * 24 * 30
+	QRSize          = 256
+)
+
+// errors related to two factor authentication
+var (
+	ErrSecondStep      = sterr.New("two factor code is required")
+	ErrInvalidCode     = sterr.New("two factor code is incorrect")
+	ErrTOTPEnabled     = sterr.New("two factor authentication is already enabled")
+	ErrTOTPDisabled    = sterr.New("two factor authentication is not enabled")
+	ErrNotEnrolled     = sterr.New("you have to start the enrolment first")
+	ErrMissingSession  = sterr.New("missing session cookie, login again")
+	ErrInvalidSession  = sterr.New("session is invalid or expired, login again")
+	ErrSessionMismatch = sterr.New("session belongs to different account")
+)
+
+// EnrollTOTP generates new pending secret for account and returns provisioning uri,
+// secret becomes active after ConfirmTOTP
+func (w *WS) EnrollTOTP(wr http.ResponseWriter, r *http.Request) {
+	var req Request
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	var resp EnrollResponce
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		resp.Secret, resp.URI, err = w.enrollTOTP(ac)
+		return
+	}()
+
+	resp.Resp = NResponce(err)
+	encoder.Encode(resp)
+}
+
+// enrollTOTP stores new pending secret of account
+func (w *WS) enrollTOTP(ac core.Account) (secret, uri string, err error) {
+	if ac.TOTP.Enabled {
+		return "", "", ErrTOTPEnabled
+	}
+
+	ac.TOTP.Pending, err = totp.NSecret()
+	if err != nil {
+		return "", "", core.EI(err)
+	}
+
+	err = w.db.SetTOTP(ac.ID, ac.TOTP)
+	if err != nil {
+		return
+	}
+
+	return ac.TOTP.Pending, totp.URI(ac.TOTP.Pending, Issuer, ac.Name), nil
+}
+
+// TOTPQR responds with png image of QR code containing provisioning uri of pending secret
+func (w *WS) TOTPQR(wr http.ResponseWriter, r *http.Request) {
+	ac, err := AccountFrom(r, core.AccountS)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if ac.TOTP.Pending == "" {
+		http.Error(wr, ErrNotEnrolled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	png, err := qrcode.Encode(totp.URI(ac.TOTP.Pending, Issuer, ac.Name), qrcode.Medium, QRSize)
+	if err != nil {
+		InternalErr(wr, err.Error())
+		return
+	}
+
+	wr.Header().Set("Content-Type", "image/png")
+	wr.Write(png)
+}
+
+// ConfirmTOTP enables two factor authentication if code matches pending secret, recovery
+// codes are returned, this is the only time user can see them
+func (w *WS) ConfirmTOTP(wr http.ResponseWriter, r *http.Request) {
+	var req CodeRequest
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	var codes []string
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		codes, err = w.confirmTOTP(wr, ac, req.Code)
+		return
+	}()
+
+	encoder.Encode(RecoveryResponce{
+		Resp:  NResponce(err),
+		Codes: codes,
+	})
+}
+
+// confirmTOTP activates pending secret and generates recovery codes
+func (w *WS) confirmTOTP(wr http.ResponseWriter, ac core.Account, code string) ([]string, error) {
+	if ac.TOTP.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	if ac.TOTP.Pending == "" {
+		return nil, ErrNotEnrolled
+	}
+
+	step, ok := totp.Verify(ac.TOTP.Pending, code, w.now(), 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, err := totp.RecoveryCodes(RecoveryCount)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	ac.TOTP = core.TOTP{
+		Enabled:  true,
+		Secret:   ac.TOTP.Pending,
+		Last:     step,
+		Recovery: make([]string, len(codes)),
+	}
+	for i, c := range codes {
+		ac.TOTP.Recovery[i] = core.Hash(c)
+	}
+
+	err = w.db.SetTOTP(ac.ID, ac.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
+	// user stays logged in on the device two factor authentication was enabled from
+	err = w.StartSession(wr, ac.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two factor authentication off, valid code or recovery code is required
+func (w *WS) DisableTOTP(wr http.ResponseWriter, r *http.Request) {
+	var req CodeRequest
+	encoder, failed := w.Setup(wr, r, &req)
+	if failed {
+		return
+	}
+
+	err := func() (err error) {
+		ac, err := AccountFrom(r, core.AccountS)
+		if err != nil {
+			return
+		}
+
+		return w.disableTOTP(ac, req.Code)
+	}()
+
+	encoder.Encode(NResponce(err))
+}
+
+// disableTOTP turns two factor authentication off and drops all sessions of account
+func (w *WS) disableTOTP(ac core.Account, code string) error {
+	if !ac.TOTP.Enabled {
+		return ErrTOTPDisabled
+	}
+
+	err := w.SecondFactor(&ac, code)
+	if err != nil {
+		return err
+	}
+
+	err = w.db.SetTOTP(ac.ID, core.TOTP{})
+	if err != nil {
+		return err
+	}
+
+	return w.db.DropSessions(ac.ID)
+}
+
+// SecondFactor verifies totp or recovery code of account, used recovery code is consumed,
+// code is used in database only if no concurrent login used it first
+func (w *WS) SecondFactor(ac *core.Account, code string) error {
+	if step, ok := totp.Verify(ac.TOTP.Secret, code, w.now(), ac.TOTP.Last); ok {
+		used, err := w.db.UseTOTPStep(ac.ID, ac.TOTP.Last, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		ac.TOTP.Last = step
+		return nil
+	}
+
+	hash := core.Hash(code)
+	for i, h := range ac.TOTP.Recovery {
+		if h == hash {
+			used, err := w.db.UseRecoveryCode(ac.ID, hash)
+			if err != nil {
+				return err
+			}
+			if !used {
+				return ErrInvalidCode
+			}
+			ac.TOTP.Recovery = append(ac.TOTP.Recovery[:i], ac.TOTP.Recovery[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrInvalidCode
+}
+
+// StartSession creates session for account and sets session cookie
+func (w *WS) StartSession(wr http.ResponseWriter, account core.ID) error {
+	token, err := core.RandomString(32)
+	if err != nil {
+		return err
+	}
+
+	expires := w.now().Add(SessionLifetime)
+	err = w.db.Session(&core.Session{
+		Account: account,
+		Hash:    core.Hash(token),
+		Expires: expires.UnixNano() / int64(time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.setCookie(wr, &http.Cookie{
+		Name:     "session",
+		Value:    token,
+		Expires:  expires,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+// CheckSession returns error if request does not carry valid session of account
+func (w *WS) CheckSession(r *http.Request, account core.ID) error {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return ErrMissingSession
+	}
+
+	s, err := w.db.SessionByHash(core.Hash(cookie.Value))
+	if err != nil || s.Expires < w.now().UnixNano()/int64(time.Millisecond) {
+		return ErrInvalidSession
+	}
+
+	if s.Account != account {
+		return ErrSessionMismatch
+	}
+
+	return nil
+}
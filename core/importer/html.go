@@ -0,0 +1,267 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"myNotes/core"
+	"regexp"
+	"strings"
+)
+
+var (
+	scripts   = regexp.MustCompile(`(?is)<(script|noscript|template)\b.*?</(script|noscript|template)\s*>`)
+	cssClass  = regexp.MustCompile(`\.([A-Za-z_][\w-]*)\s*\{([^}]*)\}`)
+	htmlSpace = regexp.MustCompile(`[ \t\n\f]+`)
+)
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "blockquote": true, "pre": true, "ul": true,
+	"ol": true, "table": true, "section": true, "article": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "dt": true,
+	"dd": true, "body": true,
+}
+
+var headings = map[string]bool{"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true}
+
+// element is open html element, style is inherited from parents
+type element struct {
+	name  string
+	style core.Style
+	skip  bool
+}
+
+// htmlDoc collects lines of html document, lines are made of styled runs
+type htmlDoc struct {
+	document
+	stack   []element
+	classes map[string]string
+	line    []core.Run
+	pre     int
+}
+
+// fromHTML converts html document, exported Google Docs documents mostly style text with
+// classes defined in <style> so those are applied too
+func fromHTML(text string) (document, error) {
+	h := htmlDoc{document: document{titleAt: -1}, classes: map[string]string{}}
+	h.stack = []element{{}}
+
+	dec := xml.NewDecoder(strings.NewReader(scripts.ReplaceAllString(text, "")))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var title strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return document{}, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			h.start(t)
+		case xml.EndElement:
+			h.end(strings.ToLower(t.Name.Local))
+		case xml.CharData:
+			top := h.stack[len(h.stack)-1]
+			switch {
+			case top.name == "style":
+				for _, m := range cssClass.FindAllStringSubmatch(string(t), -1) {
+					h.classes[m[1]] += ";" + m[2]
+				}
+			case top.name == "title":
+				title.Write(t)
+			case !top.skip:
+				h.text(string(t), top.style)
+			}
+		}
+	}
+	h.newline()
+
+	if h.titleAt < 0 {
+		h.title = title.String()
+	}
+
+	return h.document, nil
+}
+
+func (h *htmlDoc) start(t xml.StartElement) {
+	name := strings.ToLower(t.Name.Local)
+	parent := h.stack[len(h.stack)-1]
+	el := element{name: name, style: parent.style, skip: parent.skip || name == "head" || name == "svg"}
+
+	switch name {
+	case "b", "strong":
+		el.style.Bold = true
+	case "i", "em":
+		el.style.Italic = true
+	case "u", "ins":
+		el.style.Underline = true
+	case "pre":
+		h.pre++
+	}
+	if headings[name] {
+		el.style.Title = true
+	}
+
+	for _, a := range t.Attr {
+		switch strings.ToLower(a.Name.Local) {
+		case "class":
+			for _, c := range strings.Fields(a.Value) {
+				applyCSS(&el.style, h.classes[c])
+			}
+		case "style":
+			applyCSS(&el.style, a.Value)
+		}
+	}
+
+	switch {
+	case name == "br":
+		h.flush()
+	case blockElements[name]:
+		h.newline()
+	}
+	switch name {
+	case "li":
+		h.line = append(h.line, core.Run{Text: "- "})
+	case "td", "th":
+		if len(h.line) != 0 {
+			h.line = append(h.line, core.Run{Text: "    "})
+		}
+	}
+
+	h.stack = append(h.stack, el)
+}
+
+func (h *htmlDoc) end(name string) {
+	// non strict decoder closes mismatched elements itself so the top is the one closed
+	if len(h.stack) == 1 {
+		return
+	}
+	h.stack = h.stack[:len(h.stack)-1]
+
+	if name == "pre" {
+		h.pre--
+	}
+	if headings[name] && h.titleAt < 0 {
+		var sb strings.Builder
+		for _, r := range h.line {
+			sb.WriteString(r.Text)
+		}
+		if title := strings.TrimSpace(sb.String()); title != "" {
+			h.titleAt, h.title = len(h.lines), title
+		}
+	}
+	if blockElements[name] {
+		h.newline()
+	}
+}
+
+func (h *htmlDoc) text(s string, style core.Style) {
+	if h.pre == 0 {
+		s = htmlSpace.ReplaceAllString(s, " ")
+		if len(h.line) == 0 || strings.HasSuffix(h.line[len(h.line)-1].Text, " ") {
+			s = strings.TrimLeft(s, " ")
+		}
+	}
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+
+	for i, part := range strings.Split(s, "\n") {
+		if i != 0 {
+			h.flush()
+		}
+		if part != "" {
+			h.line = append(h.line, core.Run{Text: part, Style: style})
+		}
+	}
+}
+
+// newline ends line if it is not empty
+func (h *htmlDoc) newline() {
+	if len(h.line) != 0 {
+		h.flush()
+	}
+}
+
+// flush ends line even if it is empty, tags are closed in reverse order because markup
+// closes only the innermost tag
+func (h *htmlDoc) flush() {
+	if n := len(h.line); n != 0 && h.pre == 0 {
+		h.line[n-1].Text = strings.TrimRight(h.line[n-1].Text, " ")
+	}
+
+	var sb strings.Builder
+	var open []string
+	for _, r := range h.line {
+		var want []string
+		for _, t := range []struct {
+			on  bool
+			tag string
+		}{{r.Title, "<t>"}, {r.Bold, "<b>"}, {r.Italic, "<i>"}, {r.Underline, "<u>"}} {
+			if t.on {
+				want = append(want, t.tag)
+			}
+		}
+
+		keep := 0
+		for keep < len(open) && contains(want, open[keep]) {
+			keep++
+		}
+		for i := len(open) - 1; i >= keep; i-- {
+			sb.WriteString(open[i])
+		}
+		open = open[:keep]
+		for _, tag := range want {
+			if !contains(open, tag) {
+				sb.WriteString(tag)
+				open = append(open, tag)
+			}
+		}
+
+		sb.WriteString(r.Text)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString(open[i])
+	}
+
+	h.lines = append(h.lines, sb.String())
+	h.line = nil
+}
+
+// applyCSS applies font-weight, font-style and text-decoration declarations to style
+func applyCSS(style *core.Style, css string) {
+	for _, decl := range strings.Split(css, ";") {
+		key, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important")))
+
+		switch key {
+		case "font-weight":
+			switch value {
+			case "bold", "bolder", "600", "700", "800", "900":
+				style.Bold = true
+			case "normal", "lighter", "100", "200", "300", "400", "500":
+				style.Bold = false
+			}
+		case "font-style":
+			style.Italic = value == "italic" || value == "oblique"
+		case "text-decoration", "text-decoration-line":
+			style.Underline = strings.Contains(value, "underline")
+		}
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
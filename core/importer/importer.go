@@ -0,0 +1,193 @@
+// Package importer converts markdown, html and plain text files to draft notes, headings,
+// bold, italic and underline are translated to <t>, <b>, <i> and <u> markup
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"myNotes/core"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// limits of single import, files inside zip archives count separately
+const (
+	MaxFiles     = 200
+	MaxFileSize  = 1 << 20
+	MaxTotalSize = 32 << 20
+)
+
+// import errors, all except ErrTooManyFiles are reported per file
+var (
+	ErrFormat       = sterr.New("unsupported file %s, expected .md, .html, .txt or .zip")
+	ErrEncoding     = sterr.New("file %s is not utf-8 text")
+	ErrEmpty        = sterr.New("file %s is empty")
+	ErrTooLarge     = sterr.New("file %s exceeds import limit of %d bytes")
+	ErrArchive      = sterr.New("invalid zip archive %s")
+	ErrHTML         = sterr.New("invalid html in %s")
+	ErrTooManyFiles = sterr.New("import is limited to %d files")
+)
+
+// File is uploaded file
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Entry is result of importing one file, Err explains why the file was skipped, files from
+// archives are named archive.zip/path/file.md
+type Entry struct {
+	File string
+	Note core.Note
+	Err  error
+}
+
+// Convert converts files to draft notes, zip archives are expanded, nested archives are not
+func Convert(files []File) ([]Entry, error) {
+	var entries []Entry
+	total := 0
+	for _, f := range files {
+		if strings.ToLower(path.Ext(f.Name)) != ".zip" {
+			entries = append(entries, convert(f.Name, f.Data))
+		} else {
+			entries = append(entries, unzip(f, &total)...)
+		}
+
+		if len(entries) > MaxFiles {
+			return nil, ErrTooManyFiles.Args(MaxFiles)
+		}
+	}
+
+	return entries, nil
+}
+
+// unzip converts files of archive, total is amount of bytes read from all archives so far
+func unzip(f File, total *int) []Entry {
+	zr, err := zip.NewReader(bytes.NewReader(f.Data), int64(len(f.Data)))
+	if err != nil {
+		return []Entry{{File: f.Name, Err: ErrArchive.Args(f.Name).Wrap(err)}}
+	}
+
+	var entries []Entry
+	for _, zf := range zr.File {
+		base := path.Base(zf.Name)
+		if zf.FileInfo().IsDir() || strings.HasPrefix(zf.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+
+		name := f.Name + "/" + zf.Name
+		data, err := readZipped(zf, name, MaxTotalSize-*total)
+		*total += len(data)
+		if err != nil {
+			entries = append(entries, Entry{File: name, Err: err})
+			continue
+		}
+
+		if strings.ToLower(path.Ext(zf.Name)) == ".zip" {
+			entries = append(entries, Entry{File: name, Err: ErrFormat.Args(name)})
+			continue
+		}
+
+		entries = append(entries, convert(name, data))
+	}
+
+	return entries
+}
+
+// readZipped reads file of archive, declared size is not trusted, budget is amount of bytes
+// archives can still use
+func readZipped(zf *zip.File, name string, budget int) ([]byte, error) {
+	limit := MaxFileSize
+	if budget < limit {
+		limit = budget
+	}
+	if zf.UncompressedSize64 > uint64(limit) {
+		return nil, ErrTooLarge.Args(name, limit)
+	}
+
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, ErrArchive.Args(name).Wrap(err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, int64(limit)+1))
+	if err != nil {
+		return nil, ErrArchive.Args(name).Wrap(err)
+	}
+	if len(data) > limit {
+		return nil, ErrTooLarge.Args(name, limit)
+	}
+	return data, nil
+}
+
+func convert(name string, data []byte) Entry {
+	nt, err := Note(name, data)
+	return Entry{File: name, Note: nt, Err: err}
+}
+
+// document is converted file, lines are in markup
+type document struct {
+	lines []string
+	// title is plain text of first heading or title of html page
+	title string
+	// titleAt is index of line with the first heading, -1 if there is none
+	titleAt int
+}
+
+var normalize = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\t", "    ")
+
+// Note converts file to draft note, format is chosen by extension, Name is the first heading
+// or file name, heading on the first line is removed from content so it is not repeated
+func Note(name string, data []byte) (core.Note, error) {
+	if len(data) > MaxFileSize {
+		return core.Note{}, ErrTooLarge.Args(name, MaxFileSize)
+	}
+	if !utf8.Valid(data) {
+		return core.Note{}, ErrEncoding.Args(name)
+	}
+
+	text := normalize.Replace(strings.TrimPrefix(string(data), "\ufeff"))
+	if strings.TrimSpace(text) == "" {
+		return core.Note{}, ErrEmpty.Args(name)
+	}
+
+	var doc document
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		doc = markdown(text)
+	case ".html", ".htm":
+		var err error
+		doc, err = fromHTML(text)
+		if err != nil {
+			return core.Note{}, ErrHTML.Args(name).Wrap(err)
+		}
+	case ".txt", ".text":
+		doc = document{lines: strings.Split(text, "\n"), titleAt: -1}
+	default:
+		return core.Note{}, ErrFormat.Args(name)
+	}
+
+	lines := doc.lines
+	if doc.titleAt == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) != 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) != 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	nt := core.Note{Name: strings.Join(strings.Fields(doc.title), " "), Content: strings.Join(lines, "\n")}
+	if nt.Name == "" {
+		base := path.Base(name)
+		nt.Name = strings.TrimSpace(strings.TrimSuffix(base, path.Ext(base)))
+	}
+
+	return nt, nil
+}
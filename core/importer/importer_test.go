@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNote(t *testing.T) {
+	testCases := []struct {
+		desc, file, data string
+		name, content    string
+		err              error
+	}{
+		{
+			desc: "markdown",
+			file: "algebra.md",
+			data: "# Linear *Algebra*\n\nVectors are **bold** and _italic_ or <u>under</u>,\nsecond line\n\n## Matrix\n\n* one\n* two `a*b`\n\n```\n**code**\n```\n\n    indented\n\n\\*not\\* [link](https://x.y) ***both***",
+			name: "Linear Algebra",
+			content: "Vectors are <b>bold<b> and <i>italic<i> or <u>under<u>, second line\n<t>Matrix<t>\n- one\n- two a*b\n**code**\n    indented\n" +
+				"*not* link (https://x.y) <b><i>both<i><b>",
+		},
+		{
+			desc:    "markdown setext and unpaired",
+			file:    "notes.markdown",
+			data:    "intro *unclosed\n\nTitle\n=====\nhard  \nbreak &amp; **<b>nested</b>**",
+			name:    "Title",
+			content: "intro *unclosed\n<t>Title<t>\nhard\nbreak & <b>nested<b>",
+		},
+		{
+			desc: "google docs html",
+			file: "Essay.html",
+			data: `<html><head><meta content="text/html; charset=UTF-8" http-equiv="content-type"><style type="text/css">.c1{font-weight:700}.c2{font-style:italic;text-decoration:underline}</style><title>Essay</title></head>` +
+				`<body class="c3"><p class="c0"><span class="c1">Bold</span><span> and </span><span class="c2">both&nbsp;styles</span></p><p><span></span></p><ul><li>item</li></ul>` +
+				`<b style="font-weight:normal"><p>plain</p></b><script>if (a < b) {}</script><h2>Later heading</h2></body></html>`,
+			name:    "Later heading",
+			content: "<b>Bold<b> and <i><u>both styles<u><i>\n- item\nplain\n<t>Later heading<t>",
+		},
+		{
+			desc:    "html title",
+			file:    "page.htm",
+			data:    "<!DOCTYPE html><html><head><title>Page  title</title></head><body><p>one<br>two</p></body></html>",
+			name:    "Page title",
+			content: "one\ntwo",
+		},
+		{
+			desc:    "plain text",
+			file:    "dir/todo list.txt",
+			data:    "\ufeffbuy milk\r\n\tand <b>bread<b>\r\n",
+			name:    "todo list",
+			content: "buy milk\n    and <b>bread<b>",
+		},
+		{desc: "unsupported", file: "doc.docx", data: "x", err: ErrFormat},
+		{desc: "empty", file: "a.md", data: " \n", err: ErrEmpty},
+		{desc: "encoding", file: "a.txt", data: "\xff\xfe", err: ErrEncoding},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			nt, err := Note(tC.file, []byte(tC.data))
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if nt.Name != tC.name || nt.Content != tC.content {
+				t.Errorf("\n%q\n%q", nt.Name, nt.Content)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []File{
+		{"notes/a.md", []byte("# A")},
+		{"notes/b.txt", []byte("b")},
+		{"__MACOSX/notes/._a.md", []byte("junk")},
+		{"notes/inner.zip", []byte("zip")},
+		{"notes/big.txt", []byte(strings.Repeat("x", MaxFileSize+1))},
+	} {
+		w, _ := zw.Create(f.Name)
+		w.Write(f.Data)
+	}
+	zw.Close()
+
+	entries, err := Convert([]File{{"export.zip", buf.Bytes()}, {"c.md", []byte("c")}, {"broken.zip", []byte("no")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		file, name string
+		err        error
+	}{
+		{"export.zip/notes/a.md", "A", nil},
+		{"export.zip/notes/b.txt", "b", nil},
+		{"export.zip/notes/inner.zip", "", ErrFormat},
+		{"export.zip/notes/big.txt", "", ErrTooLarge},
+		{"c.md", "c", nil},
+		{"broken.zip", "", ErrArchive},
+	}
+	if len(entries) != len(expected) {
+		t.Fatal(entries)
+	}
+	for i, e := range expected {
+		if entries[i].File != e.file || !errors.Is(entries[i].Err, e.err) || e.err == nil && entries[i].Note.Name != e.name {
+			t.Errorf("%d: %+v", i, entries[i])
+		}
+	}
+
+	_, err = Convert(make([]File, MaxFiles+1))
+	if !errors.Is(err, ErrTooManyFiles) {
+		t.Error(err)
+	}
+}
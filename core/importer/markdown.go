@@ -0,0 +1,321 @@
+package importer
+
+import (
+	"html"
+	"myNotes/core"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextLine    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	listItem      = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])[ \t]+`)
+	fence         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	blockQuote    = regexp.MustCompile(`^ {0,3}> ?`)
+
+	link     = regexp.MustCompile(`^(!?)\[([^\]]*)\]\(\s*<?([^)\s>]*)>?(?:\s+"[^"]*")?\s*\)`)
+	autolink = regexp.MustCompile(`^<((?:https?|mailto):[^>\s]+|[^>\s@]+@[^>\s]+)>`)
+	htmlTag  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)\b[^>]*>`)
+)
+
+// plain strips markup tags of headings used as note name
+var plain = core.NMarkup(nil)
+
+// markdown converts CommonMark blocks to lines, paragraph becomes one line, code is kept as
+// it is and other block syntax is reduced to text
+func markdown(text string) document {
+	doc := document{titleAt: -1}
+
+	var para []string
+	flush := func() {
+		if para != nil {
+			doc.lines = append(doc.lines, inline(strings.Join(para, "")))
+			para = nil
+		}
+	}
+	heading := func(text string) {
+		flush()
+		content := inline(text)
+		if content == "" {
+			return
+		}
+		if doc.titleAt < 0 {
+			doc.titleAt, doc.title = len(doc.lines), plain.Plain(content)
+		}
+		doc.lines = append(doc.lines, "<t>"+content+"<t>")
+	}
+
+	var closing string
+	var hardBreak bool
+	for _, line := range strings.Split(text, "\n") {
+		if closing != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), closing) {
+				closing = ""
+			} else {
+				doc.lines = append(doc.lines, line)
+			}
+			continue
+		}
+
+		line = blockQuote.ReplaceAllString(line, "")
+
+		if m := fence.FindStringSubmatch(line); m != nil {
+			flush()
+			closing = m[1]
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			heading(m[2])
+			continue
+		}
+
+		if para != nil && setextLine.MatchString(line) {
+			text := strings.Join(para, "")
+			para = nil
+			heading(text)
+			continue
+		}
+
+		if thematicBreak.MatchString(line) {
+			flush()
+			continue
+		}
+
+		if m := listItem.FindStringSubmatch(line); m != nil {
+			flush()
+			marker := m[2]
+			if strings.ContainsAny(marker, "-*+") {
+				marker = "-"
+			}
+			rest := line[len(m[0]):]
+			hardBreak = strings.HasSuffix(rest, "  ")
+			para = []string{m[1] + marker + " " + strings.TrimRight(rest, " ")}
+			continue
+		}
+
+		if para == nil && strings.HasPrefix(line, "    ") {
+			doc.lines = append(doc.lines, strings.TrimRight(line, " "))
+			continue
+		}
+
+		switch {
+		case para == nil:
+		case hardBreak:
+			para = append(para, "\n")
+		default:
+			para = append(para, " ")
+		}
+
+		// hard line break is two trailing spaces or backslash
+		hardBreak = strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		para = append(para, strings.TrimSuffix(strings.Trim(line, " "), "\\"))
+	}
+	flush()
+
+	return doc
+}
+
+// token is piece of inline markdown, delimiters that find their pair become markup tags
+// and the rest is text
+type token struct {
+	text, delim string
+	open, close bool
+	// raw text is not unescaped
+	raw bool
+	// html tags are dropped if they have no pair
+	html  bool
+	tag   string
+	opens bool
+}
+
+var delimTags = map[string]string{"*": "<i>", "_": "<i>", "**": "<b>", "__": "<b>"}
+
+var htmlTags = map[string]string{"b": "<b>", "strong": "<b>", "i": "<i>", "em": "<i>", "u": "<u>", "ins": "<u>"}
+
+var entities = strings.NewReplacer("\u2003", "    ", "\u00a0", " ")
+
+// inline converts emphasis, code spans, links and inline html of paragraph
+func inline(s string) string {
+	var toks []token
+	var text strings.Builder
+	push := func(t token) {
+		if text.Len() != 0 {
+			toks = append(toks, token{text: text.String()})
+			text.Reset()
+		}
+		toks = append(toks, t)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] < utf8.RuneSelf && isPunct(rune(s[i+1])):
+			push(token{text: s[i+1 : i+2], raw: true})
+			i += 2
+			continue
+		case c == '`':
+			n := run(s[i:], '`')
+			if end := strings.Index(s[i+n:], s[i:i+n]); end >= 0 && run(s[i+n+end:], '`') == n {
+				code := s[i+n : i+n+end]
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				push(token{text: code, raw: true})
+				i += 2*n + end
+				continue
+			}
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '[' || c == '!':
+			if m := link.FindStringSubmatch(s[i:]); m != nil {
+				label := inline(m[2])
+				switch {
+				case m[1] != "":
+					// image is replaced by its alt text
+				case label == "" || m[3] == "" || plain.Plain(label) == m[3]:
+					label += m[3]
+				default:
+					label += " (" + m[3] + ")"
+				}
+				push(token{text: label, raw: true})
+				i += len(m[0])
+				continue
+			}
+		case c == '<':
+			if m := autolink.FindStringSubmatch(s[i:]); m != nil {
+				push(token{text: m[1], raw: true})
+				i += len(m[0])
+				continue
+			}
+			if m := htmlTag.FindStringSubmatch(s[i:]); m != nil {
+				name := strings.ToLower(m[2])
+				switch {
+				case name == "br":
+					push(token{text: "\n", raw: true})
+				case htmlTags[name] != "":
+					push(token{delim: htmlTags[name], open: m[1] == "", close: m[1] != "", html: true})
+				}
+				i += len(m[0])
+				continue
+			}
+		case c == '*' || c == '_':
+			n := run(s[i:], c)
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			next, _ := utf8.DecodeRuneInString(s[i+n:])
+			if i == 0 {
+				prev = ' '
+			}
+			if i+n == len(s) {
+				next = ' '
+			}
+
+			left := !unicode.IsSpace(next) && (!isPunct(next) || unicode.IsSpace(prev) || isPunct(prev))
+			right := !unicode.IsSpace(prev) && (!isPunct(prev) || unicode.IsSpace(next) || isPunct(next))
+			open, close := left, right
+			if c == '_' {
+				open = left && (!right || isPunct(prev))
+				close = right && (!left || isPunct(next))
+			}
+
+			if n > 3 || !open && !close {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+
+			d := string(c)
+			parts := []string{d}
+			switch {
+			case n == 2:
+				parts = []string{d + d}
+			case n == 3 && close:
+				parts = []string{d, d + d}
+			case n == 3:
+				parts = []string{d + d, d}
+			}
+			for _, p := range parts {
+				push(token{text: p, delim: p, open: open, close: close})
+			}
+			i += n
+			continue
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+	push(token{})
+
+	// closer pairs with nearest opener of the same kind, openers in between stay text
+	var stack []int
+	for i, t := range toks {
+		if t.delim == "" {
+			continue
+		}
+		if t.close {
+			j := len(stack) - 1
+			for j >= 0 && toks[stack[j]].delim != t.delim {
+				j--
+			}
+			if j >= 0 {
+				tag := t.delim
+				if !t.html {
+					tag = delimTags[t.delim]
+				}
+				toks[stack[j]].tag, toks[stack[j]].opens, toks[i].tag = tag, true, tag
+				stack = stack[:j]
+				continue
+			}
+		}
+		if t.open {
+			stack = append(stack, i)
+		}
+	}
+
+	// markup closes tag by repeating it so nested tag of the same kind is left out
+	var sb strings.Builder
+	depth := map[string]int{}
+	for _, t := range toks {
+		switch {
+		case t.tag != "" && t.opens:
+			if depth[t.tag] == 0 {
+				sb.WriteString(t.tag)
+			}
+			depth[t.tag]++
+		case t.tag != "":
+			depth[t.tag]--
+			if depth[t.tag] == 0 {
+				sb.WriteString(t.tag)
+			}
+		case t.html:
+		case t.raw:
+			sb.WriteString(t.text)
+		default:
+			sb.WriteString(entities.Replace(html.UnescapeString(t.text)))
+		}
+	}
+
+	return sb.String()
+}
+
+func run(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// log formats
+const (
+	JSONLog = "json"
+	TextLog = "text"
+)
+
+// Redacted replaces value of sensitive log attributes
+const Redacted = "[redacted]"
+
+// ErrLogFormat is returned for unknown log format
+var ErrLogFormat = sterr.New("unknown log format %q, expected json or text")
+
+// SensitiveKeys are log attribute keys whose values never reach the output, comparison
+// ignores case
+var SensitiveKeys = map[string]bool{
+	"password":      true,
+	"code":          true,
+	"email":         true,
+	"token":         true,
+	"secret":        true,
+	"recovery":      true,
+	"cookie":        true,
+	"authorization": true,
+}
+
+// NLogger creates leveled structured logger writing to out in given format, sensitive
+// attributes are redacted
+func NLogger(out io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: Redact}
+
+	switch format {
+	case JSONLog:
+		return slog.New(slog.NewJSONHandler(out, opts)), nil
+	case TextLog:
+		return slog.New(slog.NewTextHandler(out, opts)), nil
+	}
+
+	return nil, ErrLogFormat.Args(format)
+}
+
+// Redact hides value of attribute if its key is sensitive, it is meant to be used as
+// slog.HandlerOptions.ReplaceAttr
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if SensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	return a
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := NLogger(&buf, JSONLog, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info("login", "name", "bob", "Password", "hunter2", slog.Group("account", "email", "bob@gmail.com", "code", "123456"))
+
+	var entry struct {
+		Name, Password string
+		Account        struct{ Email, Code string }
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err, buf.String())
+	}
+
+	if entry.Name != "bob" || entry.Password != Redacted || entry.Account.Email != Redacted || entry.Account.Code != Redacted {
+		t.Error(buf.String())
+	}
+}
+
+func TestLoggerFormat(t *testing.T) {
+	testCases := []struct {
+		format string
+		ok     bool
+	}{
+		{JSONLog, true},
+		{TextLog, true},
+		{"xml", false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.format, func(t *testing.T) {
+			_, err := NLogger(&bytes.Buffer{}, tC.format, slog.LevelInfo)
+			if (err == nil) != tC.ok {
+				t.Error(err)
+			}
+		})
+	}
+}
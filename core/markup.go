@@ -0,0 +1,235 @@
+package core
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultColors are colors of markup used when account did not configure its own
+var DefaultColors = []string{"#b03830", "#b0972a", "#5d62f0"}
+
+var safeColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]{1,20}|rgba?\([\d\s,.%]+\))$`)
+
+// AttachmentPath is path attachments are served from, thumbnail of image is at
+// AttachmentPath + id + "/thumb"
+const AttachmentPath = "/a/"
+
+// embed tag is <embed:ID> where ID is id of attachment
+var (
+	embedTag  = regexp.MustCompile(`^<embed:([0-9a-f]+\.[0-9a-z]+)>`)
+	embedTags = regexp.MustCompile(`<embed:([0-9a-f]+\.[0-9a-z]+)>`)
+)
+
+// ImageExts are extensions of attachments that are displayed as images
+var ImageExts = map[string]bool{"png": true, "jpg": true, "gif": true, "webp": true}
+
+// Markup converts note content to html the same way web/tools/markdown.js does, unlike the
+// script it escapes text so content can not inject html
+type Markup struct {
+	blocks []block
+	strip  *strings.Replacer
+}
+
+type block struct {
+	start, open string
+	style       Style
+}
+
+// Style is formatting of text, Color is empty or css color
+type Style struct {
+	Title, Bold, Italic, Underline bool
+	Color                          string
+}
+
+// Run is piece of text with single style
+type Run struct {
+	Text string
+	Style
+}
+
+// NMarkup creates markup with colors of account, <1> uses first color and so on, colors
+// that are not plain css colors are ignored
+func NMarkup(colors []string) *Markup {
+	if colors == nil {
+		colors = DefaultColors
+	}
+
+	m := &Markup{blocks: []block{
+		{"<t>", `<span class="title">`, Style{Title: true}},
+		{"<b>", `<span class="bold">`, Style{Bold: true}},
+		{"<i>", `<span class="italic">`, Style{Italic: true}},
+		{"<u>", `<span class="underline">`, Style{Underline: true}},
+	}}
+	for i, c := range colors {
+		b := block{start: "<" + strconv.Itoa(i+1) + ">", open: `<span class="base">`}
+		if safeColor.MatchString(c) {
+			b.open = `<span class="base" style="color: ` + c + `;">`
+			b.style.Color = c
+		}
+		m.blocks = append(m.blocks, b)
+	}
+
+	var pairs []string
+	for _, b := range m.blocks {
+		pairs = append(pairs, b.start, "")
+	}
+	m.strip = strings.NewReplacer(pairs...)
+
+	return m
+}
+
+var layout = strings.NewReplacer("\n", "<br><hr>", "    ", "<tab></tab>")
+
+// Render returns html of content, tag is closed by repeating it and unclosed tags are closed
+// at the end
+func (m *Markup) Render(raw string) string {
+	var sb strings.Builder
+	m.walk(raw, func(text string, _ []block) {
+		sb.WriteString(html.EscapeString(text))
+	}, func(b block, open bool) {
+		if open {
+			sb.WriteString(b.open)
+		} else {
+			sb.WriteString("</span>")
+		}
+	}, func(id string) {
+		sb.WriteString(embedHTML(id))
+	})
+
+	return layout.Replace(sb.String())
+}
+
+// embedHTML shows thumbnail of image linking to full image, other files are download links,
+// id is safe to put into html as it matched embedTag
+func embedHTML(id string) string {
+	url := AttachmentPath + id
+	if ImageExts[id[strings.LastIndexByte(id, '.')+1:]] {
+		return `<a class="embed" href="` + url + `"><img src="` + url + `/thumb" alt="attachment" loading="lazy"></a>`
+	}
+	return `<a class="embed" href="` + url + `" download>` + id + `</a>`
+}
+
+// Embeds returns ids of attachments content embeds
+func Embeds(raw string) []string {
+	var ids []string
+	for _, m := range embedTags.FindAllStringSubmatch(raw, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// Lines splits content to lines of styled runs, text is not escaped
+func (m *Markup) Lines(raw string) [][]Run {
+	lines := [][]Run{nil}
+	m.walk(raw, func(text string, stack []block) {
+		var style Style
+		for _, b := range stack {
+			style.Title = style.Title || b.style.Title
+			style.Bold = style.Bold || b.style.Bold
+			style.Italic = style.Italic || b.style.Italic
+			style.Underline = style.Underline || b.style.Underline
+			if b.style.Color != "" {
+				style.Color = b.style.Color
+			}
+		}
+
+		for i, part := range strings.Split(text, "\n") {
+			if i != 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], Run{part, style})
+			}
+		}
+	}, func(block, bool) {}, func(id string) {
+		lines[len(lines)-1] = append(lines[len(lines)-1], Run{Text: "[attachment " + id + "]"})
+	})
+
+	return lines
+}
+
+// walk splits raw by tags the same way web/tools/markdown.js does, text gets every piece
+// between tags with blocks it is inside of, tag gets every opened and closed block and
+// embed gets id of every embedded attachment
+func (m *Markup) walk(raw string, text func(string, []block), tag func(b block, open bool), embed func(id string)) {
+	var stack []block
+	last := 0
+
+	flush := func(i int) {
+		if i > last {
+			text(raw[last:i], stack)
+		}
+	}
+
+outer:
+	for i := 0; i < len(raw); {
+		if n := len(stack); n != 0 && strings.HasPrefix(raw[i:], stack[n-1].start) {
+			flush(i)
+			tag(stack[n-1], false)
+			i += len(stack[n-1].start)
+			last, stack = i, stack[:n-1]
+			continue
+		}
+
+		if raw[i] == '<' {
+			if e := embedTag.FindStringSubmatch(raw[i:]); e != nil {
+				flush(i)
+				embed(e[1])
+				i += len(e[0])
+				last = i
+				continue
+			}
+		}
+
+		for _, b := range m.blocks {
+			if strings.HasPrefix(raw[i:], b.start) {
+				flush(i)
+				tag(b, true)
+				i += len(b.start)
+				last, stack = i, append(stack, b)
+				continue outer
+			}
+		}
+
+		i++
+	}
+
+	flush(len(raw))
+	for i := len(stack) - 1; i >= 0; i-- {
+		tag(stack[i], false)
+	}
+}
+
+// Plain returns content without markup and embed tags
+func (m *Markup) Plain(raw string) string {
+	return m.strip.Replace(embedTags.ReplaceAllString(raw, ""))
+}
+
+// Slug turns name into readable url segment
+func Slug(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() != 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+
+		if sb.Len() >= 60 {
+			break
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "note"
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkupRender(t *testing.T) {
+	testCases := []struct {
+		desc, colors, in, out string
+	}{
+		{desc: "plain", in: "hello", out: "hello"},
+		{desc: "bold", in: "<b>hi<b> there", out: `<span class="bold">hi</span> there`},
+		{desc: "nested", in: "<t><i>a<i>b<t>", out: `<span class="title"><span class="italic">a</span>b</span>`},
+		{desc: "color", in: "<1>red<1>", out: `<span class="base" style="color: #b03830;">red</span>`},
+		{desc: "unclosed", in: "<u><b>x", out: `<span class="underline"><span class="bold">x</span></span>`},
+		{desc: "layout", in: "a\n    b", out: "a<br><hr><tab></tab>b"},
+		{desc: "escaped", in: `<script>alert("x")</script><b>&<b>`, out: `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;<span class="bold">&amp;</span>`},
+		{desc: "unsafe color", colors: `red;background:url(x)`, in: "<1>a<1>", out: `<span class="base">a</span>`},
+		{desc: "unknown color", in: "<4>a<4>", out: "&lt;4&gt;a&lt;4&gt;"},
+		{desc: "embed image", in: "<b>see<embed:0a1b.png><b>", out: `<span class="bold">see<a class="embed" href="/a/0a1b.png"><img src="/a/0a1b.png/thumb" alt="attachment" loading="lazy"></a></span>`},
+		{desc: "embed file", in: "<embed:0a1b.pdf>", out: `<a class="embed" href="/a/0a1b.pdf" download>0a1b.pdf</a>`},
+		{desc: "invalid embed", in: `<embed:x.png"onerror=y>`, out: "&lt;embed:x.png&#34;onerror=y&gt;"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var colors []string
+			if tC.colors != "" {
+				colors = []string{tC.colors}
+			}
+
+			out := NMarkup(colors).Render(tC.in)
+			if out != tC.out {
+				t.Error(out)
+			}
+		})
+	}
+}
+
+func TestMarkupLines(t *testing.T) {
+	lines := NMarkup(nil).Lines("<b>a\n<1>b<1><b>c\n\n<i>d")
+	expected := [][]Run{
+		{{"a", Style{Bold: true}}},
+		{{"b", Style{Bold: true, Color: "#b03830"}}, {"c", Style{}}},
+		nil,
+		{{"d", Style{Italic: true}}},
+	}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Error(lines)
+	}
+}
+
+func TestEmbeds(t *testing.T) {
+	m := NMarkup(nil)
+	raw := "a<embed:0a.png>\n<b><embed:1b.pdf><b><embed:zz.png>"
+	if ids := Embeds(raw); !reflect.DeepEqual(ids, []string{"0a.png", "1b.pdf"}) {
+		t.Error(ids)
+	}
+	if plain := m.Plain(raw); plain != "a\n<embed:zz.png>" {
+		t.Error(plain)
+	}
+	if lines := m.Lines(raw); len(lines) != 2 || lines[1][0].Text != "[attachment 1b.pdf]" {
+		t.Error(lines)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	testCases := []struct {
+		in, out string
+	}{
+		{"Linear Algebra", "linear-algebra"},
+		{"  --Derivace, integrály!  ", "derivace-integrály"},
+		{"???", "note"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.in, func(t *testing.T) {
+			if out := Slug(tC.in); out != tC.out {
+				t.Error(out)
+			}
+		})
+	}
+}
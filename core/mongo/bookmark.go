@@ -0,0 +1,109 @@
+package mongo
+
+import (
+	"myNotes/core"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Bookmark stores bookmark, bookmarking the same note again only changes its collection
+// and name
+func (d *DB) Bookmark(b core.Bookmark) error {
+	defer d.observe("Bookmark", time.Now())
+
+	_, err := d.Bookmarks.UpdateOne(d.Ctx,
+		bson.M{"owner": b.Owner, "note": b.Note, "deleted": false},
+		bson.M{
+			"$set":         bson.M{"name": b.Name, "collection": b.Collection},
+			"$setOnInsert": bson.M{"borndate": core.Time()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return core.EI(err)
+}
+
+// Unbookmark deletes bookmarks of note, it is not an error if there are none
+func (d *DB) Unbookmark(owner, note core.ID) error {
+	defer d.observe("Unbookmark", time.Now())
+
+	_, err := d.Bookmarks.DeleteMany(d.Ctx, bson.M{"owner": owner, "note": note})
+	return core.EI(err)
+}
+
+// BookmarkCount returns amount of bookmarks of account
+func (d *DB) BookmarkCount(owner core.ID) (int64, error) {
+	defer d.observe("BookmarkCount", time.Now())
+
+	count, err := d.Bookmarks.CountDocuments(d.Ctx, bson.M{"owner": owner})
+	return count, core.EI(err)
+}
+
+// UserBookmarks returns up to limit bookmarks of account that come after cursor, the newest
+// first, empty collection means all bookmarks
+func (d *DB) UserBookmarks(owner core.ID, collection string, cursor core.Cursor, limit int) ([]core.Bookmark, error) {
+	defer d.observe("UserBookmarks", time.Now())
+
+	filter := bson.M{"owner": owner}
+	if collection != "" {
+		filter["collection"] = collection
+	}
+	if cursor != (core.Cursor{}) {
+		filter["$and"] = bson.A{After(cursor, "borndate", "note")}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "borndate", Value: -1}, {Key: "note", Value: -1}}).
+		SetLimit(int64(limit))
+	cur, err := d.Bookmarks.Find(d.Ctx, filter, opts)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	bms := []core.Bookmark{}
+	err = core.EI(cur.All(d.Ctx, &bms))
+	return bms, err
+}
+
+// BookmarkCollections lists named collections of account with amounts of bookmarks
+func (d *DB) BookmarkCollections(owner core.ID) ([]core.CollectionCount, error) {
+	defer d.observe("BookmarkCollections", time.Now())
+
+	cur, err := d.Bookmarks.Aggregate(d.Ctx, []bson.M{
+		{"$match": bson.M{"owner": owner, "collection": bson.M{"$ne": ""}}},
+		{"$group": bson.M{"_id": "$collection", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+	})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	cols := []core.CollectionCount{}
+	err = core.EI(cur.All(d.Ctx, &cols))
+	return cols, err
+}
+
+// NotePreviews returns previews of notes by id, missing notes are not in the map
+func (d *DB) NotePreviews(ids []core.ID) (map[core.ID]core.NotePreview, error) {
+	defer d.observe("NotePreviews", time.Now())
+
+	cur, err := d.Notes.Find(d.Ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	var nts []core.NotePreview
+	err = cur.All(d.Ctx, &nts)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	previews := make(map[core.ID]core.NotePreview, len(nts))
+	for _, nt := range nts {
+		nt.Content = Preview(nt.Content)
+		previews[nt.ID] = nt
+	}
+
+	return previews, nil
+}
@@ -1,10 +1,11 @@
 package mongo
 
 import (
-	"fmt"
 	"myNotes/core"
+	"myNotes/core/taxonomy"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jakubDoka/gogen/str"
 
@@ -50,9 +51,20 @@ func Pull(field string, value interface{}) bson.M {
 	return bson.M{"$pull": bson.M{field: value}}
 }
 
+// After matches documents that come after cursor when sorted by date and id field from
+// the newest
+func After(c core.Cursor, date, id string) bson.M {
+	return Or(
+		bson.M{date: bson.M{"$lt": c.Date}},
+		bson.M{date: c.Date, id: bson.M{"$lt": c.ID}},
+	)
+}
+
 // NoteFilter creates filter for searching notes, passed url values have to contain keys with non empty
 // lists even if you are not filtering them, if first value under key is "" then its ignored
-func (d *DB) NoteFilter(values core.SearchRequest, published bool) bson.D {
+func (d *DB) NoteFilter(values core.SearchRequest, published bool) (bson.D, error) {
+	defer d.observe("NoteFilter", time.Now())
+
 	filter := bson.D{}
 
 	// author is really annoing but important
@@ -65,7 +77,7 @@ func (d *DB) NoteFilter(values core.SearchRequest, published bool) bson.D {
 		} else { // worst part, we have to collect ids of all possible authors
 			ids, err := d.AccountIdsForName(values.Author)
 			if err != nil {
-				panic(err)
+				return nil, err
 			}
 			if len(ids) != 0 {
 				eIds := make([]interface{}, len(ids))
@@ -77,31 +89,58 @@ func (d *DB) NoteFilter(values core.SearchRequest, published bool) bson.D {
 		}
 	}
 
-	// again if string query starts with ExactLabel we will pick only exact matches
-	// othervise use start with operation
+	tx, err := d.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	// subjects and themes known to taxonomy are matched exactly by key so aliases find
+	// the same notes, if string query starts with ExactLabel we will pick only exact
+	// matches othervise use start with operation
 	for field, val := range map[string]string{"subject": values.Subject, "theme": values.Theme, "name": values.Name} {
-		if val != "" {
-			if str.StartsWith(val, ExactLabel) {
-				filter = append(filter, E(field, val))
-			} else {
-				filter = append(filter, StartsWith(field, val[len(ExactLabel):]))
-			}
+		if val == "" {
+			continue
+		}
+
+		if term, ok := tx.Lookup(taxonomy.Kind(field), strings.TrimPrefix(val, ExactLabel)); ok {
+			filter = append(filter, E(field, term.Key))
+		} else if str.StartsWith(val, ExactLabel) {
+			filter = append(filter, E(field, val[len(ExactLabel):]))
+		} else {
+			filter = append(filter, StartsWith(field, val))
 		}
 	}
 
-	for field, val := range map[string]int{"year": values.Year, "month": values.Month, "school": School(values.School)} {
+	// unknown school is none same as before taxonomy existed
+	school, _ := tx.SchoolCode(values.School)
+	for field, val := range map[string]int{"year": values.Year, "month": values.Month, "school": school} {
 		if val != 0 {
 			filter = append(filter, E(field, val))
 		}
 	}
 
+	if values.Tags != "" {
+		query, err := core.ParseTagQuery(values.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		groups := make([]bson.M, len(query))
+		for i, alts := range query {
+			groups[i] = bson.M{"tags": bson.M{"$in": alts}}
+		}
+		if len(groups) != 0 {
+			filter = append(filter, E("$and", groups))
+		}
+	}
+
 	if published {
 		filter = append(filter, E("published", true))
 	}
 
-	fmt.Println(values.School)
+	d.Log.Debug("note filter", "school", values.School, "fields", len(filter))
 
-	return filter
+	return filter, nil
 }
 
 // StartsWith is query based of fields string start
@@ -113,14 +152,3 @@ func StartsWith(field, sub string) bson.E {
 func E(key string, value interface{}) bson.E {
 	return bson.E{Key: key, Value: value}
 }
-
-// School converts string to coresponding int value
-func School(name string) int {
-	name = strings.ToLower(name)
-	// 0 is considered none and so whatewer is inputted that is not contained in map will be none
-	return map[string]int{
-		"elementary-middle": 1,
-		"high":              2,
-		"university":        3,
-	}[name]
-}
@@ -2,25 +2,39 @@ package mongo
 
 import (
 	"context"
-	"fmt"
+	"log/slog"
 	"math/rand"
 	"myNotes/core"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jakubDoka/sterr"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // Collection names
 const (
 	Accounts = "Accounts"
 	Notes    = "Notes"
+	Comments = "Comments"
 	CounterN = "CounterN"
 	CounterA = "CounterA"
+	Audits   = "Audits"
+	Sessions = "Sessions"
+	Tokens   = "Tokens"
+
+	Attachments = "Attachments"
+	Notebooks   = "Notebooks"
+	Taxonomy    = "Taxonomy"
+	Follows     = "Follows"
+	Bookmarks   = "Bookmarks"
 
 	Verified   = "ok"
 	ExactLabel = "!"
@@ -44,11 +58,47 @@ var (
 		"subject",
 		"theme",
 		"author",
+		"notebook",
+		"tags",
+		"publishdate",
 	}
 
 	CommentIndex = []string{
 		"target.id",
 	}
+
+	SessionIndex = []string{
+		"hash",
+	}
+
+	TokenIndex = []string{
+		"hash",
+		"owner",
+	}
+
+	AttachmentIndex = []string{
+		"owner",
+		"borndate",
+	}
+
+	NotebookIndex = []string{
+		"owner",
+	}
+
+	TermIndex = []string{
+		"kind",
+	}
+
+	FollowIndex = []string{
+		"follower",
+		"account",
+		"subject",
+	}
+
+	BookmarkIndex = []string{
+		"owner",
+		"note",
+	}
 )
 
 // MakeIndex creates indexing from list of field names
@@ -69,6 +119,10 @@ var (
 	ErrNotAuthor    = sterr.New("you cannot edit note you are not author of")
 	ErrLimmitRate   = sterr.New("you have to wait %s to take another action")
 	ErrNotFound     = sterr.New("%s by %s not found")
+	ErrIndex        = sterr.New("failed to create indexes of %s")
+
+	// ErrNoDocuments is returned by driver when nothing matched the filter
+	ErrNoDocuments = mongo.ErrNoDocuments
 )
 
 // DB is main database interface
@@ -81,9 +135,17 @@ type DB struct {
 
 	Cancel context.CancelFunc
 
-	Accounts, Notes, Comments, Counter *mongo.Collection
+	Accounts, Notes, Comments, Counter, Audits, Sessions, Tokens, Attachments, Notebooks, Terms, Follows, Bookmarks *mongo.Collection
+
+	// Log receives debug information about queries, it is slog.Default() unless replaced
+	Log *slog.Logger
+
+	// Observe, if set, is called with duration of every DB method
+	Observe func(method string, took time.Duration)
 
 	vCodeFactory
+
+	taxonomy taxonomyCache
 }
 
 // NDB sets up a database
@@ -91,7 +153,7 @@ func NDB(clientAddress, name string) (rdb *DB, err error) {
 	if clientAddress == "default" {
 		clientAddress = "mongodb://127.0.0.1:27017"
 	}
-	db := DB{vCodeFactory: *nVCodeFactory()}
+	db := DB{vCodeFactory: *nVCodeFactory(), Log: slog.Default()}
 	db.Client, err = mongo.NewClient(options.Client().ApplyURI(clientAddress))
 	if err != nil {
 		return
@@ -105,21 +167,104 @@ func NDB(clientAddress, name string) (rdb *DB, err error) {
 
 	db.Database = db.Client.Database(name)
 
-	db.Accounts = db.Collection(Accounts)
-	_, err = db.Accounts.Indexes().CreateMany(db.Ctx, MakeIndex(AccountIndex))
+	// on failure connection is dropped so caller does not have to clean up half made DB
+	defer func() {
+		if err != nil {
+			db.Client.Disconnect(db.Ctx)
+			db.Cancel()
+		}
+	}()
+
+	if db.Accounts, err = db.indexed(Accounts, AccountIndex); err != nil {
+		return
+	}
+	if db.Notes, err = db.indexed(Notes, NoteIndex); err != nil {
+		return
+	}
+	if db.Comments, err = db.indexed(Comments, CommentIndex); err != nil {
+		return
+	}
+
+	db.Counter = db.Collection("Counter")
+	db.Audits = db.Collection(Audits)
+
+	if db.Sessions, err = db.indexed(Sessions, SessionIndex); err != nil {
+		return
+	}
+	_, err = db.Sessions.Indexes().CreateOne(db.Ctx, mongo.IndexModel{
+		Keys:    bson.M{"expireat": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		err = ErrIndex.Args(Sessions).Wrap(err)
+		return
+	}
+	if db.Tokens, err = db.indexed(Tokens, TokenIndex); err != nil {
+		return
+	}
+	if db.Attachments, err = db.indexed(Attachments, AttachmentIndex); err != nil {
+		return
+	}
+	if db.Notebooks, err = db.indexed(Notebooks, NotebookIndex); err != nil {
+		return
+	}
+	if db.Terms, err = db.indexed(Taxonomy, TermIndex); err != nil {
+		return
+	}
+	if err = db.seedTaxonomy(); err != nil {
+		return
+	}
+	if db.Follows, err = db.indexed(Follows, FollowIndex); err != nil {
+		return
+	}
+	if err = db.backfillPublishDates(); err != nil {
+		return
+	}
+	if db.Bookmarks, err = db.indexed(Bookmarks, BookmarkIndex); err != nil {
+		return
+	}
+
+	rdb = &db
+
+	return
+}
+
+// indexed returns collection with created indexes
+func (d *DB) indexed(name string, index []string) (*mongo.Collection, error) {
+	coll := d.Collection(name)
+	_, err := coll.Indexes().CreateMany(d.Ctx, MakeIndex(index))
 	if err != nil {
-		panic(err)
+		return nil, ErrIndex.Args(name).Wrap(err)
+	}
+
+	return coll, nil
+}
+
+func (d *DB) observe(method string, start time.Time) {
+	if d.Observe != nil {
+		d.Observe(method, time.Since(start))
 	}
+}
 
-	db.Notes = db.Collection(Notes)
-	_, err = db.Notes.Indexes().CreateMany(db.Ctx, MakeIndex(NoteIndex))
+// Totals counts accounts, notes and second factor sessions that did not expire before now (in
+// milliseconds)
+func (d *DB) Totals(now int64) (accounts, notes, sessions int64, err error) {
+	defer d.observe("Totals", time.Now())
+
+	accounts, err = d.Accounts.CountDocuments(d.Ctx, All)
 	if err != nil {
-		panic(err)
+		return 0, 0, 0, core.EI(err)
 	}
 
-	db.Counter = db.Collection("Counter")
+	notes, err = d.Notes.CountDocuments(d.Ctx, All)
+	if err != nil {
+		return 0, 0, 0, core.EI(err)
+	}
 
-	rdb = &db
+	sessions, err = d.Sessions.CountDocuments(d.Ctx, bson.M{"expires": bson.M{"$gt": now}})
+	if err != nil {
+		return 0, 0, 0, core.EI(err)
+	}
 
 	return
 }
@@ -133,6 +278,8 @@ type IDCounter struct {
 
 // NID creates new unique incremental id or reuses old one
 func (d *DB) NID() (core.ID, error) {
+	defer d.observe("NID", time.Now())
+
 	var c IDCounter
 	err := d.Counter.FindOne(d.Ctx, All).Decode(&c)
 	if err == mongo.ErrNoDocuments {
@@ -143,7 +290,7 @@ func (d *DB) NID() (core.ID, error) {
 	}
 
 	nid := c.Value + 1
-	fmt.Println(c.Free)
+	d.Log.Debug("allocating id", "free", c.Free)
 	if len(c.Free) != 0 {
 		nid = c.Free[0]
 		c.Free = c.Free[1:]
@@ -161,6 +308,8 @@ func (d *DB) NID() (core.ID, error) {
 
 // DID moves id to list of freed ids for reuse
 func (d *DB) DID(id core.ID) error {
+	defer d.observe("DID", time.Now())
+
 	_, err := d.Counter.UpdateOne(d.Ctx, All, Insert("free", 0, id))
 	return core.EI(err)
 }
@@ -179,12 +328,16 @@ func (d *DB) Coll(tp core.TargetType) *mongo.Collection {
 
 // Replace replaces a document in given collection
 func (d *DB) Replace(collection *mongo.Collection, doc core.IDer) error {
+	defer d.observe("Replace", time.Now())
+
 	_, err := collection.ReplaceOne(d.Ctx, ID(doc.AID()), doc)
 	return core.EI(err)
 }
 
 // Like can change or return whether user has liked the document and optionally return id
 func (d *DB) Like(id, user core.ID, collection *mongo.Collection, change bool) (liked bool, amount int, err error) {
+	defer d.observe("Like", time.Now())
+
 	var likes core.Likes
 	err = core.EI(collection.FindOne(d.Ctx, ID(id)).Decode(&likes))
 	if err != nil {
@@ -209,6 +362,45 @@ func (d *DB) Like(id, user core.ID, collection *mongo.Collection, change bool) (
 	return
 }
 
+// SetLike likes or unlikes document by user in single update so concurrent requests
+// cannot undo each other, likes are kept sorted, amount of likes after the change is
+// returned
+func (d *DB) SetLike(id, user core.ID, collection *mongo.Collection, value bool) (int, error) {
+	defer d.observe("SetLike", time.Now())
+
+	filter, update := ID(id), Pull("likes", user)
+	if value {
+		filter = bson.M{"_id": id, "likes": bson.M{"$ne": user}}
+		update = bson.M{"$push": bson.M{"likes": bson.M{"$each": []core.ID{user}, "$sort": 1}}}
+	}
+
+	var likes core.Likes
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(d.Ctx, filter, update, opts).Decode(&likes)
+	if err == mongo.ErrNoDocuments && value { // already liked
+		err = collection.FindOne(d.Ctx, ID(id)).Decode(&likes)
+	}
+	if err != nil {
+		return 0, core.EI(err)
+	}
+
+	return len(likes.Likes), nil
+}
+
+// Ping checks whether database server is reachable
+func (d *DB) Ping(ctx context.Context) error {
+	defer d.observe("Ping", time.Now())
+
+	return core.EI(d.Client.Ping(ctx, readpref.Primary()))
+}
+
+// Close disconnects from database server, after this DB cannot be used
+func (d *DB) Close(ctx context.Context) error {
+	defer d.Cancel()
+
+	return core.EI(d.Client.Disconnect(ctx))
+}
+
 // Drop drops the database, after this DB cannot be used
 func (d *DB) Drop() {
 	d.Database.Drop(d.Ctx)
@@ -217,6 +409,8 @@ func (d *DB) Drop() {
 
 // AccountByID reads account from database, returns false if account wos not found
 func (d *DB) AccountByID(id core.ID) (ac core.Account, err error) {
+	defer d.observe("AccountByID", time.Now())
+
 	err = d.Accounts.FindOne(d.Ctx, bson.M{"_id": id}).Decode(&ac)
 	err = core.EI(err)
 	return
@@ -224,6 +418,8 @@ func (d *DB) AccountByID(id core.ID) (ac core.Account, err error) {
 
 // AccountByEmail finds account based of a email, email of every account has to be unique
 func (d *DB) AccountByEmail(email string) (ac core.Account, err error) {
+	defer d.observe("AccountByEmail", time.Now())
+
 	err = d.Accounts.FindOne(d.Ctx, bson.M{"email": email}).Decode(&ac)
 	err = AssertNotFound(err, "account", "email")
 	return
@@ -231,6 +427,8 @@ func (d *DB) AccountByEmail(email string) (ac core.Account, err error) {
 
 // AccountByName finds account based of a name, name of every account has to be unique
 func (d *DB) AccountByName(name string) (ac core.Account, err error) {
+	defer d.observe("AccountByName", time.Now())
+
 	err = d.Accounts.FindOne(d.Ctx, bson.M{"name": name}).Decode(&ac)
 	err = AssertNotFound(err, "account", "name")
 	return
@@ -238,6 +436,8 @@ func (d *DB) AccountByName(name string) (ac core.Account, err error) {
 
 // AccountIdsForName collects all account ids witch name starts with given string
 func (d *DB) AccountIdsForName(name string) (ids []core.RawID, err error) {
+	defer d.observe("AccountIdsForName", time.Now())
+
 	c, err := d.Accounts.Find(d.Ctx, bson.D{StartsWith("name", name)})
 	if err != nil {
 		return nil, core.EI(err)
@@ -248,6 +448,8 @@ func (d *DB) AccountIdsForName(name string) (ids []core.RawID, err error) {
 
 // LoginAccount returns account with given password and name
 func (d *DB) LoginAccount(name, password string) (ac core.Account, err error) {
+	defer d.observe("LoginAccount", time.Now())
+
 	err = d.Accounts.FindOne(d.Ctx, bson.M{"name": name, "password": password}).Decode(&ac)
 	if err != nil {
 		err = ErrInvalidLogin
@@ -260,14 +462,18 @@ func (d *DB) LoginAccount(name, password string) (ac core.Account, err error) {
 	return
 }
 
-//UpdateNoteList ...
+// UpdateNoteList ...
 func (d *DB) UpdateNoteList(id core.ID, list []core.ID) error {
+	defer d.observe("UpdateNoteList", time.Now())
+
 	_, err := d.Accounts.UpdateOne(d.Ctx, ID(id), Set(bson.M{"Notes": list}))
 	return core.EI(err)
 }
 
 // CanCreateAccount returns whether account can be created
 func (d *DB) CanCreateAccount(ac *core.Account) error {
+	defer d.observe("CanCreateAccount", time.Now())
+
 	_, err := d.AccountByEmail(ac.Email)
 	if err == nil {
 		return ErrEmailTaken
@@ -289,6 +495,7 @@ func (d *DB) Code() string {
 // Account inserts account to database, also generates id, if name is already taken,
 // account is not inserted and false is returned
 func (d *DB) Account(ac *core.Account) (err error) {
+	defer d.observe("Account", time.Now())
 
 	ac.ID, err = d.NID()
 	if err != nil {
@@ -305,6 +512,8 @@ func (d *DB) Account(ac *core.Account) (err error) {
 
 // ChangeAccountCode is used when user enters incorrect code to prevent brute force attacks
 func (d *DB) ChangeAccountCode(id core.ID) (string, error) {
+	defer d.observe("ChangeAccountCode", time.Now())
+
 	code := d.vCodeFactory.value()
 	_, err := d.Accounts.UpdateOne(d.Ctx, ID(id), Set(bson.M{"code": code}))
 	return code, core.EI(err)
@@ -312,12 +521,313 @@ func (d *DB) ChangeAccountCode(id core.ID) (string, error) {
 
 // MakeAccountVerified is used when user enters correct code to clarify that account is now verified
 func (d *DB) MakeAccountVerified(id core.ID) error {
+	defer d.observe("MakeAccountVerified", time.Now())
+
 	_, err := d.Accounts.UpdateOne(d.Ctx, ID(id), Set(bson.M{"code": Verified}))
 	return core.EI(err)
 }
 
+// SetTOTP overwrites two factor state of account
+func (d *DB) SetTOTP(id core.ID, t core.TOTP) error {
+	defer d.observe("SetTOTP", time.Now())
+
+	_, err := d.Accounts.UpdateOne(d.Ctx, ID(id), Set(bson.M{"totp": t}))
+	return core.EI(err)
+}
+
+// UseTOTPStep records step of accepted totp code only if last accepted step is still
+// last, false means other login used a code meanwhile
+func (d *DB) UseTOTPStep(id core.ID, last, step int64) (bool, error) {
+	defer d.observe("UseTOTPStep", time.Now())
+
+	res, err := d.Accounts.UpdateOne(d.Ctx,
+		bson.M{"_id": id, "totp.last": last},
+		Set(bson.M{"totp.last": step}),
+	)
+	if err != nil {
+		return false, core.EI(err)
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// UseRecoveryCode removes hash of recovery code from account, false means account does
+// not have it, possibly because other login used it meanwhile
+func (d *DB) UseRecoveryCode(id core.ID, hash string) (bool, error) {
+	defer d.observe("UseRecoveryCode", time.Now())
+
+	res, err := d.Accounts.UpdateOne(d.Ctx,
+		bson.M{"_id": id, "totp.recovery": hash},
+		Pull("totp.recovery", hash),
+	)
+	if err != nil {
+		return false, core.EI(err)
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// Session inserts session to database, also generates id, database deletes it once it
+// expires
+func (d *DB) Session(s *core.Session) (err error) {
+	defer d.observe("Session", time.Now())
+
+	s.ID, err = d.NID()
+	if err != nil {
+		return
+	}
+	s.BornDate = core.Time()
+	s.ExpireAt = time.UnixMilli(s.Expires)
+	_, err = d.Sessions.InsertOne(d.Ctx, s)
+	return core.EI(err)
+}
+
+// SessionByHash finds session by hash of its token
+func (d *DB) SessionByHash(hash string) (s core.Session, err error) {
+	defer d.observe("SessionByHash", time.Now())
+
+	err = d.Sessions.FindOne(d.Ctx, bson.M{"hash": hash}).Decode(&s)
+	err = AssertNotFound(err, "session", "hash")
+	return
+}
+
+// DropSessions removes all sessions of account
+func (d *DB) DropSessions(account core.ID) error {
+	defer d.observe("DropSessions", time.Now())
+
+	_, err := d.Sessions.DeleteMany(d.Ctx, bson.M{"account": account})
+	return core.EI(err)
+}
+
+// Token inserts api token to database, also generates id
+func (d *DB) Token(t *core.APIToken) (err error) {
+	defer d.observe("Token", time.Now())
+
+	t.ID, err = d.NID()
+	if err != nil {
+		return
+	}
+	t.BornDate = core.Time()
+	_, err = d.Tokens.InsertOne(d.Ctx, t)
+	return core.EI(err)
+}
+
+// TokenByHash finds api token by hash of its value
+func (d *DB) TokenByHash(hash string) (t core.APIToken, err error) {
+	defer d.observe("TokenByHash", time.Now())
+
+	err = d.Tokens.FindOne(d.Ctx, bson.M{"hash": hash}).Decode(&t)
+	err = AssertNotFound(err, "token", "hash")
+	return
+}
+
+// UserTokens returns all api tokens owned by account
+func (d *DB) UserTokens(owner core.ID) (ts []core.APIToken, err error) {
+	defer d.observe("UserTokens", time.Now())
+
+	cur, err := d.Tokens.Find(d.Ctx, bson.M{"owner": owner})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	ts = []core.APIToken{}
+	err = core.EI(cur.All(d.Ctx, &ts))
+	return
+}
+
+// UseToken updates last usage time of token
+func (d *DB) UseToken(id core.ID) error {
+	defer d.observe("UseToken", time.Now())
+
+	_, err := d.Tokens.UpdateOne(d.Ctx, ID(id), Set(bson.M{"lastused": core.Time()}))
+	return core.EI(err)
+}
+
+// RevokeToken deletes token, but only if it belongs to the owner
+func (d *DB) RevokeToken(owner, id core.ID) error {
+	defer d.observe("RevokeToken", time.Now())
+
+	res, err := d.Tokens.DeleteOne(d.Ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return core.EI(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotFound.Args("token", "id")
+	}
+
+	return nil
+}
+
+// Attachment inserts attachment, id is generated by caller
+func (d *DB) Attachment(a *core.Attachment) error {
+	defer d.observe("Attachment", time.Now())
+
+	a.BornDate = core.Time()
+	_, err := d.Attachments.InsertOne(d.Ctx, a)
+	return core.EI(err)
+}
+
+// AttachmentByID ...
+func (d *DB) AttachmentByID(id string) (a core.Attachment, err error) {
+	defer d.observe("AttachmentByID", time.Now())
+
+	err = d.Attachments.FindOne(d.Ctx, bson.M{"_id": id}).Decode(&a)
+	err = AssertNotFound(err, "attachment", "id")
+	return
+}
+
+// UserAttachments returns all attachments owned by account
+func (d *DB) UserAttachments(owner core.ID) ([]core.Attachment, error) {
+	defer d.observe("UserAttachments", time.Now())
+
+	return d.findAttachments(bson.M{"owner": owner})
+}
+
+// AttachmentsBefore returns attachments uploaded before given time (in milliseconds)
+func (d *DB) AttachmentsBefore(before int64) ([]core.Attachment, error) {
+	defer d.observe("AttachmentsBefore", time.Now())
+
+	return d.findAttachments(bson.M{"borndate": bson.M{"$lt": before}})
+}
+
+func (d *DB) findAttachments(filter bson.M) (as []core.Attachment, err error) {
+	cur, err := d.Attachments.Find(d.Ctx, filter)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	as = []core.Attachment{}
+	err = core.EI(cur.All(d.Ctx, &as))
+	return
+}
+
+// AttachmentUsage sums sizes of attachments owned by account
+func (d *DB) AttachmentUsage(owner core.ID) (int, error) {
+	defer d.observe("AttachmentUsage", time.Now())
+
+	cur, err := d.Attachments.Aggregate(d.Ctx, []bson.M{
+		{"$match": bson.M{"owner": owner}},
+		{"$group": bson.M{"_id": nil, "size": bson.M{"$sum": "$size"}}},
+	})
+	if err != nil {
+		return 0, core.EI(err)
+	}
+
+	var res []struct{ Size int }
+	err = cur.All(d.Ctx, &res)
+	if err != nil || len(res) == 0 {
+		return 0, core.EI(err)
+	}
+
+	return res[0].Size, nil
+}
+
+// DeleteAttachment deletes attachment record, but only if it belongs to the owner
+func (d *DB) DeleteAttachment(owner core.ID, id string) error {
+	defer d.observe("DeleteAttachment", time.Now())
+
+	res, err := d.Attachments.DeleteOne(d.Ctx, bson.M{"_id": id, "owner": owner})
+	if err != nil {
+		return core.EI(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotFound.Args("attachment", "id")
+	}
+
+	return nil
+}
+
+// Notebook inserts notebook, also generates id, 0 is never used because it is the root
+func (d *DB) Notebook(nb *core.Notebook) (err error) {
+	defer d.observe("Notebook", time.Now())
+
+	nb.ID, err = d.NID()
+	if err == nil && nb.ID == 0 {
+		nb.ID, err = d.NID()
+	}
+	if err != nil {
+		return
+	}
+	nb.BornDate = core.Time()
+	_, err = d.Notebooks.InsertOne(d.Ctx, nb)
+	return core.EI(err)
+}
+
+// NotebookByID ...
+func (d *DB) NotebookByID(id core.ID) (nb core.Notebook, err error) {
+	defer d.observe("NotebookByID", time.Now())
+
+	err = d.Notebooks.FindOne(d.Ctx, ID(id)).Decode(&nb)
+	err = AssertNotFound(err, "notebook", "id")
+	return
+}
+
+// UserNotebooks returns all notebooks owned by account
+func (d *DB) UserNotebooks(owner core.ID) (nbs []core.Notebook, err error) {
+	defer d.observe("UserNotebooks", time.Now())
+
+	cur, err := d.Notebooks.Find(d.Ctx, bson.M{"owner": owner})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	nbs = []core.Notebook{}
+	err = core.EI(cur.All(d.Ctx, &nbs))
+	return
+}
+
+// UpdateNotebook overwrites notebook with its modified version
+func (d *DB) UpdateNotebook(nb *core.Notebook) error {
+	defer d.observe("UpdateNotebook", time.Now())
+
+	_, err := d.Notebooks.ReplaceOne(d.Ctx, ID(nb.ID), nb)
+	return core.EI(err)
+}
+
+// PublishNotebooks sets publicity of notebooks, notes in them keep their own publicity so
+// drafts are not published with the collection
+func (d *DB) PublishNotebooks(ids []core.ID, value bool) error {
+	defer d.observe("PublishNotebooks", time.Now())
+
+	_, err := d.Notebooks.UpdateMany(d.Ctx, bson.M{"_id": bson.M{"$in": ids}}, Set(bson.M{"published": value}))
+	return core.EI(err)
+}
+
+// MoveNote moves note into notebook, 0 moves it to the root
+func (d *DB) MoveNote(id, notebook core.ID) error {
+	defer d.observe("MoveNote", time.Now())
+
+	_, err := d.Notes.UpdateOne(d.Ctx, ID(id), Set(bson.M{"notebook": notebook}))
+	return core.EI(err)
+}
+
+// DeleteNotebook deletes notebook, notes and notebooks inside are moved to its parent
+// so nothing is lost, id is freed for reuse
+func (d *DB) DeleteNotebook(nb core.Notebook) error {
+	defer d.observe("DeleteNotebook", time.Now())
+
+	_, err := d.Notebooks.UpdateMany(d.Ctx, bson.M{"parent": nb.ID, "owner": nb.Owner}, Set(bson.M{"parent": nb.Parent}))
+	if err != nil {
+		return core.EI(err)
+	}
+
+	_, err = d.Notes.UpdateMany(d.Ctx, bson.M{"notebook": nb.ID, "author": nb.Owner}, Set(bson.M{"notebook": nb.Parent}))
+	if err != nil {
+		return core.EI(err)
+	}
+
+	_, err = d.Notebooks.DeleteOne(d.Ctx, ID(nb.ID))
+	if err != nil {
+		return core.EI(err)
+	}
+
+	return d.DID(nb.ID)
+}
+
 // TakeAction sets last action to current time
 func (d *DB) TakeAction(id core.ID) (func() error, error) {
+	defer d.observe("TakeAction", time.Now())
+
 	ac, err := d.AccountByID(id)
 	if err != nil {
 		return nil, core.EI(err)
@@ -337,6 +847,8 @@ func (d *DB) TakeAction(id core.ID) (func() error, error) {
 
 // DraftByID ...
 func (d *DB) DraftByID(id core.ID) (dr core.Draft, err error) {
+	defer d.observe("DraftByID", time.Now())
+
 	err = d.Notes.FindOne(d.Ctx, ID(id)).Decode(&dr)
 	err = core.EI(err)
 	return
@@ -344,6 +856,8 @@ func (d *DB) DraftByID(id core.ID) (dr core.Draft, err error) {
 
 // UserNotes retrieves all notes that user posses into given collection, coll has to be pointer to slice
 func (d *DB) UserNotes(id core.ID, coll interface{}) error {
+	defer d.observe("UserNotes", time.Now())
+
 	cur, err := d.Notes.Find(d.Ctx, bson.M{"author": id})
 	if err != nil {
 		return core.EI(err)
@@ -354,6 +868,8 @@ func (d *DB) UserNotes(id core.ID, coll interface{}) error {
 
 // NoteByID ...
 func (d *DB) NoteByID(id core.ID) (n core.Note, err error) {
+	defer d.observe("NoteByID", time.Now())
+
 	err = d.Notes.FindOne(d.Ctx, ID(id)).Decode(&n)
 	err = core.EI(err)
 	return
@@ -361,39 +877,103 @@ func (d *DB) NoteByID(id core.ID) (n core.Note, err error) {
 
 // SetPublished ...
 func (d *DB) SetPublished(id core.ID, value bool) error {
+	defer d.observe("SetPublished", time.Now())
+
 	_, err := d.Notes.UpdateOne(d.Ctx, ID(id), Set(bson.M{"published": value}))
-	return core.EI(err)
+	if err != nil || !value {
+		return core.EI(err)
+	}
+
+	return d.stampPublished(ID(id))
 }
 
 // IsAuthor returns ErrNotAuthor if given note has different author
 func (d *DB) IsAuthor(owner, note core.ID) error {
-	_, err := d.Notes.Find(d.Ctx, bson.M{"_id": note, "owner": owner})
-	if err != nil {
+	defer d.observe("IsAuthor", time.Now())
+
+	err := d.Notes.FindOne(d.Ctx, bson.M{"_id": note, "author": owner}).Err()
+	if err == mongo.ErrNoDocuments {
 		return ErrNotAuthor
 	}
-	return nil
+	return core.EI(err)
 }
 
 // Note inserts note to database, also generates id
 func (d *DB) Note(nt *core.Note) (err error) {
+	defer d.observe("Note", time.Now())
+
 	nt.ID, err = d.NID()
 	if err != nil {
 		return
 	}
 	nt.BornDate = core.Time()
+	if nt.Published {
+		nt.PublishDate = nt.BornDate
+	}
 	_, err = d.Notes.InsertOne(d.Ctx, nt)
 	return core.EI(err)
 }
 
-// UpdateNote overwrites note with its modified version, target is determinate by id
+// UpdateNote overwrites note with its modified version except likes and views, target is
+// determinate by id
 func (d *DB) UpdateNote(nt *core.Note) error {
-	_, err := d.Notes.ReplaceOne(d.Ctx, ID(nt.ID), nt)
+	defer d.observe("UpdateNote", time.Now())
+
+	if nt.Published && nt.PublishDate == 0 {
+		nt.PublishDate = core.Time()
+	}
+	doc, err := bson.Marshal(nt)
+	if err != nil {
+		return core.EI(err)
+	}
+	var fields bson.M
+	err = bson.Unmarshal(doc, &fields)
+	if err != nil {
+		return core.EI(err)
+	}
+
+	// likes and views are changed by readers meanwhile, replacing the document would lose them
+	delete(fields, "_id")
+	delete(fields, "views")
+
+	_, err = d.Notes.UpdateOne(d.Ctx, ID(nt.ID), Set(fields))
 	return core.EI(err)
 }
 
+// DeleteNote removes note and its comments and marks its bookmarks deleted, id is freed
+// for reuse
+func (d *DB) DeleteNote(id core.ID) error {
+	defer d.observe("DeleteNote", time.Now())
+
+	_, err := d.Notes.DeleteOne(d.Ctx, ID(id))
+	if err != nil {
+		return core.EI(err)
+	}
+
+	_, err = d.Comments.DeleteMany(d.Ctx, bson.M{"note": id})
+	if err != nil {
+		return core.EI(err)
+	}
+
+	// bookmarks stay so owners see what disappeared, id can be reused by other note
+	_, err = d.Bookmarks.UpdateMany(d.Ctx, bson.M{"note": id, "deleted": false}, Set(bson.M{"deleted": true}))
+	if err != nil {
+		return core.EI(err)
+	}
+
+	return d.DID(id)
+}
+
 // SearchNote returns fitting search results for given parameters
 func (d *DB) SearchNote(values core.SearchRequest, published bool) ([]core.NotePreview, error) {
-	res, err := d.Notes.Find(d.Ctx, d.NoteFilter(values, published))
+	defer d.observe("SearchNote", time.Now())
+
+	filter, err := d.NoteFilter(values, published)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.Notes.Find(d.Ctx, filter)
 	if err != nil {
 		return nil, core.EI(err)
 	}
@@ -402,18 +982,58 @@ func (d *DB) SearchNote(values core.SearchRequest, published bool) ([]core.NoteP
 	for i := 0; res.TryNext(d.Ctx) && i <= MaxCursorSize; i++ {
 		notes = notes[:i+1]
 		res.Decode(&notes[i])
-		if len(notes[i].Content) > MaxPreviewSize {
-			notes[i].Content = notes[i].Content[:MaxPreviewSize]
-		}
+		notes[i].Content = Preview(notes[i].Content)
 	}
 
-	fmt.Println(notes)
+	d.Log.Debug("note search", "results", len(notes))
 
 	return notes, nil
 }
 
+// Preview cuts content to MaxPreviewSize bytes, cut is moved back to start of rune so
+// letter is not split
+func Preview(content string) string {
+	if len(content) <= MaxPreviewSize {
+		return content
+	}
+
+	cut := MaxPreviewSize
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut]
+}
+
+// TagCounts counts published notes of tags starting with prefix, most used tags are first
+func (d *DB) TagCounts(prefix string, limit int) ([]core.TagCount, error) {
+	defer d.observe("TagCounts", time.Now())
+
+	match := bson.M{"published": true}
+	if prefix != "" {
+		match["tags"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}
+	}
+
+	cur, err := d.Notes.Aggregate(d.Ctx, []bson.M{
+		{"$match": match},
+		{"$unwind": "$tags"},
+		{"$match": match},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": limit},
+	})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	tags := []core.TagCount{}
+	err = core.EI(cur.All(d.Ctx, &tags))
+	return tags, err
+}
+
 // CommentByID ...
 func (d *DB) CommentByID(id core.ID) (n core.Comment, err error) {
+	defer d.observe("CommentByID", time.Now())
+
 	err = d.Comments.FindOne(d.Ctx, ID(id)).Decode(&n)
 	err = core.EI(err)
 	return
@@ -421,6 +1041,8 @@ func (d *DB) CommentByID(id core.ID) (n core.Comment, err error) {
 
 // Comment adds new comment to db
 func (d *DB) Comment(cm *core.Comment) (err error) {
+	defer d.observe("Comment", time.Now())
+
 	cm.ID, err = d.NID()
 	if err != nil {
 		return core.EI(err)
@@ -430,6 +1052,19 @@ func (d *DB) Comment(cm *core.Comment) (err error) {
 	return core.EI(err)
 }
 
+// Audit stores audit record
+func (d *DB) Audit(a *core.Audit) (err error) {
+	defer d.observe("Audit", time.Now())
+
+	a.ID, err = d.NID()
+	if err != nil {
+		return
+	}
+	a.BornDate = core.Time()
+	_, err = d.Audits.InsertOne(d.Ctx, a)
+	return core.EI(err)
+}
+
 type vCodeFactory struct {
 	r rand.Rand
 	m sync.Mutex
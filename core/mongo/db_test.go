@@ -1,11 +1,19 @@
 package mongo
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"myNotes/core"
+	"myNotes/core/taxonomy"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func TestNID(t *testing.T) {
@@ -89,9 +97,9 @@ func TestSearch(t *testing.T) {
 	}
 
 	nts := []core.Note{
-		{Name: "aa", Author: 1, Year: 2, Month: 3, Theme: "a", Subject: "f", School: 0},
-		{Name: "aab", Author: 1, Year: 1, Month: 5, Theme: "a", Subject: "g", School: 0},
-		{Name: "bb", Author: 2, Year: 5, Month: 6, Theme: "fa", Subject: "g", School: 0},
+		{Name: "aa", Author: 1, Year: 2, Month: 3, Theme: "a", Subject: "f", School: 0, Tags: []string{"x", "y"}},
+		{Name: "aab", Author: 1, Year: 1, Month: 5, Theme: "a", Subject: "g", School: 0, Tags: []string{"x"}},
+		{Name: "bb", Author: 2, Year: 5, Month: 6, Theme: "fa", Subject: "g", School: 0, Tags: []string{"z"}},
 		{Name: "bc", Author: 0, Year: 2, Month: 6, Theme: "ca", Subject: "fa", School: 0},
 	}
 
@@ -145,6 +153,49 @@ func TestSearch(t *testing.T) {
 				{Name: "aab", Author: 1, ID: 1},
 			},
 		},
+
+		{
+			desc:  "exact subject",
+			query: core.SearchRequest{Subject: "!f"},
+			results: []core.NotePreview{
+				{Name: "aa", Author: 1, ID: 0},
+			},
+		},
+
+		{
+			desc:  "subject",
+			query: core.SearchRequest{Subject: "f"},
+			results: []core.NotePreview{
+				{Name: "aa", Author: 1, ID: 0},
+				{Name: "bc", Author: 0, ID: 3},
+			},
+		},
+
+		{
+			desc:  "exact theme",
+			query: core.SearchRequest{Theme: "!a"},
+			results: []core.NotePreview{
+				{Name: "aa", Author: 1, ID: 0},
+				{Name: "aab", Author: 1, ID: 1},
+			},
+		},
+
+		{
+			desc:  "all tags",
+			query: core.SearchRequest{Tags: "x,Y"},
+			results: []core.NotePreview{
+				{Name: "aa", Author: 1, ID: 0},
+			},
+		},
+
+		{
+			desc:  "any tag",
+			query: core.SearchRequest{Tags: "y|z"},
+			results: []core.NotePreview{
+				{Name: "aa", Author: 1, ID: 0},
+				{Name: "bb", Author: 2, ID: 2},
+			},
+		},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
@@ -172,6 +223,387 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestPreview(t *testing.T) {
+	long := strings.Repeat("a", MaxPreviewSize)
+	testCases := []struct {
+		desc, content, expected string
+	}{
+		{desc: "short", content: "note", expected: "note"},
+		{desc: "exact", content: long, expected: long},
+		{desc: "ascii", content: long + "b", expected: long},
+		{desc: "split letter", content: long[1:] + "čb", expected: long[1:]},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if res := Preview(tC.content); res != tC.expected {
+				t.Error(len(res), res)
+			}
+		})
+	}
+}
+
+func TestSecondFactorUse(t *testing.T) {
+	db := Setup()
+
+	ac := core.Account{Name: "twofactor"}
+	if err := db.Account(&ac); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetTOTP(ac.ID, core.TOTP{Enabled: true, Last: 5, Recovery: []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		use      func() (bool, error)
+		expected bool
+	}{
+		{"step", func() (bool, error) { return db.UseTOTPStep(ac.ID, 5, 6) }, true},
+		{"stale step", func() (bool, error) { return db.UseTOTPStep(ac.ID, 5, 7) }, false},
+		{"recovery", func() (bool, error) { return db.UseRecoveryCode(ac.ID, "a") }, true},
+		{"used recovery", func() (bool, error) { return db.UseRecoveryCode(ac.ID, "a") }, false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			used, err := tC.use()
+			if err != nil || used != tC.expected {
+				t.Error(used, err)
+			}
+		})
+	}
+
+	ac, _ = db.AccountByID(ac.ID)
+	if ac.TOTP.Last != 6 || len(ac.TOTP.Recovery) != 1 {
+		t.Error(ac.TOTP)
+	}
+}
+
+func TestNotebooks(t *testing.T) {
+	db := Setup()
+
+	parent := core.Notebook{Owner: 1, Name: "math"}
+	if err := db.Notebook(&parent); err != nil || parent.ID == 0 {
+		t.Fatal(parent.ID, err)
+	}
+	child := core.Notebook{Owner: 1, Parent: parent.ID, Name: "algebra"}
+	if err := db.Notebook(&child); err != nil {
+		t.Fatal(err)
+	}
+	nt := core.Note{Author: 1}
+	if err := db.Note(&nt); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MoveNote(nt.ID, child.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PublishNotebooks([]core.ID{parent.ID, child.ID}, true); err != nil {
+		t.Fatal(err)
+	}
+	if nt, _ := db.NoteByID(nt.ID); nt.Published || nt.Notebook != child.ID {
+		t.Error(nt)
+	}
+
+	if err := db.DeleteNotebook(child); err != nil {
+		t.Fatal(err)
+	}
+	if nt, _ := db.NoteByID(nt.ID); nt.Notebook != parent.ID {
+		t.Error(nt)
+	}
+	if _, err := db.NotebookByID(child.ID); !errors.Is(err, ErrNotFound) {
+		t.Error(err)
+	}
+
+	nbs, err := db.UserNotebooks(1)
+	if err != nil || len(nbs) != 1 || !nbs[0].Published {
+		t.Error(nbs, err)
+	}
+}
+
+func TestTaxonomy(t *testing.T) {
+	db := Setup()
+
+	tx, err := db.Taxonomy()
+	if err != nil || len(tx.Terms(taxonomy.SchoolK)) != 3 {
+		t.Fatal(tx, err)
+	}
+
+	_, err = db.PutTerm(taxonomy.Term{Kind: taxonomy.SubjectK, Key: "math", Aliases: []string{"maths", "mathematics"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nts := []core.Note{
+		{Name: "a", Subject: "Maths", School: 2},
+		{Name: "b", Subject: "Alchemy", School: 2},
+	}
+	for i := range nts {
+		db.Note(&nts[i])
+	}
+
+	r, err := db.MigrateTaxonomy(true)
+	if err != nil || r.Notes != 2 || r.Changed != 1 || len(r.Unresolved) != 1 {
+		t.Error(r, err)
+	}
+	if nt, _ := db.NoteByID(nts[0].ID); nt.Subject != "Maths" {
+		t.Error("dry run changed note", nt)
+	}
+
+	r, err = db.MigrateTaxonomy(false)
+	if err != nil || r.Changed != 1 {
+		t.Error(r, err)
+	}
+
+	res, err := db.SearchNote(core.SearchRequest{Subject: "mathematics", School: "secondary"}, false)
+	if err != nil || len(res) != 1 || res[0].Name != "a" {
+		t.Error(res, err)
+	}
+
+	if err := db.DeleteTerm(taxonomy.SubjectK, "math"); !errors.Is(err, taxonomy.ErrInUse) {
+		t.Error(err)
+	}
+	if err := db.DeleteTerm(taxonomy.SchoolK, "high"); !errors.Is(err, taxonomy.ErrInUse) {
+		t.Error(err)
+	}
+	if err := db.DeleteTerm(taxonomy.SchoolK, "university"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := tx.School(3); !ok {
+		t.Error("cached taxonomy was modified")
+	}
+	if tx, _ := db.Taxonomy(); len(tx.Terms(taxonomy.SchoolK)) != 2 {
+		t.Error(tx.Terms(taxonomy.SchoolK))
+	}
+}
+
+func TestFeed(t *testing.T) {
+	db := Setup()
+
+	nts := []core.Note{
+		{Name: "a", Author: 1, Subject: "math", Published: true},
+		{Name: "b", Author: 2, Subject: "physics", Published: true},
+		{Name: "c", Author: 2, Subject: "math", Published: true},
+		{Name: "d", Author: 1, Subject: "math"},
+		{Name: "e", Author: 3, Subject: "art", Published: true},
+	}
+	for i := range nts {
+		if err := db.Note(&nts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// published later so it is the newest
+	if err := db.SetPublished(nts[3].ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	follows := []core.Follow{
+		{Follower: 5, Kind: core.FollowAccount, Account: 1},
+		{Follower: 5, Kind: core.FollowSubject, Subject: "math"},
+	}
+	for _, f := range append(follows, follows[0]) {
+		if err := db.Follow(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if followers, following, err := db.FollowCounts(1); followers != 1 || following != 0 || err != nil {
+		t.Error(followers, following, err)
+	}
+	if followers, following, err := db.FollowCounts(5); followers != 0 || following != 2 || err != nil {
+		t.Error(followers, following, err)
+	}
+
+	stored, err := db.UserFollows(5)
+	if err != nil || len(stored) != 2 {
+		t.Fatal(stored, err)
+	}
+
+	var names []string
+	var cursor core.Cursor
+	for {
+		page, err := db.Feed(stored, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range page {
+			names = append(names, n.Name)
+		}
+		if len(page) < 2 {
+			break
+		}
+		last := page[len(page)-1]
+		cursor = core.Cursor{Date: last.PublishDate, ID: last.ID}
+	}
+	if strings.Join(names, "") != "dca" {
+		t.Error(names)
+	}
+
+	if err := db.Unfollow(follows[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, following, _ := db.FollowCounts(5); following != 1 {
+		t.Error(following)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	db := Setup()
+
+	nts := []core.Note{
+		{Name: "a", Author: 2, Published: true},
+		{Name: "b", Author: 2, Published: true},
+		{Name: "c", Author: 2, Published: true},
+	}
+	for i := range nts {
+		db.Note(&nts[i])
+		if err := db.Bookmark(core.Bookmark{Owner: 1, Note: nts[i].ID, Name: nts[i].Name, Collection: "exam"}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	// bookmarking again only moves it
+	if err := db.Bookmark(core.Bookmark{Owner: 1, Note: nts[0].ID, Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := db.BookmarkCount(1); count != 3 || err != nil {
+		t.Error(count, err)
+	}
+
+	cols, err := db.BookmarkCollections(1)
+	if err != nil || len(cols) != 1 || cols[0].Collection != "exam" || cols[0].Count != 2 {
+		t.Error(cols, err)
+	}
+
+	if err := db.DeleteNote(nts[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPublished(nts[2].ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := db.UserBookmarks(1, "", core.Cursor{}, 2)
+	if err != nil || len(page) != 2 || page[0].Note != nts[2].ID || !page[1].Deleted {
+		t.Fatal(page, err)
+	}
+	rest, err := db.UserBookmarks(1, "", core.Cursor{Date: page[1].BornDate, ID: page[1].Note}, 2)
+	if err != nil || len(rest) != 1 || rest[0].Note != nts[0].ID {
+		t.Error(rest, err)
+	}
+
+	previews, err := db.NotePreviews([]core.ID{nts[0].ID, nts[1].ID, nts[2].ID})
+	if err != nil || len(previews) != 2 || previews[nts[2].ID].Published {
+		t.Error(previews, err)
+	}
+
+	if err := db.Unbookmark(1, nts[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := db.BookmarkCount(1); count != 2 {
+		t.Error(count)
+	}
+}
+
+func TestIsAuthor(t *testing.T) {
+	db := Setup()
+
+	nt := core.Note{Name: "own", Author: 1}
+	db.Note(&nt)
+
+	testCases := []struct {
+		desc        string
+		owner, note core.ID
+		err         error
+	}{
+		{desc: "author", owner: 1, note: nt.ID},
+		{desc: "other account", owner: 2, note: nt.ID, err: ErrNotAuthor},
+		{desc: "missing note", owner: 1, note: nt.ID + 100, err: ErrNotAuthor},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if err := db.IsAuthor(tC.owner, tC.note); !errors.Is(err, tC.err) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSetLike(t *testing.T) {
+	db := Setup()
+
+	nt := core.Note{Name: "liked", Author: 1}
+	db.Note(&nt)
+
+	testCases := []struct {
+		desc  string
+		user  core.ID
+		value bool
+		count int
+		liked bool
+	}{
+		{desc: "like", user: 5, value: true, count: 1, liked: true},
+		{desc: "like again", user: 5, value: true, count: 1, liked: true},
+		{desc: "other user", user: 3, value: true, count: 2, liked: true},
+		{desc: "unlike", user: 5, value: false, count: 1},
+		{desc: "unlike again", user: 5, value: false, count: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			count, err := db.SetLike(nt.ID, tC.user, db.Notes, tC.value)
+			if err != nil || count != tC.count {
+				t.Fatal(count, err)
+			}
+			// state is read by binary search so likes have to stay sorted
+			if liked, _, _ := db.Like(nt.ID, tC.user, db.Notes, false); liked != tC.liked {
+				t.Error(liked)
+			}
+		})
+	}
+
+	if _, err := db.SetLike(nt.ID+100, 1, db.Notes, true); err == nil {
+		t.Error("missing note is liked")
+	}
+}
+
+func TestNoteStats(t *testing.T) {
+	db := Setup()
+
+	nt := core.Note{Name: "a", Author: 2, Published: true}
+	db.Note(&nt)
+	hidden := core.Note{Name: "b", Author: 2}
+	db.Note(&hidden)
+
+	if _, _, err := db.Like(nt.ID, 3, db.Notes, true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.AddView(nt.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Comment(&core.Comment{Author: 3, Note: nt.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// editing note keeps its likes and views
+	nt.Content = "edited"
+	if err := db.UpdateNote(&nt); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.NoteStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		if s.ID == hidden.ID {
+			t.Error("unpublished note is ranked")
+		}
+		if s.ID == nt.ID && (s.Likes != 1 || s.Views != 2 || s.Comments != 2 || s.Name != "a") {
+			t.Error(s)
+		}
+	}
+}
+
 func Setup() *DB {
 	db, err := NDB("default", "test")
 	if err != nil {
@@ -185,3 +617,36 @@ func Setup() *DB {
 
 	return db
 }
+
+// nothing listens on this port so every operation fails fast
+const unreachable = "mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"
+
+func TestNDBFailure(t *testing.T) {
+	db, err := NDB(unreachable, "test")
+	if db != nil || !errors.Is(err, ErrIndex) {
+		t.Error(db, err)
+	}
+}
+
+func TestNoteFilterFailure(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(unreachable))
+	if err != nil {
+		panic(err)
+	}
+
+	db := DB{Client: client, Log: slog.Default()}
+	db.Ctx, db.Cancel = context.WithCancel(context.Background())
+	defer db.Cancel()
+
+	err = client.Connect(db.Ctx)
+	if err != nil {
+		panic(err)
+	}
+	db.Database = client.Database("test")
+	db.Accounts = db.Collection(Accounts)
+
+	_, err = db.NoteFilter(core.SearchRequest{Author: "name"}, true)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,148 @@
+package mongo
+
+import (
+	"myNotes/core"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// unpublished matches notes that were never published, notes from before PublishDate
+// existed have no such field
+var unpublished = bson.M{"$in": bson.A{nil, 0}}
+
+// stampPublished sets PublishDate of published notes matching filter that do not have it
+func (d *DB) stampPublished(filter bson.M) error {
+	filter["published"] = true
+	filter["publishdate"] = unpublished
+
+	_, err := d.Notes.UpdateMany(d.Ctx, filter, Set(bson.M{"publishdate": core.Time()}))
+	return core.EI(err)
+}
+
+// backfillPublishDates gives published notes from before PublishDate existed their
+// creation time, it does nothing once all notes have it
+func (d *DB) backfillPublishDates() error {
+	_, err := d.Notes.UpdateMany(d.Ctx,
+		bson.M{"published": true, "publishdate": unpublished},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"publishdate": "$borndate"}}}},
+	)
+	return core.EI(err)
+}
+
+// followFilter matches the follow regardless of its date
+func followFilter(f core.Follow) bson.M {
+	filter := bson.M{"follower": f.Follower, "kind": f.Kind}
+	switch f.Kind {
+	case core.FollowAccount:
+		filter["account"] = f.Account
+	case core.FollowSubject:
+		filter["subject"] = f.Subject
+	}
+	return filter
+}
+
+// Follow stores follow, following same source again changes nothing
+func (d *DB) Follow(f core.Follow) error {
+	defer d.observe("Follow", time.Now())
+
+	f.BornDate = core.Time()
+	_, err := d.Follows.UpdateOne(d.Ctx, followFilter(f), bson.M{"$setOnInsert": f}, options.Update().SetUpsert(true))
+	return core.EI(err)
+}
+
+// Unfollow deletes follow, it is not an error if there is none
+func (d *DB) Unfollow(f core.Follow) error {
+	defer d.observe("Unfollow", time.Now())
+
+	_, err := d.Follows.DeleteOne(d.Ctx, followFilter(f))
+	return core.EI(err)
+}
+
+// UserFollows returns everything account follows, the newest first
+func (d *DB) UserFollows(follower core.ID) ([]core.Follow, error) {
+	defer d.observe("UserFollows", time.Now())
+
+	cur, err := d.Follows.Find(d.Ctx, bson.M{"follower": follower}, options.Find().SetSort(bson.M{"borndate": -1}))
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	follows := []core.Follow{}
+	err = core.EI(cur.All(d.Ctx, &follows))
+	return follows, err
+}
+
+// FollowCounts returns amount of accounts following account and amount of accounts
+// and subjects account follows
+func (d *DB) FollowCounts(id core.ID) (followers, following int64, err error) {
+	defer d.observe("FollowCounts", time.Now())
+
+	followers, err = d.Follows.CountDocuments(d.Ctx, bson.M{"kind": core.FollowAccount, "account": id})
+	if err != nil {
+		return 0, 0, core.EI(err)
+	}
+
+	following, err = d.Follows.CountDocuments(d.Ctx, bson.M{"follower": id})
+	if err != nil {
+		return 0, 0, core.EI(err)
+	}
+
+	return
+}
+
+// Feed returns up to limit published notes from followed accounts and subjects that come
+// after cursor, the newest first
+func (d *DB) Feed(follows []core.Follow, cursor core.Cursor, limit int) ([]core.NotePreview, error) {
+	defer d.observe("Feed", time.Now())
+
+	var authors []core.ID
+	var subjects []string
+	for _, f := range follows {
+		switch f.Kind {
+		case core.FollowAccount:
+			authors = append(authors, f.Account)
+		case core.FollowSubject:
+			subjects = append(subjects, f.Subject)
+		}
+	}
+
+	notes := []core.NotePreview{}
+	if len(authors) == 0 && len(subjects) == 0 {
+		return notes, nil
+	}
+
+	sources := bson.A{}
+	if len(authors) != 0 {
+		sources = append(sources, bson.M{"author": bson.M{"$in": authors}})
+	}
+	if len(subjects) != 0 {
+		sources = append(sources, bson.M{"subject": bson.M{"$in": subjects}})
+	}
+
+	filter := bson.M{"published": true, "$or": sources}
+	if cursor != (core.Cursor{}) {
+		filter["$and"] = bson.A{After(cursor, "publishdate", "_id")}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "publishdate", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cur, err := d.Notes.Find(d.Ctx, filter, opts)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	err = cur.All(d.Ctx, &notes)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	for i := range notes {
+		notes[i].Content = Preview(notes[i].Content)
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,68 @@
+package mongo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"myNotes/core"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Files is GridFS bucket of attachment content, it implements attach.Storage so files are
+// kept with the rest of data when local disk of server is not persistent
+type Files struct {
+	bucket *gridfs.Bucket
+	db     *DB
+}
+
+// Files opens GridFS bucket of attachments
+func (d *DB) Files() (*Files, error) {
+	b, err := gridfs.NewBucket(d.Database, options.GridFSBucket().SetName(Attachments))
+	if err != nil {
+		return nil, core.EI(err)
+	}
+	return &Files{bucket: b, db: d}, nil
+}
+
+// Put stores data under key, key is also used as file name
+func (f *Files) Put(key string, data []byte) error {
+	defer f.db.observe("FilesPut", time.Now())
+
+	return core.EI(f.bucket.UploadFromStreamWithID(key, key, bytes.NewReader(data)))
+}
+
+// Open reads whole file because GridFS streams can not seek, attachments are small enough
+func (f *Files) Open(key string) (io.ReadSeekCloser, error) {
+	defer f.db.observe("FilesOpen", time.Now())
+
+	var buf bytes.Buffer
+	_, err := f.bucket.DownloadToStream(key, &buf)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound.Args("file", "key")
+	}
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	return readSeekCloser{bytes.NewReader(buf.Bytes())}, nil
+}
+
+// Delete removes file with its chunks
+func (f *Files) Delete(key string) error {
+	defer f.db.observe("FilesDelete", time.Now())
+
+	err := f.bucket.DeleteContext(f.db.Ctx, key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil
+	}
+	return core.EI(err)
+}
+
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error { return nil }
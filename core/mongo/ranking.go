@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"myNotes/core"
+	"myNotes/core/ranking"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AddView counts one view of note
+func (d *DB) AddView(id core.ID) error {
+	defer d.observe("AddView", time.Now())
+
+	_, err := d.Notes.UpdateOne(d.Ctx, ID(id), bson.M{"$inc": bson.M{"views": 1}})
+	return core.EI(err)
+}
+
+// NoteStats returns interactions with all published notes, likes are counted from what
+// Like maintains and comments include replies
+func (d *DB) NoteStats() ([]ranking.Stats, error) {
+	defer d.observe("NoteStats", time.Now())
+
+	cur, err := d.Notes.Aggregate(d.Ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"published": true}}},
+		{{Key: "$project", Value: bson.M{
+			"author":      1,
+			"school":      1,
+			"subject":     1,
+			"name":        1,
+			"publishdate": 1,
+			"views":       1,
+			"likes":       bson.M{"$size": bson.M{"$ifNull": bson.A{"$likes", bson.A{}}}},
+		}}},
+	})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	stats := []ranking.Stats{}
+	err = cur.All(d.Ctx, &stats)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	cur, err = d.Comments.Aggregate(d.Ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$note", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	var counts []struct {
+		Note  core.ID `bson:"_id"`
+		Count int
+	}
+	err = cur.All(d.Ctx, &counts)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	comments := make(map[core.ID]int, len(counts))
+	for _, c := range counts {
+		comments[c.Note] = c.Count
+	}
+	for i := range stats {
+		stats[i].Comments = comments[stats[i].ID]
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,213 @@
+package mongo
+
+import (
+	"myNotes/core"
+	"myNotes/core/taxonomy"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TaxonomyTTL is how long taxonomy is cached, changes are visible immediately to instance
+// that made them and other instances see them after this time
+const TaxonomyTTL = time.Minute
+
+// taxonomyCache holds last loaded taxonomy
+type taxonomyCache struct {
+	m      sync.Mutex
+	value  *taxonomy.Taxonomy
+	loaded time.Time
+}
+
+// seedTaxonomy stores default terms when there are none, so notes made before taxonomy
+// existed keep their schools
+func (d *DB) seedTaxonomy() error {
+	count, err := d.Terms.CountDocuments(d.Ctx, All)
+	if err != nil || count != 0 {
+		return core.EI(err)
+	}
+
+	terms := []interface{}{}
+	for _, t := range taxonomy.Defaults() {
+		terms = append(terms, t)
+	}
+	_, err = d.Terms.InsertMany(d.Ctx, terms)
+	return core.EI(err)
+}
+
+// Taxonomy returns cached taxonomy, it is loaded again after TaxonomyTTL
+func (d *DB) Taxonomy() (*taxonomy.Taxonomy, error) {
+	d.taxonomy.m.Lock()
+	defer d.taxonomy.m.Unlock()
+
+	if d.taxonomy.value != nil && time.Since(d.taxonomy.loaded) < TaxonomyTTL {
+		return d.taxonomy.value, nil
+	}
+
+	defer d.observe("Taxonomy", time.Now())
+
+	cur, err := d.Terms.Find(d.Ctx, All)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	terms := []taxonomy.Term{}
+	err = cur.All(d.Ctx, &terms)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	// stored terms were validated when they were put so this fails only if someone
+	// edited the collection by hand
+	tx, err := taxonomy.New(terms)
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	d.taxonomy.value, d.taxonomy.loaded = tx, time.Now()
+	return tx, nil
+}
+
+// PutTerm adds or replaces term, term is validated together with the rest of taxonomy,
+// changing code of school that notes use is refused
+func (d *DB) PutTerm(term taxonomy.Term) (*taxonomy.Taxonomy, error) {
+	defer d.observe("PutTerm", time.Now())
+
+	tx, err := d.Taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := tx.Set(term)
+	if err != nil {
+		return nil, err
+	}
+
+	if old, ok := tx.Lookup(term.Kind, term.Key); ok && old.Key == term.Key && old.Code != term.Code {
+		err = d.termUnused(old)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = d.Terms.ReplaceOne(d.Ctx, bson.M{"kind": term.Kind, "key": term.Key}, term, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, core.EI(err)
+	}
+
+	d.cacheTaxonomy(changed)
+
+	return changed, nil
+}
+
+// DeleteTerm deletes term, terms and notes that use it have to be changed first
+func (d *DB) DeleteTerm(kind taxonomy.Kind, key string) error {
+	defer d.observe("DeleteTerm", time.Now())
+
+	tx, err := d.Taxonomy()
+	if err != nil {
+		return err
+	}
+
+	changed, err := tx.Remove(kind, key)
+	if err != nil {
+		return err
+	}
+
+	term, _ := tx.Lookup(kind, key)
+	err = d.termUnused(term)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Terms.DeleteOne(d.Ctx, bson.M{"kind": kind, "key": key})
+	if err != nil {
+		return core.EI(err)
+	}
+
+	d.cacheTaxonomy(changed)
+
+	return nil
+}
+
+// MigrateTaxonomy maps free text subjects and themes of notes onto keys of terms, with
+// dryRun nothing is changed and report only tells what would happen
+func (d *DB) MigrateTaxonomy(dryRun bool) (taxonomy.Report, error) {
+	defer d.observe("MigrateTaxonomy", time.Now())
+
+	var r taxonomy.Report
+
+	tx, err := d.Taxonomy()
+	if err != nil {
+		return r, err
+	}
+
+	cur, err := d.Notes.Find(d.Ctx, All, options.Find().SetProjection(bson.M{"subject": 1, "theme": 1}))
+	if err != nil {
+		return r, core.EI(err)
+	}
+	defer cur.Close(d.Ctx)
+
+	for cur.Next(d.Ctx) {
+		var nt struct {
+			ID             core.ID `bson:"_id"`
+			Subject, Theme string
+		}
+		err = cur.Decode(&nt)
+		if err != nil {
+			return r, core.EI(err)
+		}
+
+		subject, theme := tx.Migrate(&r, nt.Subject, nt.Theme)
+		if subject == nt.Subject && theme == nt.Theme {
+			continue
+		}
+
+		r.Changed++
+		if dryRun {
+			continue
+		}
+
+		_, err = d.Notes.UpdateOne(d.Ctx, ID(nt.ID), Set(bson.M{"subject": subject, "theme": theme}))
+		if err != nil {
+			return r, core.EI(err)
+		}
+	}
+	if err = cur.Err(); err != nil {
+		return r, core.EI(err)
+	}
+
+	r.Sort()
+
+	return r, nil
+}
+
+// termUnused returns taxonomy.ErrInUse if some note refers to the term
+func (d *DB) termUnused(term taxonomy.Term) error {
+	var filter bson.M
+	switch term.Kind {
+	case taxonomy.SchoolK:
+		filter = bson.M{"school": term.Code}
+	default:
+		filter = bson.M{string(term.Kind): term.Key}
+	}
+
+	count, err := d.Notes.CountDocuments(d.Ctx, filter)
+	if err != nil {
+		return core.EI(err)
+	}
+	if count != 0 {
+		return taxonomy.ErrInUse.Args(term.Kind, term.Key, strconv.FormatInt(count, 10), "notes")
+	}
+
+	return nil
+}
+
+func (d *DB) cacheTaxonomy(tx *taxonomy.Taxonomy) {
+	d.taxonomy.m.Lock()
+	d.taxonomy.value, d.taxonomy.loaded = tx, time.Now()
+	d.taxonomy.m.Unlock()
+}
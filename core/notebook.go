@@ -0,0 +1,183 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// notebook limits
+const (
+	MaxNotebookDepth = 16
+	MaxNotebooks     = 1000
+)
+
+// notebook errors
+var (
+	ErrNotebookCycle = sterr.New("notebook cannot be moved into itself or its descendant")
+	ErrNotebookDepth = sterr.New("notebooks can be nested at most %d levels deep")
+	ErrNotebookLimit = sterr.New("account can have at most %d notebooks")
+)
+
+// Notebook is folder of notes, notebooks of account form a tree, Parent 0 is the root
+type Notebook struct {
+	ID            ID `bson:"_id"`
+	Owner, Parent ID
+
+	Name string
+
+	Published bool
+
+	BornDate int64
+}
+
+// AID implements IDer
+func (n *Notebook) AID() ID { return n.ID }
+
+// NotebookEntry is notebook listed in its parent with counts of its direct children
+type NotebookEntry struct {
+	Notebook
+	Notebooks, Notes int
+}
+
+// Shelf is tree of notebooks and notes of one account, notes in notebooks that do
+// not exist belong to the root
+type Shelf struct {
+	notebooks map[ID]Notebook
+	children  map[ID][]ID
+	notes     map[ID][]Draft
+}
+
+// NShelf builds tree from all notebooks and notes of account
+func NShelf(notebooks []Notebook, notes []Draft) *Shelf {
+	s := &Shelf{
+		notebooks: map[ID]Notebook{},
+		children:  map[ID][]ID{},
+		notes:     map[ID][]Draft{},
+	}
+
+	notebooks = append([]Notebook(nil), notebooks...)
+	sort.Slice(notebooks, func(i, j int) bool { return notebooks[i].Name < notebooks[j].Name })
+	for _, nb := range notebooks {
+		s.notebooks[nb.ID] = nb
+	}
+	for _, nb := range notebooks {
+		parent := nb.Parent
+		if _, ok := s.notebooks[parent]; !ok {
+			parent = 0
+		}
+		s.children[parent] = append(s.children[parent], nb.ID)
+	}
+
+	for _, nt := range notes {
+		parent := nt.Notebook
+		if _, ok := s.notebooks[parent]; !ok {
+			parent = 0
+		}
+		s.notes[parent] = append(s.notes[parent], nt)
+	}
+
+	return s
+}
+
+// Len returns amount of notebooks on shelf
+func (s *Shelf) Len() int {
+	return len(s.notebooks)
+}
+
+// Notebook returns notebook of shelf by id
+func (s *Shelf) Notebook(id ID) (Notebook, bool) {
+	nb, ok := s.notebooks[id]
+	return nb, ok
+}
+
+// Contents lists direct children of notebook, 0 lists the root, when published is true
+// only published notebooks and notes are listed and counted
+func (s *Shelf) Contents(id ID, published bool) (notebooks []NotebookEntry, notes []Draft) {
+	notebooks, notes = []NotebookEntry{}, []Draft{}
+	for _, child := range s.children[id] {
+		nb := s.notebooks[child]
+		if published && !nb.Published {
+			continue
+		}
+
+		e := NotebookEntry{Notebook: nb}
+		for _, c := range s.children[child] {
+			if !published || s.notebooks[c].Published {
+				e.Notebooks++
+			}
+		}
+		for _, nt := range s.notes[child] {
+			if !published || nt.Published {
+				e.Notes++
+			}
+		}
+		notebooks = append(notebooks, e)
+	}
+
+	for _, nt := range s.notes[id] {
+		if !published || nt.Published {
+			notes = append(notes, nt)
+		}
+	}
+
+	return
+}
+
+// Path returns notebooks from the root to the notebook, including the notebook
+func (s *Shelf) Path(id ID) []Notebook {
+	var path []Notebook
+	for nb, ok := s.notebooks[id]; ok && len(path) <= len(s.notebooks); nb, ok = s.notebooks[nb.Parent] {
+		path = append(path, nb)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+// Descendants returns ids of notebook and all notebooks nested in it
+func (s *Shelf) Descendants(id ID) []ID {
+	ids := []ID{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, s.children[ids[i]]...)
+	}
+	return ids
+}
+
+// CanMove checks whether notebook can be moved into parent, parent 0 is the root, id
+// that is not on the shelf is checked as new empty notebook
+func (s *Shelf) CanMove(id, parent ID) error {
+	if parent == 0 {
+		return nil
+	}
+
+	path := s.Path(parent)
+	for _, nb := range path {
+		if nb.ID == id {
+			return ErrNotebookCycle
+		}
+	}
+
+	height := 1
+	if _, ok := s.notebooks[id]; ok {
+		height = s.height(id)
+	}
+
+	if len(path)+height > MaxNotebookDepth {
+		return ErrNotebookDepth.Args(MaxNotebookDepth)
+	}
+
+	return nil
+}
+
+// height returns amount of levels of notebook subtree, notebook itself included
+func (s *Shelf) height(id ID) int {
+	h := 0
+	for _, c := range s.children[id] {
+		h = max(h, s.height(c))
+	}
+	return h + 1
+}
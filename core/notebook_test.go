@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testShelf() *Shelf {
+	return NShelf([]Notebook{
+		{ID: 1, Name: "math", Published: true},
+		{ID: 2, Parent: 1, Name: "algebra", Published: true},
+		{ID: 3, Parent: 1, Name: "geometry"},
+		{ID: 4, Parent: 2, Name: "linear", Published: true},
+		{ID: 5, Parent: 9, Name: "lost"},
+	}, []Draft{
+		{ID: 10, Notebook: 1, Published: true},
+		{ID: 11, Notebook: 1},
+		{ID: 12, Notebook: 2, Published: true},
+		{ID: 13},
+		{ID: 14, Notebook: 9},
+	})
+}
+
+func TestShelfContents(t *testing.T) {
+	s := testShelf()
+
+	testCases := []struct {
+		desc      string
+		id        ID
+		published bool
+		notebooks []NotebookEntry
+		notes     []ID
+	}{
+		{desc: "root", id: 0, notebooks: []NotebookEntry{
+			{Notebook: Notebook{ID: 5, Parent: 9, Name: "lost"}},
+			{Notebook: Notebook{ID: 1, Name: "math", Published: true}, Notebooks: 2, Notes: 2},
+		}, notes: []ID{13, 14}},
+		{desc: "notebook", id: 1, notebooks: []NotebookEntry{
+			{Notebook: Notebook{ID: 2, Parent: 1, Name: "algebra", Published: true}, Notebooks: 1, Notes: 1},
+			{Notebook: Notebook{ID: 3, Parent: 1, Name: "geometry"}},
+		}, notes: []ID{10, 11}},
+		{desc: "published", id: 1, published: true, notebooks: []NotebookEntry{
+			{Notebook: Notebook{ID: 2, Parent: 1, Name: "algebra", Published: true}, Notebooks: 1, Notes: 1},
+		}, notes: []ID{10}},
+		{desc: "empty", id: 4, notebooks: []NotebookEntry{}, notes: []ID{}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			notebooks, notes := s.Contents(tC.id, tC.published)
+			if !reflect.DeepEqual(notebooks, tC.notebooks) {
+				t.Error(notebooks)
+			}
+
+			ids := []ID{}
+			for _, nt := range notes {
+				ids = append(ids, nt.ID)
+			}
+			if !reflect.DeepEqual(ids, tC.notes) {
+				t.Error(ids)
+			}
+		})
+	}
+}
+
+func TestShelfMove(t *testing.T) {
+	s := testShelf()
+
+	if path := s.Path(4); len(path) != 3 || path[0].ID != 1 || path[2].ID != 4 {
+		t.Error(path)
+	}
+	if ids := s.Descendants(1); !reflect.DeepEqual(ids, []ID{1, 2, 3, 4}) {
+		t.Error(ids)
+	}
+
+	deep := make([]Notebook, MaxNotebookDepth)
+	for i := range deep {
+		deep[i] = Notebook{ID: ID(i + 1), Parent: ID(i)}
+	}
+	ds := NShelf(deep, nil)
+
+	testCases := []struct {
+		desc       string
+		s          *Shelf
+		id, parent ID
+		err        error
+	}{
+		{desc: "to root", s: s, id: 4, parent: 0},
+		{desc: "to sibling", s: s, id: 3, parent: 2},
+		{desc: "into itself", s: s, id: 2, parent: 2, err: ErrNotebookCycle},
+		{desc: "into descendant", s: s, id: 1, parent: 4, err: ErrNotebookCycle},
+		{desc: "new notebook", s: s, id: 100, parent: 4},
+		{desc: "too deep", s: ds, id: 100, parent: MaxNotebookDepth, err: ErrNotebookDepth},
+		{desc: "deepest", s: ds, id: MaxNotebookDepth, parent: MaxNotebookDepth - 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if err := tC.s.CanMove(tC.id, tC.parent); !errors.Is(err, tC.err) {
+				t.Error(err)
+			}
+		})
+	}
+}
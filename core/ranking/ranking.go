@@ -0,0 +1,152 @@
+// Package ranking scores published notes by likes, comments and views, top score is plain
+// weighted sum while trending score decays with age of note so fresh popular notes rise
+// above old ones
+package ranking
+
+import (
+	"math"
+	"myNotes/core"
+	"sort"
+	"time"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// ErrWindow is returned for unknown name of window
+var ErrWindow = sterr.New("unknown window %s, use day, week, month, year or all")
+
+// Windows are periods rankings can be limited to by publish date, 0 means no limit
+var Windows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+// Window returns duration of named window
+func Window(name string) (time.Duration, error) {
+	d, ok := Windows[name]
+	if !ok {
+		return 0, ErrWindow.Args(name)
+	}
+	return d, nil
+}
+
+// Stats are counts of interactions with published note
+type Stats struct {
+	ID     core.ID `bson:"_id"`
+	Author core.ID
+
+	School  int
+	Subject string
+	Name    string
+
+	PublishDate int64
+
+	Likes, Comments int
+	Views           int64
+}
+
+// Weights are points for each interaction
+type Weights struct {
+	Like, Comment, View float64
+}
+
+// Score returns weighted sum of interactions of note
+func (w Weights) Score(s Stats) float64 {
+	return float64(s.Likes)*w.Like + float64(s.Comments)*w.Comment + float64(s.Views)*w.View
+}
+
+// Decay returns factor trending score of note of given age is multiplied by, it halves
+// every halfLife
+func Decay(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
+
+// Entry is ranked note
+type Entry struct {
+	Stats
+	Score float64
+}
+
+// Filter restricts ranked notes, zero values match everything, Since is publish date in
+// milliseconds
+type Filter struct {
+	School  int
+	Subject string
+	Since   int64
+}
+
+// Match returns whether filter accepts note
+func (f Filter) Match(s Stats) bool {
+	return (f.School == 0 || s.School == f.School) &&
+		(f.Subject == "" || s.Subject == f.Subject) &&
+		s.PublishDate >= f.Since
+}
+
+// Ranking is snapshot of scores computed at Computed (in milliseconds), it is not changed
+// after computing so it can be shared
+type Ranking struct {
+	Computed      int64
+	top, trending []Entry
+}
+
+// Compute ranks notes at now (in milliseconds), notes nobody interacted with are left out
+func Compute(stats []Stats, w Weights, halfLife time.Duration, now int64) *Ranking {
+	r := &Ranking{Computed: now}
+	for _, s := range stats {
+		score := w.Score(s)
+		if score <= 0 {
+			continue
+		}
+		age := time.Duration(now-s.PublishDate) * time.Millisecond
+		r.top = append(r.top, Entry{s, score})
+		r.trending = append(r.trending, Entry{s, score * Decay(age, halfLife)})
+	}
+
+	sortEntries(r.top)
+	sortEntries(r.trending)
+
+	return r
+}
+
+// Top returns up to limit notes matching filter with highest score
+func (r *Ranking) Top(f Filter, limit int) []Entry {
+	return pick(r.top, f, limit)
+}
+
+// Trending returns up to limit notes matching filter with highest decayed score
+func (r *Ranking) Trending(f Filter, limit int) []Entry {
+	return pick(r.trending, f, limit)
+}
+
+// sortEntries sorts by score, ties go to newer notes
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.PublishDate != b.PublishDate {
+			return a.PublishDate > b.PublishDate
+		}
+		return a.ID > b.ID
+	})
+}
+
+func pick(entries []Entry, f Filter, limit int) []Entry {
+	picked := []Entry{}
+	for _, e := range entries {
+		if len(picked) == limit {
+			break
+		}
+		if f.Match(e.Stats) {
+			picked = append(picked, e)
+		}
+	}
+	return picked
+}
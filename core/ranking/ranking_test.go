@@ -0,0 +1,94 @@
+package ranking
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const hour = int64(time.Hour / time.Millisecond)
+
+func TestDecay(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		age      time.Duration
+		expected float64
+	}{
+		{desc: "fresh", age: 0, expected: 1},
+		{desc: "half life", age: time.Hour, expected: .5},
+		{desc: "two half lives", age: 2 * time.Hour, expected: .25},
+		{desc: "future", age: -time.Hour, expected: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if d := Decay(tC.age, time.Hour); math.Abs(d-tC.expected) > 1e-9 {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		expected time.Duration
+		err      error
+	}{
+		{desc: "day", expected: 24 * time.Hour},
+		{desc: "all"},
+		{desc: "century", err: ErrWindow},
+		{desc: "", err: ErrWindow},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			d, err := Window(tC.desc)
+			if !errors.Is(err, tC.err) || d != tC.expected {
+				t.Error(d, err)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	now := 100 * hour
+	w := Weights{Like: 3, Comment: 2, View: 1}
+	r := Compute([]Stats{
+		{ID: 1, School: 1, Subject: "math", PublishDate: 0, Likes: 10},
+		{ID: 2, School: 2, Subject: "math", PublishDate: now - hour, Likes: 2, Comments: 1, Views: 4},
+		{ID: 3, School: 1, Subject: "physics", PublishDate: now - 2*hour, Views: 14},
+		{ID: 4, School: 1, Subject: "math", PublishDate: now},
+	}, w, time.Hour, now)
+
+	ids := func(entries []Entry) []uint64 {
+		res := []uint64{}
+		for _, e := range entries {
+			res = append(res, e.ID)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		desc     string
+		list     func(*Ranking, Filter, int) []Entry
+		filter   Filter
+		limit    int
+		expected []uint64
+	}{
+		{desc: "top", list: (*Ranking).Top, limit: 10, expected: []uint64{1, 3, 2}},
+		{desc: "top limit", list: (*Ranking).Top, limit: 1, expected: []uint64{1}},
+		{desc: "top window", list: (*Ranking).Top, filter: Filter{Since: now - 3*hour}, limit: 10, expected: []uint64{3, 2}},
+		{desc: "trending", list: (*Ranking).Trending, limit: 10, expected: []uint64{2, 3, 1}},
+		{desc: "school", list: (*Ranking).Trending, filter: Filter{School: 1}, limit: 10, expected: []uint64{3, 1}},
+		{desc: "subject", list: (*Ranking).Trending, filter: Filter{Subject: "math"}, limit: 10, expected: []uint64{2, 1}},
+		{desc: "nothing", list: (*Ranking).Top, filter: Filter{Subject: "art"}, limit: 10, expected: []uint64{}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if res := ids(tC.list(r, tC.filter, tC.limit)); !reflect.DeepEqual(res, tC.expected) {
+				t.Error(res)
+			}
+		})
+	}
+}
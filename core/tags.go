@@ -0,0 +1,108 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// tag limits
+const (
+	MaxTags      = 10
+	MaxTagLength = 32
+)
+
+// tag errors
+var (
+	ErrTag         = sterr.New("tag %s is longer than %d characters")
+	ErrTooManyTags = sterr.New("note can have at most %d tags")
+	ErrTagQuery    = sterr.New("tag query %s has too many tags")
+)
+
+// TagCount is tag with amount of published notes that have it
+type TagCount struct {
+	Tag   string `bson:"_id"`
+	Count int
+}
+
+// NormalizeTag lowercases tag and replaces whitespace, dashes and underscores with single
+// dash, other characters than letters, digits and "+#." are dropped so "C++" and "c++ "
+// are the same tag, leading "#" is not part of tag, result is empty if nothing is left
+func NormalizeTag(tag string) string {
+	tag = strings.TrimLeft(strings.TrimSpace(tag), "#")
+
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(tag) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), strings.ContainsRune("+#.", r):
+			if dash && sb.Len() != 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		case unicode.IsSpace(r), r == '-', r == '_':
+			dash = true
+		}
+	}
+
+	return sb.String()
+}
+
+// NormalizeTags normalizes tags and removes empty and duplicate ones, order is kept
+func NormalizeTags(tags []string) ([]string, error) {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > MaxTagLength {
+			return nil, ErrTag.Args(t, MaxTagLength)
+		}
+
+		seen[t] = true
+		res = append(res, t)
+	}
+
+	if len(res) > MaxTags {
+		return nil, ErrTooManyTags.Args(MaxTags)
+	}
+
+	return res, nil
+}
+
+// ParseTags splits comma separated list of tags and normalizes it
+func ParseTags(raw string) ([]string, error) {
+	return NormalizeTags(strings.Split(raw, ","))
+}
+
+// ParseTagQuery parses tag filter of search, groups separated by comma have to match all
+// and tags of group separated by "|" are alternatives, "math,algebra|geometry" matches
+// notes tagged math and either algebra or geometry
+func ParseTagQuery(raw string) ([][]string, error) {
+	var query [][]string
+	count := 0
+	for _, group := range strings.Split(raw, ",") {
+		var alts []string
+		for _, t := range strings.Split(group, "|") {
+			if t = NormalizeTag(t); t != "" {
+				alts = append(alts, t)
+			}
+		}
+
+		count += len(alts)
+		if count > MaxTags {
+			return nil, ErrTagQuery.Args(raw)
+		}
+
+		if len(alts) != 0 {
+			query = append(query, alts)
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	testCases := []struct {
+		desc string
+		in   []string
+		out  []string
+		err  error
+	}{
+		{desc: "case and spaces", in: []string{" Linear  Algebra ", "linear_algebra", "#linear-algebra"}, out: []string{"linear-algebra"}},
+		{desc: "symbols", in: []string{"C++", "c#", "node.js", "what?!"}, out: []string{"c++", "c#", "node.js", "what"}},
+		{desc: "unicode", in: []string{"Řešení"}, out: []string{"řešení"}},
+		{desc: "empty", in: []string{"", " - ", "#"}, out: []string{}},
+		{desc: "too long", in: []string{strings.Repeat("a", MaxTagLength+1)}, err: ErrTag},
+		{desc: "too many", in: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), err: ErrTooManyTags},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := NormalizeTags(tC.in)
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if err == nil && !reflect.DeepEqual(out, tC.out) {
+				t.Error(out)
+			}
+		})
+	}
+}
+
+func TestParseTagQuery(t *testing.T) {
+	testCases := []struct {
+		desc, in string
+		out      [][]string
+		err      error
+	}{
+		{desc: "all", in: "Math, physics", out: [][]string{{"math"}, {"physics"}}},
+		{desc: "any", in: "math,algebra|Geometry", out: [][]string{{"math"}, {"algebra", "geometry"}}},
+		{desc: "empty groups", in: ",|,math", out: [][]string{{"math"}}},
+		{desc: "too many", in: "a|b|c|d|e|f,g,h,i,j,k", err: ErrTagQuery},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			out, err := ParseTagQuery(tC.in)
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if err == nil && !reflect.DeepEqual(out, tC.out) {
+				t.Error(out)
+			}
+		})
+	}
+}
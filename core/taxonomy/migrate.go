@@ -0,0 +1,66 @@
+package taxonomy
+
+import "sort"
+
+// Report summarizes migration of free text values of notes to keys of terms
+type Report struct {
+	Notes, Changed int
+	Unresolved     []Unresolved
+
+	index map[Kind]map[string]int
+}
+
+// Unresolved is value that no term matches, admins can add it as alias and migrate again
+type Unresolved struct {
+	Kind  Kind
+	Value string
+	Notes int
+}
+
+// Migrate returns subject and theme of note mapped to keys of terms, values that cannot
+// be resolved are kept and recorded in the report, values of unmanaged kinds are kept
+// as they are
+func (t *Taxonomy) Migrate(r *Report, subject, theme string) (string, string) {
+	r.Notes++
+
+	sub, ok := t.Lookup(SubjectK, subject)
+	if ok {
+		subject = sub.Key
+	} else if subject != "" && t.Managed(SubjectK) {
+		r.unresolved(SubjectK, subject)
+	}
+
+	th, found := t.Lookup(ThemeK, theme)
+	if found && ok && th.Parent == sub.Key {
+		theme = th.Key
+	} else if theme != "" && t.Managed(ThemeK) {
+		r.unresolved(ThemeK, theme)
+	}
+
+	return subject, theme
+}
+
+func (r *Report) unresolved(kind Kind, value string) {
+	if r.index == nil {
+		r.index = map[Kind]map[string]int{}
+	}
+	if r.index[kind] == nil {
+		r.index[kind] = map[string]int{}
+	}
+
+	i, ok := r.index[kind][value]
+	if !ok {
+		i = len(r.Unresolved)
+		r.index[kind][value] = i
+		r.Unresolved = append(r.Unresolved, Unresolved{Kind: kind, Value: value})
+	}
+	r.Unresolved[i].Notes++
+}
+
+// Sort orders unresolved values so the most common are first
+func (r *Report) Sort() {
+	sort.SliceStable(r.Unresolved, func(i, j int) bool {
+		return r.Unresolved[i].Notes > r.Unresolved[j].Notes
+	})
+	r.index = nil
+}
@@ -0,0 +1,292 @@
+// Package taxonomy manages values of school, subject and theme of notes, values are stored
+// as keys of terms and anything users type is resolved through keys and aliases so
+// "Math" and "mathematics" end up as the same subject
+package taxonomy
+
+import (
+	"myNotes/core"
+	"sort"
+
+	"github.com/jakubDoka/sterr"
+)
+
+// Kind of term
+type Kind string
+
+// Kind variants
+const (
+	SchoolK  Kind = "school"
+	SubjectK Kind = "subject"
+	ThemeK   Kind = "theme"
+)
+
+// Kinds lists all kinds in order terms are sorted
+var Kinds = []Kind{SchoolK, SubjectK, ThemeK}
+
+// taxonomy errors
+var (
+	ErrKind      = sterr.New("unknown term kind %s")
+	ErrKey       = sterr.New("%s key %s is not normalized")
+	ErrDuplicate = sterr.New("%s %s is used by more terms")
+	ErrCode      = sterr.New("school %s needs unique positive code")
+	ErrParent    = sterr.New("%s %s refers to unknown %s %s")
+	ErrInUse     = sterr.New("%s %s is still used by %s %s")
+	ErrUnknown   = sterr.New("unknown %s %s")
+	ErrNotTaught = sterr.New("subject %s is not taught at school %s")
+	ErrTheme     = sterr.New("theme %s does not belong to subject %s")
+)
+
+// Term is managed value, Code is value of core.Note.School and is used only by schools,
+// Schools restricts subjects to school levels, empty means all, themes belong to subject
+// Parent, Names are display names by locale
+type Term struct {
+	Kind Kind
+	Key  string
+
+	Code    int      `json:",omitempty"`
+	Parent  string   `json:",omitempty"`
+	Schools []string `json:",omitempty"`
+
+	Names   map[string]string
+	Aliases []string
+}
+
+// Name returns display name in first locale that term has a name for, key is used when
+// there is none
+func (t Term) Name(locales ...string) string {
+	for _, l := range locales {
+		if n, ok := t.Names[l]; ok {
+			return n
+		}
+	}
+	return t.Key
+}
+
+// Taxonomy is validated set of terms, it is immutable so it can be shared
+type Taxonomy struct {
+	terms   []Term
+	lookup  map[Kind]map[string]int
+	schools map[int]int
+	themes  map[string]int
+}
+
+// Defaults returns school levels that were hardcoded before taxonomy existed, codes are
+// the same so notes do not need migration of schools
+func Defaults() []Term {
+	return []Term{
+		{Kind: SchoolK, Key: "elementary-middle", Code: 1, Names: map[string]string{"en": "elementary and middle school", "cs": "základní škola"}, Aliases: []string{"elementary", "middle", "primary"}},
+		{Kind: SchoolK, Key: "high", Code: 2, Names: map[string]string{"en": "high school", "cs": "střední škola"}, Aliases: []string{"high-school", "secondary"}},
+		{Kind: SchoolK, Key: "university", Code: 3, Names: map[string]string{"en": "university", "cs": "vysoká škola"}, Aliases: []string{"college", "uni"}},
+	}
+}
+
+// New validates terms and builds lookup tables, keys and aliases have to be normalized
+// by core.NormalizeTag and unique within kind
+func New(terms []Term) (*Taxonomy, error) {
+	t := &Taxonomy{
+		terms:   append([]Term(nil), terms...),
+		lookup:  map[Kind]map[string]int{SchoolK: {}, SubjectK: {}, ThemeK: {}},
+		schools: map[int]int{},
+		themes:  map[string]int{},
+	}
+
+	order := map[Kind]int{}
+	for i, k := range Kinds {
+		order[k] = i
+	}
+	sort.SliceStable(t.terms, func(i, j int) bool {
+		a, b := t.terms[i], t.terms[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return a.Key < b.Key
+	})
+
+	for i, term := range t.terms {
+		lookup, ok := t.lookup[term.Kind]
+		if !ok {
+			return nil, ErrKind.Args(term.Kind)
+		}
+
+		for _, v := range append([]string{term.Key}, term.Aliases...) {
+			if v == "" || core.NormalizeTag(v) != v {
+				return nil, ErrKey.Args(term.Kind, v)
+			}
+			if _, ok := lookup[v]; ok {
+				return nil, ErrDuplicate.Args(term.Kind, v)
+			}
+			lookup[v] = i
+		}
+
+		if term.Kind == SchoolK {
+			if _, ok := t.schools[term.Code]; ok || term.Code <= 0 {
+				return nil, ErrCode.Args(term.Key)
+			}
+			t.schools[term.Code] = i
+		}
+	}
+
+	for _, term := range t.terms {
+		switch term.Kind {
+		case SubjectK:
+			for _, s := range term.Schools {
+				if other, ok := t.Lookup(SchoolK, s); !ok || other.Key != s {
+					return nil, ErrParent.Args(term.Kind, term.Key, SchoolK, s)
+				}
+			}
+		case ThemeK:
+			if other, ok := t.Lookup(SubjectK, term.Parent); !ok || other.Key != term.Parent {
+				return nil, ErrParent.Args(term.Kind, term.Key, SubjectK, term.Parent)
+			}
+			t.themes[term.Parent]++
+		}
+	}
+
+	return t, nil
+}
+
+// Terms returns all terms of kind, empty kind returns all terms
+func (t *Taxonomy) Terms(kind Kind) []Term {
+	terms := []Term{}
+	for _, term := range t.terms {
+		if kind == "" || term.Kind == kind {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Lookup finds term of kind by its key or alias, value is normalized first
+func (t *Taxonomy) Lookup(kind Kind, value string) (Term, bool) {
+	i, ok := t.lookup[kind][core.NormalizeTag(value)]
+	if !ok {
+		return Term{}, false
+	}
+	return t.terms[i], true
+}
+
+// Managed reports whether taxonomy defines any terms of kind, values of unmanaged kinds
+// are free text
+func (t *Taxonomy) Managed(kind Kind) bool {
+	return len(t.lookup[kind]) != 0
+}
+
+// School returns school term of core.Note.School value
+func (t *Taxonomy) School(code int) (Term, bool) {
+	i, ok := t.schools[code]
+	if !ok {
+		return Term{}, false
+	}
+	return t.terms[i], true
+}
+
+// SchoolCode returns code of school by key or alias, "" and "none" are 0, unknown school
+// is reported as error
+func (t *Taxonomy) SchoolCode(name string) (int, error) {
+	if name == "" || name == "none" {
+		return 0, nil
+	}
+
+	term, ok := t.Lookup(SchoolK, name)
+	if !ok {
+		return 0, ErrUnknown.Args(SchoolK, name)
+	}
+	return term.Code, nil
+}
+
+// Labels are display names of school, subject and theme of note
+type Labels struct {
+	School, Subject, Theme string
+}
+
+// Labels returns display names of note values in first locale terms have name for, values
+// that are not terms are shown as they are and unknown school is "none"
+func (t *Taxonomy) Labels(nt core.Note, locales ...string) Labels {
+	l := Labels{School: "none", Subject: nt.Subject, Theme: nt.Theme}
+	if sch, ok := t.School(nt.School); ok {
+		l.School = sch.Name(locales...)
+	}
+	if sub, ok := t.Lookup(SubjectK, nt.Subject); ok {
+		l.Subject = sub.Name(locales...)
+	}
+	if th, ok := t.Lookup(ThemeK, nt.Theme); ok {
+		l.Theme = th.Name(locales...)
+	}
+	return l
+}
+
+// Canonical resolves subject and theme of note to keys of terms, empty values stay empty,
+// subject has to be taught at the school and theme has to belong to the subject when
+// the subject has managed themes
+func (t *Taxonomy) Canonical(school int, subject, theme string) (string, string, error) {
+	if subject == "" || !t.Managed(SubjectK) {
+		return subject, theme, nil
+	}
+
+	sub, ok := t.Lookup(SubjectK, subject)
+	if !ok {
+		return "", "", ErrUnknown.Args(SubjectK, subject)
+	}
+
+	if sch, ok := t.School(school); ok && len(sub.Schools) != 0 && !contains(sub.Schools, sch.Key) {
+		return "", "", ErrNotTaught.Args(sub.Key, sch.Key)
+	}
+
+	if theme == "" || t.themes[sub.Key] == 0 {
+		return sub.Key, theme, nil
+	}
+
+	th, ok := t.Lookup(ThemeK, theme)
+	if !ok {
+		return "", "", ErrUnknown.Args(ThemeK, theme)
+	}
+	if th.Parent != sub.Key {
+		return "", "", ErrTheme.Args(th.Key, sub.Key)
+	}
+
+	return sub.Key, th.Key, nil
+}
+
+// Set returns new taxonomy with term added or replaced, term is matched by kind and key
+func (t *Taxonomy) Set(term Term) (*Taxonomy, error) {
+	terms := []Term{}
+	for _, other := range t.terms {
+		if other.Kind != term.Kind || other.Key != term.Key {
+			terms = append(terms, other)
+		}
+	}
+	return New(append(terms, term))
+}
+
+// Remove returns new taxonomy without term, terms that refer to it have to be removed first
+func (t *Taxonomy) Remove(kind Kind, key string) (*Taxonomy, error) {
+	terms := []Term{}
+	found := false
+	for _, term := range t.terms {
+		if term.Kind == kind && term.Key == key {
+			found = true
+			continue
+		}
+
+		if kind == SchoolK && term.Kind == SubjectK && contains(term.Schools, key) ||
+			kind == SubjectK && term.Kind == ThemeK && term.Parent == key {
+			return nil, ErrInUse.Args(kind, key, term.Kind, term.Key)
+		}
+		terms = append(terms, term)
+	}
+
+	if !found {
+		return nil, ErrUnknown.Args(kind, key)
+	}
+
+	return New(terms)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package taxonomy
+
+import (
+	"errors"
+	"myNotes/core"
+	"reflect"
+	"testing"
+)
+
+func testTaxonomy(t *testing.T) *Taxonomy {
+	tx, err := New(append(Defaults(),
+		Term{Kind: SubjectK, Key: "math", Names: map[string]string{"en": "Mathematics", "cs": "Matematika"}, Aliases: []string{"mathematics", "maths"}},
+		Term{Kind: SubjectK, Key: "physics", Schools: []string{"high", "university"}},
+		Term{Kind: ThemeK, Key: "linear-algebra", Parent: "math", Aliases: []string{"algebra"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		terms []Term
+		err   error
+	}{
+		{desc: "defaults", terms: Defaults()},
+		{desc: "kind", terms: []Term{{Kind: "topic", Key: "a"}}, err: ErrKind},
+		{desc: "not normalized", terms: []Term{{Kind: SubjectK, Key: "Math"}}, err: ErrKey},
+		{desc: "alias of other term", terms: []Term{{Kind: SubjectK, Key: "a"}, {Kind: SubjectK, Key: "b", Aliases: []string{"a"}}}, err: ErrDuplicate},
+		{desc: "same key of other kind", terms: []Term{{Kind: SubjectK, Key: "a"}, {Kind: ThemeK, Key: "a", Parent: "a"}}},
+		{desc: "school code", terms: append(Defaults(), Term{Kind: SchoolK, Key: "other", Code: 1}), err: ErrCode},
+		{desc: "unknown school", terms: []Term{{Kind: SubjectK, Key: "a", Schools: []string{"high"}}}, err: ErrParent},
+		{desc: "parent by alias", terms: []Term{{Kind: SubjectK, Key: "a", Aliases: []string{"b"}}, {Kind: ThemeK, Key: "c", Parent: "b"}}, err: ErrParent},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := New(tC.terms)
+			if !errors.Is(err, tC.err) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tx := testTaxonomy(t)
+
+	testCases := []struct {
+		desc                 string
+		school               int
+		subject, theme       string
+		outSubject, outTheme string
+		err                  error
+	}{
+		{desc: "alias", subject: "Mathematics", theme: "Algebra", outSubject: "math", outTheme: "linear-algebra"},
+		{desc: "empty", outSubject: "", outTheme: ""},
+		{desc: "free theme", subject: "physics", theme: "Optics", school: 2, outSubject: "physics", outTheme: "Optics"},
+		{desc: "unknown subject", subject: "Alchemy", err: ErrUnknown},
+		{desc: "not taught", subject: "physics", school: 1, err: ErrNotTaught},
+		{desc: "unknown theme", subject: "math", theme: "Optics", err: ErrUnknown},
+		{desc: "theme of other subject", subject: "physics", theme: "algebra", err: nil, outSubject: "physics", outTheme: "algebra"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			subject, theme, err := tx.Canonical(tC.school, tC.subject, tC.theme)
+			if !errors.Is(err, tC.err) {
+				t.Fatal(err)
+			}
+			if subject != tC.outSubject || theme != tC.outTheme {
+				t.Error(subject, theme)
+			}
+		})
+	}
+
+	free, _ := New(Defaults())
+	if subject, theme, err := free.Canonical(1, "Alchemy", "Gold"); err != nil || subject != "Alchemy" || theme != "Gold" {
+		t.Error(subject, theme, err)
+	}
+}
+
+func TestSchool(t *testing.T) {
+	tx := testTaxonomy(t)
+
+	for name, code := range map[string]int{"": 0, "none": 0, "High": 2, "uni": 3} {
+		if c, err := tx.SchoolCode(name); c != code || err != nil {
+			t.Error(name, c, err)
+		}
+	}
+	if _, err := tx.SchoolCode("kindergarten"); !errors.Is(err, ErrUnknown) {
+		t.Error(err)
+	}
+
+	sch, _ := tx.School(3)
+	if name := sch.Name("de", "cs", "en"); name != "vysoká škola" {
+		t.Error(name)
+	}
+	if name := sch.Name("de"); name != "university" {
+		t.Error(name)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tx := testTaxonomy(t)
+
+	testCases := []struct {
+		desc    string
+		note    core.Note
+		locales []string
+		labels  Labels
+	}{
+		{desc: "localized", note: core.Note{School: 1, Subject: "math", Theme: "linear-algebra"}, locales: []string{"cs", "en"}, labels: Labels{"základní škola", "Matematika", "linear-algebra"}},
+		{desc: "free text", note: core.Note{School: 7, Subject: "Alchemy"}, locales: []string{"en"}, labels: Labels{"none", "Alchemy", ""}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if l := tx.Labels(tC.note, tC.locales...); l != tC.labels {
+				t.Error(l)
+			}
+		})
+	}
+}
+
+func TestSetRemove(t *testing.T) {
+	tx := testTaxonomy(t)
+
+	changed, err := tx.Set(Term{Kind: SubjectK, Key: "math", Aliases: []string{"matika"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := changed.Lookup(SubjectK, "maths"); ok {
+		t.Error("old alias is still present")
+	}
+	if term, ok := changed.Lookup(SubjectK, "matika"); !ok || term.Key != "math" {
+		t.Error(term)
+	}
+
+	if _, err := tx.Remove(SubjectK, "math"); !errors.Is(err, ErrInUse) {
+		t.Error(err)
+	}
+	if _, err := tx.Remove(SchoolK, "high"); !errors.Is(err, ErrInUse) {
+		t.Error(err)
+	}
+	if _, err := tx.Remove(ThemeK, "geometry"); !errors.Is(err, ErrUnknown) {
+		t.Error(err)
+	}
+	removed, err := tx.Remove(ThemeK, "linear-algebra")
+	if err != nil || len(removed.Terms(ThemeK)) != 0 {
+		t.Error(err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tx := testTaxonomy(t)
+
+	var r Report
+	notes := [][4]string{
+		{"Maths", "algebra", "math", "linear-algebra"},
+		{"Physics", "Optics", "physics", "Optics"},
+		{"Chemistry", "", "Chemistry", ""},
+		{"chemistry ", "", "chemistry ", ""},
+		{"chemistry ", "", "chemistry ", ""},
+	}
+	for _, n := range notes {
+		subject, theme := tx.Migrate(&r, n[0], n[1])
+		if subject != n[2] || theme != n[3] {
+			t.Error(n, subject, theme)
+		}
+	}
+	r.Sort()
+
+	expected := []Unresolved{
+		{Kind: SubjectK, Value: "chemistry ", Notes: 2},
+		{Kind: ThemeK, Value: "Optics", Notes: 1},
+		{Kind: SubjectK, Value: "Chemistry", Notes: 1},
+	}
+	if r.Notes != len(notes) || !reflect.DeepEqual(r.Unresolved, expected) {
+		t.Error(r)
+	}
+}
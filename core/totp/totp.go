@@ -0,0 +1,125 @@
+// Package totp implements time based one time passwords as described in RFC 6238,
+// codes are compatible with common authenticator apps
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// parameters of generated codes, they are also encoded in provisioning uri
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	Skew   = 1
+
+	SecretSize   = 20
+	RecoverySize = 5
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NSecret generates new random secret encoded in base32
+func NSecret() (string, error) {
+	bts := make([]byte, SecretSize)
+	_, err := rand.Read(bts)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(bts), nil
+}
+
+// Step returns time step of given time
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns code for given secret and time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, Step(t)), nil
+}
+
+// Verify checks code against steps around t, steps that are not greater then last are
+// rejected so code cannot be used twice, step of accepted code is returned
+func Verify(secret, c string, t time.Time, last int64) (int64, bool) {
+	key, err := decode(secret)
+	if err != nil || len(c) != Digits {
+		return 0, false
+	}
+
+	step := Step(t)
+	for i := step - Skew; i <= step+Skew; i++ {
+		if i > last && hmac.Equal([]byte(code(key, i)), []byte(c)) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns provisioning uri, it is meant to be encoded into QR code and scanned
+// by authenticator app
+func URI(secret, issuer, account string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period / time.Second))},
+	}
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// RecoveryCodes generates n random one time recovery codes
+func RecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	bts := make([]byte, RecoverySize)
+	for i := range codes {
+		_, err := rand.Read(bts)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = hex.EncodeToString(bts)
+	}
+
+	return codes, nil
+}
+
+func decode(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+func code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	m := hmac.New(sha1.New, key)
+	m.Write(msg[:])
+	sum := m.Sum(nil)
+
+	off := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
@@ -0,0 +1,60 @@
+package totp
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// secret from RFC 6238 appendix B
+var secret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		time   int64
+		result string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tC := range testCases {
+		t.Run(strconv.FormatInt(tC.time, 10), func(t *testing.T) {
+			res, err := Code(secret, time.Unix(tC.time, 0))
+			if err != nil {
+				t.Error(err)
+			}
+			if res != tC.result {
+				t.Error(res, tC.result)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	c, _ := Code(secret, now)
+
+	testCases := []struct {
+		desc string
+		time time.Time
+		last int64
+		ok   bool
+	}{
+		{"current", now, 0, true},
+		{"skew", now.Add(Period), 0, true},
+		{"expired", now.Add(Period * 2), 0, false},
+		{"reused", now, Step(now), false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, ok := Verify(secret, c, tC.time, tC.last)
+			if ok != tC.ok {
+				t.Error(ok, tC.ok)
+			}
+		})
+	}
+}
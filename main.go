@@ -1,21 +1,75 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"myNotes/core"
+	"myNotes/core/config"
 	"myNotes/core/http"
 	"myNotes/core/mongo"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	db, err := mongo.NDB("default", "myNotes")
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, err := core.NLogger(os.Stderr, cfg.Log.Format, cfg.Log.SlogLevel())
 	if err != nil {
 		panic(err)
 	}
+	slog.SetDefault(logger)
 
-	bot := *http.NEmailSender(http.BotAccount.Email, http.BotAccount.Password, 587)
+	db, err := mongo.NDB(cfg.Storage.URI, cfg.Storage.Database)
+	if err != nil {
+		logger.Error("failed to connect to database", "err", err)
+		os.Exit(1)
+	}
+
+	bot := http.NEmailSender(cfg.Mail.Host, cfg.Mail.Sender, cfg.Mail.Password, cfg.Mail.Port)
 
-	ws := http.NWS("127.0.0.1", "./web", 5504, db, bot)
+	ws := http.NWS(cfg, db, bot)
+
+	if cfg.Attachments.Storage == "gridfs" {
+		files, err := db.Files()
+		if err != nil {
+			logger.Error("failed to open attachment storage", "err", err)
+			os.Exit(1)
+		}
+		ws.SetStorage(files)
+	}
 
 	ws.RegisterHandlers()
 
-	ws.Run()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = ws.Run(ctx)
+	if err != nil {
+		logger.Error("server stopped", "err", err)
+	}
+
+	// database is closed last, requests and email queue might still need it until now
+	cctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if cerr := db.Close(cctx); cerr != nil {
+		logger.Error("failed to close database", "err", cerr)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -139,7 +139,7 @@ saveB.onclick = function(e) {
         colors += rgb2hex(e.style.backgroundColor) + " "
     })
 
-    request("configure", {name: nameA.value, colors: colors.replaceAll("#", "")}).then(j => {
+    post("configure", {name: nameA.value, colors: colors.replaceAll("#", "")}).then(j => {
         const err = getErr(j)
         if(err){
             error.innerHTML = err
@@ -8,6 +8,8 @@ const shortcuts = elem("shortcuts")
 const shortcutsB = elem("shortcuts-b")
 const save = elem("save")
 const publish = elem("publish")
+const attachB = elem("attach-b")
+const attach = elem("attach")
 var published = false
 
 const ident = elem("name")
@@ -16,6 +18,7 @@ const year = elem("year")
 const month = elem("month")
 const subject = elem("subject")
 const theme = elem("theme")
+const tags = elem("tags")
 
 const error = elem("error") 
 
@@ -35,6 +38,8 @@ for(var i in elems) {
     e.oninput = input
 }
 
+loadSchools(school)
+
 if(id != "") {
     request("privatenote", {id: id}).then(j => {
         const err = getErr(j)
@@ -44,11 +49,12 @@ if(id != "") {
             var n = j.Note
             raw.value = n.Content
             ident.value = n.Name
-            school.selectedIndex = n.School
+            school.value = schools[n.School] || ""
             year.value = n.Year
             month.value = n.Month
             subject.value = n.Subject
             theme.value = n.Theme
+            tags.value = (n.Tags || []).join(", ")
             published = n.Published
             switchPublish()
         }
@@ -109,6 +115,36 @@ shortcutsB.onclick = function(ev) {
     shortcuts.hidden = false
 }
 
+attachB.onclick = function(ev) {
+    ev.preventDefault()
+    attach.click()
+}
+
+// uploaded files are embedded at cursor, attachments not embedded in any note are
+// deleted by server after a while
+attach.onchange = function() {
+    const form = new FormData()
+    for(const f of attach.files) {
+        form.append("files", f)
+    }
+    attach.value = ""
+
+    fetch("/api/v1/me/attachments", {
+        method: "POST",
+        headers: {"X-CSRF-Token": getCookie("csrf")},
+        body: form,
+    }).then(re => re.json()).then(j => {
+        if(j.Error) {
+            error.innerHTML = j.Error.Message
+            return
+        }
+
+        error.innerHTML = ""
+        j.Attachments.forEach(a => write(`<embed:${a.ID}>`))
+        input()
+    })
+}
+
 save.onclick = function(ev) {
     ev.preventDefault()
     if(!IsComplete()) {
@@ -118,16 +154,16 @@ save.onclick = function(ev) {
 
     error.innerHTML = ""
 
-    request("save", {
+    post("save", {
         name: ident.value, 
         school:school.value, 
         year:year.value, 
         subject:subject.value, 
         theme:theme.value, 
+        tags:encodeURIComponent(tags.value),
         month:month.value, 
         id:id
     }, {
-        method: "POST",
         headers: {
             'content-type': 'text/plain'
         },
@@ -159,7 +195,7 @@ publish.onclick = function(ev) {
 
     error.innerHTML = ""
 
-    request("setpublished", {id: id, publish: !published}).then( j => {
+    post("setpublished", {id: id, publish: !published}).then( j => {
         const err = getErr(j)
         if(err) {
             error.innerHTML = err
@@ -0,0 +1,11 @@
+// Package web holds frontend files so they can be served without the web directory present,
+// brotli variants are picked up when they exist next to the file with .br suffix, for example
+// after running `find web -name '*.js' -o -name '*.css' | xargs brotli -k`
+package web
+
+import "embed"
+
+// FS contains everything in web directory including this file, go sources are not served
+//
+//go:embed *
+var FS embed.FS
@@ -43,6 +43,26 @@ refresh.onclick = async function(ev) {
 }
 
 embed("components/school.html", "schools").then(() => {
+    loadSchools(elem("school"))
     query = searchSetup()
+    query.tags.value = new URLSearchParams(window.location.search).get("tags") || ""
     refresh.click()
 })
+
+// tag cloud scales tags by how many notes use them, clicking tag adds it to the filter
+request("/api/v1/tags").then(j => {
+    const cloud = elem("tag-cloud")
+    const max = Math.max(1, ...j.Tags.map(t => t.Count))
+    j.Tags.forEach(t => {
+        const a = document.createElement("a")
+        a.href = "/index.html?tags=" + encodeURIComponent(t.Tag)
+        a.textContent = "#" + t.Tag
+        a.style.fontSize = (80 + 70 * t.Count / max) + "%"
+        a.onclick = ev => {
+            ev.preventDefault()
+            query.tags.value = (query.tags.value == "") ? t.Tag : query.tags.value + "," + t.Tag
+            refresh.click()
+        }
+        cloud.append(a, " ")
+    })
+}).catch(e => {})
@@ -34,7 +34,7 @@ singIn.onclick = function(ev) {
     }
     
     err.innerHTML = ""
-    sha256(password).then((str)=> request("register", {name: nm.value, password: str, email: email.value}).then(j => {
+    sha256(password).then((str)=> post("register", {name: nm.value, password: str, email: email.value}).then(j => {
         const err2 = getErr(j)
         if (err2) {
             err.innerHTML = err2
@@ -51,7 +51,7 @@ verify.onclick = function(ev) {
         return
     }
 
-    sha256(password).then((str)=> request("verify", {name: nm.value, password: str, code: code.value}).then((j) => {
+    sha256(password).then((str)=> post("verify", {name: nm.value, password: str, code: code.value}).then((j) => {
         const err2 = getErr(j)
         if (err2) {
             err.innerHTML = err2
@@ -68,14 +68,26 @@ login.onclick = function(ev) {
         return
     }
 
-    sha256(password).then((str)=> request("login", {name: nm.value, password: str}).then((j) => {
+    sha256(password).then((str)=> loginRequest({name: nm.value, password: str}))
+}
+
+const secondStepMessage = "two factor code is required"
+
+function loginRequest(params) {
+    post("login", params).then((j) => {
         const err2 = getErr(j)
-        if (err2) {
+        if (err2 && err2.endsWith(secondStepMessage)) {
+            const code = window.prompt("enter code from your authenticator app or one of recovery codes")
+            if (code) {
+                params.code = code
+                loginRequest(params)
+            }
+        } else if (err2) {
             err.innerHTML = err2
         } else {
             window.location.href = "account.html"
         }
-    }))
+    })
 }
 
 function missingName() {
@@ -48,14 +48,14 @@ function replace(start, end, str, sub) {
 function assertLogin(message) {
     const dat = getCookie("user")
     if(dat == ""){
-        window.location.href = "login.html"
+        window.location.href = "/login.html"
         window.alert(message)
     }
     return dat
 }
 
 function gotoLogin(message) {
-    window.location.href = "login.html"
+    window.location.href = "/login.html"
     if(message != "") {
         window.alert(message)
     }
@@ -86,8 +86,35 @@ async function sha256(message) {
     return hashHex;
 }
 
-const searchParams = ["name", "school", "year", "month", "subject", "theme", "author"]
+const searchParams = ["name", "school", "year", "month", "subject", "theme", "author", "tags"]
+// keys of schools indexed by Note.School, loadSchools replaces them by managed ones
 const schools = ["none", "elementary-middle", "high", "university"]
+const schoolNames = {}
+
+// loadSchools fills select with schools managed by server named in users language,
+// "none" option and selected value are kept
+async function loadSchools(select) {
+    const j = await request("/api/v1/taxonomy?kind=school")
+    if(!j || !j.Terms) return
+
+    const value = select && select.value
+    const none = select && select.querySelector("option[value=none]")
+    if(select) select.innerHTML = ""
+    if(none) select.append(none)
+
+    j.Terms.forEach(t => {
+        schools[t.Code] = t.Key
+        schoolNames[t.Key] = t.Name
+        if(select) {
+            const o = document.createElement("option")
+            o.value = t.Key
+            o.textContent = t.Name
+            select.append(o)
+        }
+    })
+
+    if(select && value) select.value = value
+}
 
 async function search(query) {
     return await fetch(buildRequest(searchParams, "search", query)).then(handleResponse)
@@ -102,6 +129,7 @@ function searchSetup() {
         subject: elem("subject"),
         theme: elem("theme"),
         author: elem("author"),
+        tags: elem("tags"),
     }
 }
 
@@ -113,6 +141,15 @@ async function request(command, params, init) {
     return await fetch(buildRequest(Object.keys(params), command, params), init).then(handleResponse)
 }
 
+// post sends request that changes state, server requires csrf cookie to be repeated
+// in header so other sites cannot make it on behalf of user
+async function post(command, params, init) {
+    init = init || {}
+    init.method = "POST"
+    init.headers = Object.assign({"X-CSRF-Token": getCookie("csrf")}, init.headers)
+    return await request(command, params, init)
+}
+
 async function handleResponse(re) {
     if(re.status != 200) {
         console.error(await re.text())
@@ -243,7 +280,7 @@ function registerLikeButton(like, counter, data, target, id) {
     like.onclick = async function(e) {
         e.preventDefault()
         console.log("pp")
-        request("like", {id: id, target: target, change: true}).then(j => {
+        post("like", {id: id, target: target, change: true}).then(j => {
             const err = getErr(j)
             if (err) {
                 alert(err)
@@ -263,31 +300,6 @@ async function embed(path, target) {
     const e = document.getElementById(target)
     const text = await loadText(path)
     e.innerHTML = text
-    Rerun(e)
-}
-
-function Rerun(elem) {
-    elem.childNodes.forEach(element => {
-        if(element.tagName == "SCRIPT") {
-            var data = (element.text || element.textContent || element.innerHTML || "" ),
-            head = document.getElementsByTagName("head")[0] ||
-                      document.documentElement,
-            script = document.createElement("script");
-    
-            script.type = "text/javascript";
-            try {
-            // doesn't work on ie...
-            script.appendChild(document.createTextNode(data));      
-            } catch(e) {
-            // IE has funky script nodes
-            script.text = data;
-            }
-        
-            head.insertBefore(script, head.firstChild);
-            head.removeChild(script);
-        }
-        Rerun(element)
-    })
 }
 
-embed("components/menu.html", "menu")
+embed("/components/menu.html", "menu")
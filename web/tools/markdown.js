@@ -14,6 +14,19 @@ class Block {
     }
 }
 
+// embedTag references uploaded attachment, id ends with extension of the file
+const embedTag = /^<embed:([0-9a-f]+\.[0-9a-z]+)>/
+const imageExts = new Set(["png", "jpg", "gif", "webp"])
+
+// embedHTML matches rendering of published notes on the server
+function embedHTML(id) {
+    const url = "/a/" + id
+    if(imageExts.has(id.substring(id.lastIndexOf(".")+1))) {
+        return `<a class="embed" href="${url}"><img src="${url}/thumb" alt="attachment" loading="lazy"></a>`
+    }
+    return `<a class="embed" href="${url}" download>${id}</a>`
+}
+
 function Colored(start, color) {
     b = new Block(start, "")
     b.color = color
@@ -62,6 +75,13 @@ class Markdown {
         }
 
         for(; i < raw.length; i++) {
+            const e = (raw[i] == "<") ? embedTag.exec(raw.substring(i, i+64)) : null
+            if(e) {
+                result.push(raw.substring(last, i), embedHTML(e[1]))
+                i += e[0].length-1
+                last = i+1
+                continue
+            }
             if(stack.length != 0 && this.check(stack[stack.length-1], i, raw)) {
                 handle(stack.pop(), true)
                 continue
@@ -1,4 +1,7 @@
-const id = new URLSearchParams(window.location.search).get("id")
+// notes served from /n/{id}/{slug} are already rendered by server, script only adds
+// interactive parts there
+const rendered = document.body.dataset.note
+const id = rendered || new URLSearchParams(window.location.search).get("id")
 const error = elem("error")
 const info = elem("info")
 const content = elem("content")
@@ -8,9 +11,7 @@ const commentA = elem("comment-a")
 const add = elem("add")
 const like = elem("like")
 
-loadAccount()
-
-request("publicnote", {id: id}).then(j => {
+if (!rendered) request("publicnote", {id: id}).then(j => {
     const err = getErr(j)
     if(err) {
         error.innerHTML = err
@@ -24,12 +25,16 @@ request("publicnote", {id: id}).then(j => {
         info.appendChild(infElem(e, n[key]))
     }) 
 
-    info.appendChild(infElem("school", schools[n.School]))
+    const sch = infElem("school", schools[n.School] || "none")
+    info.appendChild(sch)
+    loadSchools().then(() => {
+        info.replaceChild(infElem("school", schoolNames[schools[n.School]] || schools[n.School] || "none"), sch)
+    })
 
     request("publicaccount", {id: n.Author}).then(j => {
         var err = getErr(j) 
         if (err == undefined) {
-           err = `<a href="account.html?id=${n.Author}" style="color:wheat;">${j.Account.Name}</a>`
+           err = `<a href="/account.html?id=${n.Author}" style="color:wheat;">${j.Account.Name}</a>`
         }
         info.appendChild(infElem("author", err))
 
@@ -44,8 +49,7 @@ add.onclick = function(e) {
 
     error.innerHTML = ""
 
-    request("comment", {id: id, target: "note"}, {
-        method: "POST",
+    post("comment", {id: id, target: "note"}, {
         headers: {
             'content-type': 'text/plain'
         },